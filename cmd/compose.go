@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"io"
+	"os"
+
+	"github.com/databotic/traefik-migration-tool/cmd/options"
+	"github.com/databotic/traefik-migration-tool/internal/compose"
+	"github.com/databotic/traefik-migration-tool/internal/converter"
+	"github.com/databotic/traefik-migration-tool/internal/labels"
+	"github.com/databotic/traefik-migration-tool/internal/report"
+	"github.com/spf13/cobra"
+)
+
+func ConvertCompose() *cobra.Command {
+	o := options.NewConvertOptions()
+
+	cmd := &cobra.Command{
+		Use:   "convert-compose",
+		Short: "Convert Traefik v2 labels of docker-compose files to v3",
+		Long:  "Convert Traefik v2 labels of docker-compose and swarm stack files to v3",
+		PreRunE: func(_ *cobra.Command, _ []string) error {
+			return o.Process()
+		},
+		RunE: func(_ *cobra.Command, _ []string) error {
+			defer func() { _ = o.Input.Close() }()
+
+			src, err := io.ReadAll(o.Input)
+			if err != nil {
+				return err
+			}
+
+			ingressRoute, err := converter.NewIngressRoute()
+			if err != nil {
+				return err
+			}
+			tcpRule, err := converter.NewTCPRule()
+			if err != nil {
+				return err
+			}
+
+			rewriter := labels.New(ingressRoute)
+			rewriter.TCPRules = tcpRule
+
+			r := report.New()
+			converted, err := compose.New(rewriter, r).Convert(src)
+			if err != nil {
+				return err
+			}
+
+			if _, err = o.Out.Write(converted); err != nil {
+				return err
+			}
+
+			_, err = r.WriteTo(os.Stderr)
+			return err
+		},
+	}
+	o.AddFlags(cmd.Flags())
+
+	return cmd
+}
@@ -1,58 +1,347 @@
 package cmd
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/databotic/traefik-migration-tool/cmd/options"
 	"github.com/databotic/traefik-migration-tool/internal/converter"
+	"github.com/databotic/traefik-migration-tool/internal/fileprovider"
+	"github.com/databotic/traefik-migration-tool/internal/helm"
 	"github.com/databotic/traefik-migration-tool/internal/parser"
+	"github.com/databotic/traefik-migration-tool/internal/rawdata"
+	"github.com/databotic/traefik-migration-tool/internal/report"
+	"github.com/databotic/traefik-migration-tool/internal/staticconfig"
+	"github.com/databotic/traefik-migration-tool/internal/terraform"
 	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 var separator string = "---"
 
 func Convert() *cobra.Command {
 	o := options.NewConvertOptions()
+	var snapshot []runtime.Object
 
 	cmd := &cobra.Command{
 		Use:   "convert",
 		Short: "Convert Traefik v2 kubernetes resources to v3",
-		Long:  "Convert Traefik v2 kubernetes resources to v3",
+		Long:  "Convert Traefik v2 kubernetes resources and file provider dynamic configurations to v3",
 		PreRunE: func(_ *cobra.Command, _ []string) error {
-			return o.Process()
-		},
-		RunE: func(_ *cobra.Command, _ []string) error {
-			objects, err := parser.ParseManifest(o.Input)
-			if err != nil {
+			if err := o.Process(); err != nil {
 				return err
 			}
+			if o.ClusterSnapshot == "" {
+				return nil
+			}
 
-			c, err := converter.New()
+			content, err := os.ReadFile(o.ClusterSnapshot)
 			if err != nil {
 				return err
 			}
+			snapshot, err = parser.ParseManifest(bytes.NewReader(content))
+			return err
+		},
+		RunE: func(_ *cobra.Command, _ []string) error {
+			if o.FromAPI != "" {
+				return convertFromAPI(o)
+			}
+			if o.Batch() {
+				return convertFiles(o, snapshot)
+			}
+			defer func() { _ = o.Input.Close() }()
 
-			converted, err := c.Do(objects)
+			content, err := io.ReadAll(o.Input)
 			if err != nil {
 				return err
 			}
 
-			var fragments []string
-			for _, object := range converted {
-				data, err := c.EncodeYaml(object)
-				if err != nil {
-					return err
-				}
-				fragments = append(fragments, string(data))
+			r := report.New()
+			converted, _, err := convertContent(o, snapshot, nil, content, o.Ext(), r)
+			if err != nil {
+				return err
 			}
 
-			if _, err = o.Out.Write([]byte(strings.Join(fragments, separator+"\n"))); err != nil {
+			if _, err = o.Out.Write(converted); err != nil {
 				return err
 			}
-			return nil
+
+			_, err = r.WriteTo(os.Stderr)
+			return err
 		},
 	}
 	o.AddFlags(cmd.Flags())
+	o.AddAPIFlags(cmd.Flags())
+	o.AddManifestFlags(cmd.Flags())
+	o.AddBatchFlags(cmd.Flags())
 
 	return cmd
 }
+
+// convertFiles converts the files given to -f, writing the ones holding
+// Traefik resources to the output directory or back in place. A file failing
+// to convert doesn't stop the others, but makes the command fail.
+func convertFiles(o *options.ConvertOptions, snapshot []runtime.Object) error {
+	// the v3 definitions are only added to the first file needing them.
+	emittedCRDs := map[string]bool{}
+	var failed int
+	for _, file := range o.Files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		r := report.New()
+		converted, traefik, err := convertContent(o, snapshot, emittedCRDs, content, filepath.Ext(file), r)
+		if err != nil {
+			if mentionsTraefik(content) {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", file, err)
+				failed++
+			}
+			continue
+		}
+		if !traefik {
+			continue
+		}
+
+		path, err := o.OutputPath(file)
+		if err != nil {
+			return err
+		}
+		if !o.InPlace || !bytes.Equal(converted, content) {
+			if err := writeFile(file, path, converted); err != nil {
+				return err
+			}
+		}
+		if !bytes.Equal(converted, content) {
+			fmt.Fprintf(os.Stderr, "converted %s\n", path)
+		}
+
+		for _, e := range r.Entries() {
+			fmt.Fprintf(os.Stderr, "%s: %s: %s\n", file, e.Source, e.Message)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files could not be converted", failed, len(o.Files))
+	}
+	return nil
+}
+
+// mentionsTraefik reports whether content mentions Traefik at all. Files
+// which don't, such as Helm values or other manifests, aren't Traefik input.
+func mentionsTraefik(content []byte) bool {
+	return bytes.Contains(bytes.ToLower(content), []byte("traefik"))
+}
+
+// writeFile writes the converted content of file to path, keeping its mode.
+func writeFile(file, path string, content []byte) error {
+	stat, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, stat.Mode())
+}
+
+// convertContent converts content according to its kind, and reports whether
+// it holds Traefik resources at all. snapshot holds the objects of a cluster,
+// if any, to check the converted definitions against. emittedCRDs, if set,
+// records the v3 definitions written by the previous files of a batch.
+func convertContent(o *options.ConvertOptions, snapshot []runtime.Object, emittedCRDs map[string]bool, content []byte, ext string, r *report.Report) ([]byte, bool, error) {
+	if o.Reverse {
+		if _, ok := fileprovider.Detect(content, ext); ok || staticconfig.DetectV2(content, ext) || terraform.Detect(content, ext) || helm.Detect(content) {
+			return nil, false, errors.New("--reverse only converts Kubernetes manifests")
+		}
+	}
+
+	if o.V1 && staticconfig.DetectV1(content) {
+		converted, err := staticconfig.New(r).Convert(content)
+		return converted, true, err
+	}
+
+	if staticconfig.DetectV2(content, ext) {
+		converted, err := staticconfig.New(r).ConvertV2(content, ext)
+		return converted, true, err
+	}
+
+	if terraform.Detect(content, ext) {
+		converted, err := convertTerraform(content, r)
+		return converted, bytes.Contains(content, []byte("traefik.containo.us")), err
+	}
+
+	if helm.Detect(content) {
+		converted, err := convertTemplate(content, r)
+		return converted, true, err
+	}
+
+	if format, ok := fileprovider.Detect(content, ext); ok {
+		_, dynamic := fileprovider.Detect(content, "")
+		converted, err := convertDynamicConfiguration(content, format, r)
+		return converted, dynamic, err
+	}
+
+	objects, err := parser.ParseManifest(bytes.NewReader(content))
+	if err != nil {
+		return nil, false, err
+	}
+
+	c, err := converter.New(r)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := c.KeepServerFields(o.KeepServerFields...); err != nil {
+		return nil, false, err
+	}
+	if err := c.SetRBACMode(converter.RBACMode(o.RBACMode)); err != nil {
+		return nil, false, err
+	}
+	if err := c.SetCRDMode(converter.CRDMode(o.CRDMode)); err != nil {
+		return nil, false, err
+	}
+	c.SetClusterSnapshot(snapshot)
+	if emittedCRDs != nil {
+		c.SetEmittedCRDs(emittedCRDs)
+	}
+	if err := c.SetTarget(converter.Target(o.Target)); err != nil {
+		return nil, false, err
+	}
+	if err := c.SetGateway(o.Gateway); err != nil {
+		return nil, false, err
+	}
+	if err := c.SetSourceGroup(converter.SourceGroup(o.SourceGroup)); err != nil {
+		return nil, false, err
+	}
+	if o.IngressToIngressRoute {
+		c.ConvertIngresses()
+	}
+	if o.IngressNginx {
+		c.ConvertIngressNginx()
+	}
+	if o.V1 {
+		c.ConvertV1()
+	}
+	if o.Reverse {
+		if err := c.Reverse(); err != nil {
+			return nil, false, err
+		}
+	}
+
+	converted, err := c.Do(objects)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if converter.Target(o.Target) == converter.TargetFileProvider {
+		exported, err := exportFileProvider(o, converted, r)
+		return exported, converter.HasTraefikResources(objects), err
+	}
+
+	var fragments []string
+	for _, object := range converted {
+		data, err := c.EncodeYaml(object)
+		if err != nil {
+			return nil, false, err
+		}
+		fragments = append(fragments, string(data))
+	}
+
+	return []byte(strings.Join(fragments, separator+"\n")), converter.HasTraefikResources(objects), nil
+}
+
+// exportFileProvider exports the converted objects to a file provider
+// dynamic configuration, in TOML when the output file is a .toml one.
+func exportFileProvider(o *options.ConvertOptions, objects []runtime.Object, r *report.Report) ([]byte, error) {
+	endpoints := map[string][]string{}
+	if o.Endpoints != "" {
+		content, err := os.ReadFile(o.Endpoints)
+		if err != nil {
+			return nil, err
+		}
+		if endpoints, err = fileprovider.ReadEndpoints(content); err != nil {
+			return nil, err
+		}
+	}
+
+	config, err := fileprovider.NewExporter(endpoints, r).Export(objects)
+	if err != nil {
+		return nil, err
+	}
+
+	format := fileprovider.FormatYAML
+	if strings.EqualFold(o.OutputExt(), ".toml") {
+		format = fileprovider.FormatTOML
+	}
+	return fileprovider.Encode(config, format)
+}
+
+func convertDynamicConfiguration(content []byte, format fileprovider.Format, r *report.Report) ([]byte, error) {
+	c, err := fileprovider.New(r)
+	if err != nil {
+		return nil, err
+	}
+	return c.Convert(content, format)
+}
+
+func convertTerraform(content []byte, r *report.Report) ([]byte, error) {
+	c, err := terraform.New(r)
+	if err != nil {
+		return nil, err
+	}
+	return c.Convert(content)
+}
+
+func convertTemplate(content []byte, r *report.Report) ([]byte, error) {
+	c, err := helm.New(r)
+	if err != nil {
+		return nil, err
+	}
+	return c.Convert(content)
+}
+
+func convertFromAPI(o *options.ConvertOptions) error {
+	data, err := rawdata.Fetch(nil, o.FromAPI)
+	if err != nil {
+		return err
+	}
+
+	r := report.New()
+	config, inventory := rawdata.NewImporter(r).Import(data)
+
+	c, err := fileprovider.New(r)
+	if err != nil {
+		return err
+	}
+
+	v3Config, err := c.Transform(config)
+	if err != nil {
+		return err
+	}
+
+	format := fileprovider.FormatYAML
+	if strings.EqualFold(o.OutputExt(), ".toml") {
+		format = fileprovider.FormatTOML
+	}
+
+	converted, err := fileprovider.Encode(v3Config, format)
+	if err != nil {
+		return err
+	}
+
+	if _, err = o.Out.Write(converted); err != nil {
+		return err
+	}
+
+	if err := rawdata.WriteInventory(os.Stderr, inventory); err != nil {
+		return err
+	}
+	_, err = r.WriteTo(os.Stderr)
+	return err
+}
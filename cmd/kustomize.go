@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/databotic/traefik-migration-tool/cmd/options"
+	"github.com/databotic/traefik-migration-tool/internal/kustomize"
+	"github.com/databotic/traefik-migration-tool/internal/report"
+	"github.com/spf13/cobra"
+)
+
+func ConvertKustomize() *cobra.Command {
+	var dir, output string
+	var inPlace bool
+
+	cmd := &cobra.Command{
+		Use:   "convert-kustomize",
+		Short: "Convert the Traefik v2 resources and patches of a kustomization tree to v3",
+		Long: "Convert the Traefik v2 resources of a kustomization and of its bases, together with the " +
+			"patches applied to them, to v3",
+		PreRunE: func(_ *cobra.Command, _ []string) error {
+			if output == "" && !inPlace {
+				return errors.New("either an output directory or --in-place is required")
+			}
+			if output != "" && inPlace {
+				return errors.New("--output and --in-place are mutually exclusive")
+			}
+			return nil
+		},
+		RunE: func(_ *cobra.Command, _ []string) error {
+			r := report.New()
+			c, err := kustomize.New(r)
+			if err != nil {
+				return err
+			}
+
+			files, err := c.Convert(dir)
+			if err != nil {
+				return err
+			}
+
+			root, err := filepath.Abs(dir)
+			if err != nil {
+				return err
+			}
+			for _, file := range files {
+				root = options.CommonDir(root, filepath.Dir(file.Path))
+			}
+
+			for _, file := range files {
+				if inPlace && !file.Changed {
+					continue
+				}
+
+				path := file.Path
+				if !inPlace {
+					rel, err := filepath.Rel(root, file.Path)
+					if err != nil {
+						return err
+					}
+					path = filepath.Join(output, rel)
+					if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+						return err
+					}
+				}
+
+				stat, err := os.Stat(file.Path)
+				if err != nil {
+					return err
+				}
+				if err := os.WriteFile(path, file.Content, stat.Mode()); err != nil {
+					return err
+				}
+				if file.Changed {
+					fmt.Fprintf(os.Stderr, "converted %s\n", path)
+				}
+			}
+
+			_, err = r.WriteTo(os.Stderr)
+			return err
+		},
+	}
+	cmd.Flags().StringVarP(&dir, "file", "f", ".", "directory of the kustomization to convert.")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "directory the converted files are written to, mirroring the kustomization tree.")
+	cmd.Flags().BoolVar(&inPlace, "in-place", false, "overwrite the converted files.")
+
+	return cmd
+}
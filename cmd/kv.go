@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/databotic/traefik-migration-tool/cmd/options"
+	"github.com/databotic/traefik-migration-tool/internal/converter"
+	"github.com/databotic/traefik-migration-tool/internal/kv"
+	"github.com/databotic/traefik-migration-tool/internal/labels"
+	"github.com/databotic/traefik-migration-tool/internal/report"
+	"github.com/spf13/cobra"
+)
+
+func ConvertKV() *cobra.Command {
+	o := options.NewConvertOptions()
+	rootKey := labels.DefaultPrefix
+
+	cmd := &cobra.Command{
+		Use:   "convert-kv",
+		Short: "Convert a Traefik v2 KV store export to a v3 change set",
+		Long: "Convert a Traefik v2 KV store export (etcd, Consul, Redis or ZooKeeper), given as JSON or key=value lines, " +
+			"to the change set of keys to put and to delete for v3",
+		PreRunE: func(_ *cobra.Command, _ []string) error {
+			return o.Process()
+		},
+		RunE: func(_ *cobra.Command, _ []string) error {
+			defer func() { _ = o.Input.Close() }()
+
+			content, err := io.ReadAll(o.Input)
+			if err != nil {
+				return err
+			}
+
+			entries, err := kv.Parse(content)
+			if err != nil {
+				return err
+			}
+
+			ingressRoute, err := converter.NewIngressRoute()
+			if err != nil {
+				return err
+			}
+			tcpRule, err := converter.NewTCPRule()
+			if err != nil {
+				return err
+			}
+
+			rewriter := labels.New(ingressRoute)
+			rewriter.Prefix = rootKey
+			rewriter.TCPRules = tcpRule
+
+			r := report.New()
+			changes, err := kv.New(rewriter, r).Convert(entries)
+			if err != nil {
+				return err
+			}
+
+			encoder := json.NewEncoder(o.Out)
+			encoder.SetEscapeHTML(false)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(changes); err != nil {
+				return err
+			}
+
+			_, err = r.WriteTo(os.Stderr)
+			return err
+		},
+	}
+	o.AddFlags(cmd.Flags())
+	cmd.Flags().StringVar(&rootKey, "root-key", labels.DefaultPrefix, "root key of the Traefik configuration, as configured in the provider")
+
+	return cmd
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/databotic/traefik-migration-tool/cmd/options"
+	"github.com/databotic/traefik-migration-tool/internal/converter"
+	"github.com/databotic/traefik-migration-tool/internal/fileprovider"
+	"github.com/databotic/traefik-migration-tool/internal/labels"
+	"github.com/databotic/traefik-migration-tool/internal/marathon"
+	"github.com/databotic/traefik-migration-tool/internal/report"
+	"github.com/spf13/cobra"
+)
+
+func ConvertMarathon() *cobra.Command {
+	o := options.NewConvertOptions()
+	target := string(marathon.TargetFileProvider)
+	exposedByDefault := true
+
+	cmd := &cobra.Command{
+		Use:   "convert-marathon",
+		Short: "Convert Traefik v2 labels of Marathon applications to a file provider configuration or Docker labels",
+		Long: "Convert the Traefik v2 labels of Marathon application definitions, whose provider was removed in v3, " +
+			"to a file provider dynamic configuration or to docker-compose services labelled for the v3 Docker provider",
+		PreRunE: func(_ *cobra.Command, _ []string) error {
+			switch marathon.Target(target) {
+			case marathon.TargetFileProvider, marathon.TargetDocker:
+			default:
+				return fmt.Errorf("unknown target %s, expected %s or %s", target, marathon.TargetFileProvider, marathon.TargetDocker)
+			}
+			return o.Process()
+		},
+		RunE: func(_ *cobra.Command, _ []string) error {
+			defer func() { _ = o.Input.Close() }()
+
+			src, err := io.ReadAll(o.Input)
+			if err != nil {
+				return err
+			}
+
+			ingressRoute, err := converter.NewIngressRoute()
+			if err != nil {
+				return err
+			}
+			tcpRules, err := converter.NewTCPRule()
+			if err != nil {
+				return err
+			}
+			rewriter := labels.New(ingressRoute)
+			rewriter.TCPRules = tcpRules
+
+			r := report.New()
+			c := marathon.New(rewriter, r)
+			c.ExposedByDefault = exposedByDefault
+
+			var converted []byte
+			if marathon.Target(target) == marathon.TargetDocker {
+				if converted, err = c.Compose(src); err != nil {
+					return err
+				}
+			} else {
+				config, err := c.FileProvider(src)
+				if err != nil {
+					return err
+				}
+				format := fileprovider.FormatYAML
+				if strings.EqualFold(o.OutputExt(), ".toml") {
+					format = fileprovider.FormatTOML
+				}
+				if converted, err = fileprovider.Encode(config, format); err != nil {
+					return err
+				}
+			}
+
+			if _, err = o.Out.Write(converted); err != nil {
+				return err
+			}
+
+			_, err = r.WriteTo(os.Stderr)
+			return err
+		},
+	}
+	o.AddFlags(cmd.Flags())
+	cmd.Flags().StringVar(&target, "target", target, "what the applications are converted to: "+
+		"file-provider produces a dynamic configuration for the file provider, docker produces docker-compose services with v3 labels.")
+	cmd.Flags().BoolVar(&exposedByDefault, "exposed-by-default", true, "convert the applications without a traefik.enable label, "+
+		"as the exposedByDefault option of the Marathon provider did.")
+
+	return cmd
+}
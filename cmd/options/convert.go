@@ -2,17 +2,87 @@ package options
 
 import (
 	"errors"
+	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/spf13/pflag"
 )
 
+// batchExtensions are the extensions of the files converted when walking
+// directories.
+var batchExtensions = []string{".yaml", ".yml", ".json", ".toml", ".tf"}
+
 type ConvertOptions struct {
-	file     string
+	files    []string
 	output   string
 	fileName string
 
+	// batch is set when the command converts several files at once.
+	batch bool
+
+	// FromAPI is the URL of a running Traefik v2 instance to import the
+	// configuration from, instead of reading a file.
+	FromAPI string
+
+	// KeepServerFields are the server populated fields kept in the
+	// converted objects.
+	KeepServerFields []string
+
+	// RBACMode selects whether the roles granting access to
+	// traefik.containo.us grant traefik.io as well or instead.
+	RBACMode string
+
+	// CRDMode selects whether the v3 CustomResourceDefinitions replace the
+	// v2 ones or are added next to them.
+	CRDMode string
+
+	// IngressToIngressRoute generates IngressRoutes from the Ingress
+	// objects.
+	IngressToIngressRoute bool
+
+	// IngressNginx generates IngressRoutes and Middlewares from the Ingress
+	// objects handled by ingress-nginx.
+	IngressNginx bool
+
+	// Target is the kind of objects the routing configuration is converted
+	// to, and Gateway the namespace/name of the Gateway the generated routes
+	// are attached to.
+	Target  string
+	Gateway string
+
+	// Endpoints is a file holding the servers of the Kubernetes services
+	// referenced by the routes exported by --target file-provider.
+	Endpoints string
+
+	// SourceGroup selects which objects hold a v2 configuration: auto
+	// detects the traefik.io objects written with v2 semantics.
+	SourceGroup string
+
+	// Reverse converts the traefik.io objects back to traefik.containo.us
+	// ones.
+	Reverse bool
+
+	// V1 reads the input as a Traefik 1.7 configuration: Ingress objects
+	// annotated for 1.7 and traefik.toml static configurations.
+	V1 bool
+
+	// ClusterSnapshot is a dump of the objects stored in a cluster, used to
+	// report the v2 definitions which still have objects.
+	ClusterSnapshot string
+
+	// InPlace rewrites the converted files instead of writing them to the
+	// output directory.
+	InPlace bool
+
+	// Files are the files to convert in batch mode, and Root the directory
+	// their layout is mirrored from.
+	Files []string
+	Root  string
+
 	Input *os.File
 	Out   *os.File
 }
@@ -23,37 +93,126 @@ func NewConvertOptions() *ConvertOptions {
 }
 
 func (o *ConvertOptions) AddFlags(fs *pflag.FlagSet) {
-	fs.StringVarP(&o.file, "file", "f", "-", "filename or path to the resource to be converted.")
+	fs.StringSliceVarP(&o.files, "file", "f", []string{"-"}, "filename or path to the resource to be converted.")
 	fs.StringVarP(&o.output, "output", "o", "-", "output file")
 }
 
+// AddBatchFlags allows several files, directories and glob patterns to be
+// given to -f, and adds the flags for converting them.
+func (o *ConvertOptions) AddBatchFlags(fs *pflag.FlagSet) {
+	o.batch = true
+	fs.BoolVar(&o.InPlace, "in-place", false, "rewrite the converted files in place.")
+}
+
+// Ext returns the extension of the input file, if any.
+func (o *ConvertOptions) Ext() string {
+	return filepath.Ext(o.fileName)
+}
+
+// AddAPIFlags adds the flags for importing the configuration of a running instance.
+func (o *ConvertOptions) AddAPIFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.FromAPI, "from-api", "", "URL of a running Traefik v2 instance to import the configuration from its /api/rawdata endpoint.")
+}
+
+// AddManifestFlags adds the flags for converting Kubernetes manifests.
+func (o *ConvertOptions) AddManifestFlags(fs *pflag.FlagSet) {
+	fs.StringSliceVar(&o.KeepServerFields, "keep-server-fields", nil, "server populated fields to keep in the converted objects, "+
+		"among creationTimestamp, generation, managedFields, resourceVersion, selfLink, status and uid.")
+	fs.StringVar(&o.RBACMode, "rbac", "transition", "how roles granting access to traefik.containo.us are rewritten: "+
+		"transition grants both traefik.containo.us and traefik.io, final grants traefik.io only.")
+	fs.StringVar(&o.CRDMode, "crds", "augment", "how Traefik CustomResourceDefinitions are converted: "+
+		"augment adds the v3 definitions next to the v2 ones, replace removes the v2 ones.")
+	fs.BoolVar(&o.IngressToIngressRoute, "ingress-to-ingressroute", false, "generate IngressRoutes from the Ingress objects handled by Traefik.")
+	fs.BoolVar(&o.IngressNginx, "ingress-nginx", false, "generate IngressRoutes and Middlewares from the Ingress objects handled by ingress-nginx, "+
+		"reporting the nginx annotations with no Traefik equivalent.")
+	fs.StringVar(&o.Target, "target", "crd", "what the IngressRoutes are converted to: "+
+		"crd produces traefik.io IngressRoutes, gateway-api produces HTTPRoutes, TCPRoutes and TLSRoutes, "+
+		"file-provider produces a dynamic configuration for the file provider.")
+	fs.StringVar(&o.Gateway, "gateway", "traefik/traefik-gateway", "namespace/name of the Gateway the routes generated by --target gateway-api are attached to.")
+	fs.StringVar(&o.Endpoints, "endpoints", "", "YAML file mapping the Kubernetes services, as namespace/name:port or namespace/name, "+
+		"to the URLs of their servers for --target file-provider.")
+	fs.StringVar(&o.SourceGroup, "source-group", "auto", "which objects hold a v2 configuration: "+
+		"auto converts the traefik.containo.us objects and the traefik.io ones written with v2 rules or middleware options, "+
+		"traefik.containo.us converts these only, traefik.io converts the traefik.io routes and middlewares of Traefik 2.10 and 2.11 as well, except the routes only valid in v3.")
+	fs.BoolVar(&o.Reverse, "reverse", false, "convert the traefik.io objects back to traefik.containo.us ones, to roll back a v3 migration.")
+	fs.BoolVar(&o.V1, "v1", false, "convert a Traefik 1.7 configuration: Ingress objects annotated for 1.7 "+
+		"are converted to IngressRoutes and Middlewares, traefik.toml to a v3 static configuration.")
+	fs.StringVar(&o.ClusterSnapshot, "cluster-snapshot", "", "objects dumped from a cluster with kubectl get -o yaml, "+
+		"used to report the v2 definitions which still have stored objects.")
+}
+
+// OutputExt returns the extension of the output file, if any.
+func (o *ConvertOptions) OutputExt() string {
+	if o.output == "-" {
+		return ""
+	}
+	return filepath.Ext(o.output)
+}
+
+// Batch reports whether several files are converted at once.
+func (o *ConvertOptions) Batch() bool {
+	return o.Files != nil
+}
+
+// OutputPath returns the path the converted content of file is written to.
+func (o *ConvertOptions) OutputPath(file string) (string, error) {
+	if o.InPlace {
+		return file, nil
+	}
+
+	rel, err := filepath.Rel(o.Root, file)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(o.output, rel), nil
+}
+
 func (o *ConvertOptions) Process() error {
-	if o.file != "-" {
-		stat, err := os.Stat(o.file)
+	if o.Reverse && (o.FromAPI != "" || o.IngressToIngressRoute || o.IngressNginx || (o.Target != "" && o.Target != "crd")) {
+		return errors.New("--reverse can't be combined with --from-api, --ingress-to-ingressroute, --ingress-nginx or --target")
+	}
+	if o.V1 && (o.Reverse || o.FromAPI != "" || o.IngressToIngressRoute || o.IngressNginx || (o.Target != "" && o.Target != "crd")) {
+		return errors.New("--v1 can't be combined with --reverse, --from-api, --ingress-to-ingressroute, --ingress-nginx or --target")
+	}
+	if o.Endpoints != "" && o.Target != "file-provider" {
+		return errors.New("--endpoints requires --target file-provider")
+	}
+
+	if o.FromAPI != "" {
+		o.Input = nil
+	} else if o.batch && o.isBatch() {
+		return o.processBatch()
+	} else if len(o.files) != 1 {
+		return errors.New("a single file is expected")
+	} else if file := o.files[0]; file != "-" {
+		stat, err := os.Stat(file)
 		if err != nil {
 			return err
 		}
 
-		file, err := os.OpenFile(o.file, os.O_RDONLY, stat.Mode())
+		input, err := os.OpenFile(file, os.O_RDONLY, stat.Mode())
 		if err != nil {
 			return err
 		}
 
 		o.fileName = stat.Name()
-		o.Input = file
+		o.Input = input
 	} else {
 		o.Input = os.Stdin
 	}
 
 	if o.output != "-" {
 		stat, err := os.Stat(o.output)
-		if err != nil {
-			if !errors.Is(err, os.ErrNotExist) {
-				return err
-			}
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
 		}
 
-		if stat.IsDir() {
+		if stat == nil && strings.HasSuffix(o.output, string(filepath.Separator)) {
+			if err := os.MkdirAll(o.output, 0o755); err != nil {
+				return err
+			}
+			o.output = filepath.Join(o.output, o.fileName)
+		} else if stat != nil && stat.IsDir() {
 			o.output = filepath.Join(o.output, o.fileName)
 		}
 
@@ -68,3 +227,118 @@ func (o *ConvertOptions) Process() error {
 
 	return nil
 }
+
+// isBatch reports whether the inputs are several files, directories or
+// glob patterns.
+func (o *ConvertOptions) isBatch() bool {
+	if len(o.files) > 1 || o.InPlace {
+		return true
+	}
+	if file := o.files[0]; file != "-" {
+		if stat, err := os.Stat(file); err == nil {
+			return stat.IsDir()
+		}
+		return strings.ContainsAny(file, "*?[")
+	}
+	return false
+}
+
+func (o *ConvertOptions) processBatch() error {
+	if o.InPlace == (o.output != "-") {
+		return errors.New("converting several files requires either an output directory or --in-place")
+	}
+
+	seen := map[string]bool{}
+	var roots []string
+	for _, pattern := range o.files {
+		if pattern == "-" {
+			return errors.New("stdin can't be converted together with other files")
+		}
+
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return err
+		}
+		if matches == nil {
+			return fmt.Errorf("no file matches %s", pattern)
+		}
+
+		for _, match := range matches {
+			files, root, err := walkInput(match)
+			if err != nil {
+				return err
+			}
+			roots = append(roots, root)
+			for _, file := range files {
+				if !seen[file] {
+					seen[file] = true
+					o.Files = append(o.Files, file)
+				}
+			}
+		}
+	}
+	sort.Strings(o.Files)
+	if o.Files == nil {
+		o.Files = []string{}
+	}
+
+	o.Root = roots[0]
+	for _, root := range roots[1:] {
+		o.Root = CommonDir(o.Root, root)
+	}
+
+	if !o.InPlace {
+		return os.MkdirAll(o.output, 0o755)
+	}
+	return nil
+}
+
+// walkInput returns the files to convert for an input, with the directory
+// its layout is mirrored from.
+func walkInput(path string) ([]string, string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, "", err
+	}
+	if !stat.IsDir() {
+		return []string{path}, filepath.Dir(path), nil
+	}
+
+	var files []string
+	err = filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if file != path && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		for _, ext := range batchExtensions {
+			if strings.EqualFold(filepath.Ext(file), ext) {
+				files = append(files, file)
+				break
+			}
+		}
+		return nil
+	})
+
+	return files, path, err
+}
+
+// CommonDir returns the deepest directory holding both a and b.
+func CommonDir(a, b string) string {
+	for a != filepath.Dir(a) {
+		if b == a || strings.HasPrefix(b, a+string(filepath.Separator)) {
+			return a
+		}
+		a = filepath.Dir(a)
+	}
+	return a
+}
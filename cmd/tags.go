@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"io"
+	"os"
+
+	"github.com/databotic/traefik-migration-tool/cmd/options"
+	"github.com/databotic/traefik-migration-tool/internal/converter"
+	"github.com/databotic/traefik-migration-tool/internal/labels"
+	"github.com/databotic/traefik-migration-tool/internal/report"
+	"github.com/databotic/traefik-migration-tool/internal/tags"
+	"github.com/spf13/cobra"
+)
+
+func ConvertTags() *cobra.Command {
+	o := options.NewConvertOptions()
+	prefix := labels.DefaultPrefix
+
+	cmd := &cobra.Command{
+		Use:   "convert-tags",
+		Short: "Convert Traefik v2 tags of Consul, Nomad and ECS definitions to v3",
+		Long: "Convert the Traefik v2 tags and labels of Consul service definitions, Nomad jobs " +
+			"and ECS task definitions, written in JSON or HCL, to v3",
+		PreRunE: func(_ *cobra.Command, _ []string) error {
+			return o.Process()
+		},
+		RunE: func(_ *cobra.Command, _ []string) error {
+			defer func() { _ = o.Input.Close() }()
+
+			src, err := io.ReadAll(o.Input)
+			if err != nil {
+				return err
+			}
+
+			ingressRoute, err := converter.NewIngressRoute()
+			if err != nil {
+				return err
+			}
+			tcpRule, err := converter.NewTCPRule()
+			if err != nil {
+				return err
+			}
+
+			rewriter := labels.New(ingressRoute)
+			rewriter.Prefix = prefix
+			rewriter.TCPRules = tcpRule
+
+			r := report.New()
+			converted, err := tags.New(rewriter, r).Convert(src, tags.DetectFormat(src, o.Ext()))
+			if err != nil {
+				return err
+			}
+
+			if _, err = o.Out.Write(converted); err != nil {
+				return err
+			}
+
+			_, err = r.WriteTo(os.Stderr)
+			return err
+		},
+	}
+	o.AddFlags(cmd.Flags())
+	cmd.Flags().StringVar(&prefix, "prefix", labels.DefaultPrefix, "prefix of the Traefik tags, as configured in the provider")
+
+	return cmd
+}
@@ -0,0 +1,186 @@
+package compose
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/databotic/traefik-migration-tool/internal/labels"
+	"github.com/databotic/traefik-migration-tool/internal/report"
+	"github.com/databotic/traefik-migration-tool/internal/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// swarmOptions are the docker provider options which moved to the swarm
+// provider namespace in v3.
+var swarmOptions = []string{"network", "lbswarm"}
+
+// Converter rewrites the Traefik labels of a docker-compose file in place,
+// leaving comments and formatting untouched.
+type Converter struct {
+	labels *labels.Rewriter
+	report *report.Report
+
+	swarmServices map[string]bool
+}
+
+func New(rewriter *labels.Rewriter, r *report.Report) *Converter {
+	return &Converter{labels: rewriter, report: r}
+}
+
+func (c *Converter) Convert(src []byte) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(src, &doc); err != nil {
+		return nil, fmt.Errorf("error parsing compose file: %v", err)
+	}
+	if len(doc.Content) == 0 {
+		return src, nil
+	}
+
+	services := utils.YAMLMappingValue(doc.Content[0], "services")
+	if services == nil || services.Kind != yaml.MappingNode {
+		return src, nil
+	}
+
+	c.swarmServices = map[string]bool{}
+
+	var edits []utils.TextEdit
+	for i := 0; i+1 < len(services.Content); i += 2 {
+		name, service := services.Content[i].Value, utils.ResolveYAMLAlias(services.Content[i+1])
+
+		e, err := c.convertLabels(src, name, utils.YAMLMappingValue(service, "labels"), false)
+		if err != nil {
+			return nil, err
+		}
+		edits = append(edits, e...)
+
+		deploy := utils.YAMLMappingValue(service, "deploy")
+		e, err = c.convertLabels(src, name, utils.YAMLMappingValue(deploy, "labels"), true)
+		if err != nil {
+			return nil, err
+		}
+		edits = append(edits, e...)
+
+		if c.swarmServices[name] {
+			c.report.Add("service "+name, "deploy.labels are read by the swarm provider in v3, "+
+				"providers.swarm has to be enabled in the static configuration")
+		}
+	}
+
+	return utils.ApplyEdits(src, edits)
+}
+
+func (c *Converter) convertLabels(src []byte, service string, node *yaml.Node, swarm bool) ([]utils.TextEdit, error) {
+	node = utils.ResolveYAMLAlias(node)
+	if node == nil {
+		return nil, nil
+	}
+
+	var edits []utils.TextEdit
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], utils.ResolveYAMLAlias(node.Content[i+1])
+			if key.Value == "<<" {
+				e, err := c.convertMerge(src, service, value, swarm)
+				if err != nil {
+					return nil, err
+				}
+				edits = append(edits, e...)
+				continue
+			}
+			if value.Kind != yaml.ScalarNode {
+				continue
+			}
+
+			newKey, newValue, err := c.rewrite(service, key.Value, value.Value, swarm)
+			if err != nil {
+				return nil, err
+			}
+			if newKey != key.Value {
+				e, err := utils.YAMLScalarEdit(src, key, newKey)
+				if err != nil {
+					return nil, err
+				}
+				edits = append(edits, e)
+			}
+			if newValue != value.Value {
+				e, err := utils.YAMLScalarEdit(src, value, newValue)
+				if err != nil {
+					return nil, err
+				}
+				edits = append(edits, e)
+			}
+		}
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			item = utils.ResolveYAMLAlias(item)
+			if item.Kind != yaml.ScalarNode {
+				continue
+			}
+			key, value, _ := strings.Cut(item.Value, "=")
+
+			newKey, newValue, err := c.rewrite(service, key, value, swarm)
+			if err != nil {
+				return nil, err
+			}
+			if newKey != key || newValue != value {
+				e, err := utils.YAMLScalarEdit(src, item, newKey+"="+newValue)
+				if err != nil {
+					return nil, err
+				}
+				edits = append(edits, e)
+			}
+		}
+	}
+
+	return edits, nil
+}
+
+func (c *Converter) convertMerge(src []byte, service string, node *yaml.Node, swarm bool) ([]utils.TextEdit, error) {
+	if node.Kind != yaml.SequenceNode {
+		return c.convertLabels(src, service, node, swarm)
+	}
+
+	var edits []utils.TextEdit
+	for _, n := range node.Content {
+		e, err := c.convertLabels(src, service, n, swarm)
+		if err != nil {
+			return nil, err
+		}
+		edits = append(edits, e...)
+	}
+	return edits, nil
+}
+
+func (c *Converter) rewrite(service, key, value string, swarm bool) (string, string, error) {
+	newKey, newValue, err := c.labels.Rewrite(key, value)
+	if err != nil {
+		return "", "", fmt.Errorf("service %s: %w", service, err)
+	}
+
+	if msg := c.labels.Deprecated(key); msg != "" {
+		c.report.Add("service "+service, "label %s: %s and should be fixed manually", key, msg)
+	}
+
+	if swarm && c.labels.IsTraefikKey(key) {
+		c.swarmServices[service] = true
+		newKey = swarmKey(newKey)
+	}
+	return newKey, newValue, nil
+}
+
+// swarmKey moves the swarm specific docker provider labels to the v3 swarm
+// provider namespace.
+func swarmKey(key string) string {
+	parts := strings.Split(key, ".")
+	if len(parts) != 3 || !strings.EqualFold(parts[0], labels.DefaultPrefix) || !strings.EqualFold(parts[1], "docker") {
+		return key
+	}
+
+	for _, opt := range swarmOptions {
+		if strings.EqualFold(parts[2], opt) {
+			return parts[0] + ".swarm." + parts[2]
+		}
+	}
+	return key
+}
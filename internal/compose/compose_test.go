@@ -0,0 +1,49 @@
+package compose
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/databotic/traefik-migration-tool/internal/converter"
+	"github.com/databotic/traefik-migration-tool/internal/labels"
+	"github.com/databotic/traefik-migration-tool/internal/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var updateExpected = flag.Bool("update_expected", false, "Update expected files in testdata")
+
+func TestConvert(t *testing.T) {
+	testCases := []string{
+		"compose.yaml",
+	}
+
+	for _, test := range testCases {
+		t.Run(test, func(t *testing.T) {
+			src, err := os.ReadFile(filepath.Join("fixtures", "input", test))
+			require.NoError(t, err)
+
+			ingressRoute, err := converter.NewIngressRoute()
+			require.NoError(t, err)
+			tcpRule, err := converter.NewTCPRule()
+			require.NoError(t, err)
+
+			rewriter := labels.New(ingressRoute)
+			rewriter.TCPRules = tcpRule
+
+			converted, err := New(rewriter, report.New()).Convert(src)
+			require.NoError(t, err)
+
+			fixtureFile := filepath.Join("fixtures", "output", test)
+			if *updateExpected {
+				require.NoError(t, os.WriteFile(fixtureFile, converted, 0o666))
+			}
+
+			expected, err := os.ReadFile(fixtureFile)
+			require.NoError(t, err)
+			assert.Equal(t, string(expected), string(converted))
+		})
+	}
+}
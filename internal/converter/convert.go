@@ -1,9 +1,14 @@
 package converter
 
 import (
+	"bytes"
 	"regexp"
+	"strings"
 
+	"github.com/databotic/traefik-migration-tool/internal/parser"
+	"github.com/databotic/traefik-migration-tool/internal/report"
 	"github.com/databotic/traefik-migration-tool/internal/utils"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes/scheme"
 )
@@ -13,51 +18,192 @@ var (
 	regexPattern = regexp.MustCompile(`[*+?()|{}[\]\\]`)
 )
 
+var middlewareKinds = []string{
+	"Middleware.traefik.containo.us",
+	"Middleware.traefik.io",
+}
+
 type ConvertFactory interface {
 	Transform(object runtime.Object) (runtime.Object, error)
 }
 
 type Converter struct {
-	converters map[string]ConvertFactory
+	converters    map[string]ConvertFactory
+	ingressRoutes *IngressRoute
+	tcpRules      *TCPRule
+	sourceGroup   SourceGroup
+	middlewares   *middlewareIndex
+	rbac          *RBAC
+	crds          *crds
+	gateway       *GatewayAPI
+	ingressesV1   *IngressV1
+	reverse       bool
+	references    []ReferenceRewriter
+	report        *report.Report
+	keep          map[string]bool
 }
 
-func New() (*Converter, error) {
+func New(r *report.Report) (*Converter, error) {
 	IngressRoute, err := NewIngressRoute()
 	if err != nil {
 		return nil, err
 	}
 
+	tcpRules, err := NewTCPRule()
+	if err != nil {
+		return nil, err
+	}
+
+	middlewares := newMiddlewareIndex()
+	rbac := NewRBAC()
+
 	converters := map[string]ConvertFactory{
-		"IngressRoute.traefik.containo.us": IngressRoute,
-		"Middleware.traefik.containo.us":   NewMiddleWare(),
+		"IngressRoute.traefik.containo.us":      IngressRoute,
+		"Middleware.traefik.containo.us":        NewMiddleWare(r),
+		"Ingress.networking.k8s.io":             NewIngress(middlewares, r),
+		"Service":                               NewService(r),
+		"ClusterRole.rbac.authorization.k8s.io": rbac,
+		"Role.rbac.authorization.k8s.io":        rbac,
+	}
+	gatewayUpgrade := NewGatewayUpgrade(r)
+	for _, kind := range gatewayUpgradeKinds {
+		converters[kind+"."+gatewayGroup] = gatewayUpgrade
 	}
 
-	return &Converter{converters: converters}, nil
+	return &Converter{
+		converters:    converters,
+		ingressRoutes: IngressRoute,
+		tcpRules:      tcpRules,
+		sourceGroup:   SourceGroupAuto,
+		middlewares:   middlewares,
+		rbac:          rbac,
+		crds:          newCRDs(r),
+		references:    DefaultReferenceRewriters(),
+		report:        r,
+		keep:          map[string]bool{},
+	}, nil
 }
 
 func (c *Converter) Do(objects []runtime.Object) ([]runtime.Object, error) {
 	var converted []runtime.Object
 
+	if !c.reverse {
+		var err error
+		if objects, err = c.v2Objects(objects); err != nil {
+			return nil, err
+		}
+	}
+
+	c.indexMiddlewares(objects)
+	if c.gateway != nil {
+		c.gateway.indexMiddlewares(objects)
+	}
+	if c.ingressesV1 != nil {
+		c.ingressesV1.indexServices(objects)
+	}
+
 	for _, o := range objects {
-		gvk := o.GetObjectKind()
-		
-		gk := gvk.GroupVersionKind().GroupKind().String()
+		gk := o.GetObjectKind().GroupVersionKind().GroupKind().String()
+
+		if _, ok := o.(*parser.Unknown); ok && c.converters[gk] == nil {
+			// objects of unknown kinds are written back as they were read,
+			// unless they reference Traefik resources or hold server
+			// populated fields.
+			object, err := c.rewriteReferences(o.DeepCopyObject())
+			if err != nil {
+				return converted, err
+			}
+			if err := c.stripServerFields(object); err != nil {
+				return converted, err
+			}
+			converted = append(converted, object)
+			continue
+		}
+
+		if converter, ok := c.converters[gk].(MultiConvertFactory); ok {
+			objects, err := converter.TransformAll(o)
+			if err != nil {
+				return converted, err
+			}
+			if objects != nil {
+				converted = append(converted, objects...)
+				continue
+			}
+		}
+
+		object := o.DeepCopyObject()
 		if converter, ok := c.converters[gk]; ok {
-			object, err := converter.Transform(o)
+			var err error
+			object, err = converter.Transform(o)
 			if err != nil {
 				return converted, err
 			}
+		}
 
-			converted = append(converted, object)
+		object, err := c.rewriteReferences(object)
+		if err != nil {
+			return converted, err
+		}
+
+		if err := c.stripServerFields(object); err != nil {
+			return converted, err
+		}
+		converted = append(converted, object)
+	}
+
+	if c.reverse {
+		return converted, nil
+	}
+	return c.crds.convert(converted)
+}
+
+// HasTraefikResources reports whether objects hold Traefik resources or
+// objects annotated for Traefik.
+func HasTraefikResources(objects []runtime.Object) bool {
+	for _, o := range objects {
+		switch o.GetObjectKind().GroupVersionKind().Group {
+		case containousGroup, traefikGroup:
+			return true
+		}
+		if _, _, ok := traefikCRD(o); ok || grantsContainous(o) || referencesTraefik(o) || outdatedGatewayObject(o) {
+			return true
+		}
+
+		accessor, err := meta.Accessor(o)
+		if err != nil {
 			continue
 		}
-		converted = append(converted, o)
+		for key := range accessor.GetAnnotations() {
+			if strings.Contains(key, "traefik.") {
+				return true
+			}
+		}
 	}
+	return false
+}
 
-	return converted, nil
+func (c *Converter) indexMiddlewares(objects []runtime.Object) {
+	for _, o := range objects {
+		gk := o.GetObjectKind().GroupVersionKind().GroupKind().String()
+		for _, kind := range middlewareKinds {
+			if gk != kind {
+				continue
+			}
+			if accessor, err := meta.Accessor(o); err == nil {
+				c.middlewares.add(accessor.GetNamespace(), accessor.GetName())
+			}
+		}
+	}
 }
 
 func (c *Converter) EncodeYaml(object runtime.Object) ([]byte, error) {
+	if u, ok := object.(*parser.Unknown); ok {
+		if !bytes.HasSuffix(u.Source, []byte("\n")) {
+			return append(bytes.Clone(u.Source), '\n'), nil
+		}
+		return u.Source, nil
+	}
+
 	encoder := scheme.Codecs.EncoderForVersion(
 		utils.YAMLCodec{}, object.GetObjectKind().GroupVersionKind().GroupVersion(),
 	)
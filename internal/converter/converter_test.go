@@ -1,6 +1,7 @@
 package converter
 
 import (
+	"bytes"
 	"flag"
 	"os"
 	"path/filepath"
@@ -8,14 +9,23 @@ import (
 	"testing"
 
 	"github.com/databotic/traefik-migration-tool/internal/parser"
+	"github.com/databotic/traefik-migration-tool/internal/report"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
 )
 
 var updateExpected = flag.Bool("update_expected", false, "Update expected files in testdata")
 
 type TestStruct struct {
 	ingressRouteFile string
+	rbacMode         RBACMode
+	ingressRoutes    bool
+	target           Target
+	reverse          bool
+	v1               bool
+	ingressNginx     bool
+	sourceGroup      SourceGroup
 }
 
 func testFile(c TestStruct, t *testing.T) {
@@ -27,12 +37,34 @@ func testFile(c TestStruct, t *testing.T) {
 
 	file, err := os.OpenFile(inputFile, os.O_RDONLY, stat.Mode())
 	require.NoError(t, err)
+	defer func() { _ = file.Close() }()
 
 	objects, err := parser.ParseManifest(file)
 	require.NoError(t, err)
 
-	converter, err := New()
+	converter, err := New(report.New())
 	require.NoError(t, err)
+	if c.rbacMode != "" {
+		require.NoError(t, converter.SetRBACMode(c.rbacMode))
+	}
+	if c.ingressRoutes {
+		converter.ConvertIngresses()
+	}
+	if c.target != "" {
+		require.NoError(t, converter.SetTarget(c.target))
+	}
+	if c.v1 {
+		converter.ConvertV1()
+	}
+	if c.ingressNginx {
+		converter.ConvertIngressNginx()
+	}
+	if c.sourceGroup != "" {
+		require.NoError(t, converter.SetSourceGroup(c.sourceGroup))
+	}
+	if c.reverse {
+		require.NoError(t, converter.Reverse())
+	}
 
 	converted, err := converter.Do(objects)
 	require.NoError(t, err)
@@ -54,7 +86,12 @@ func testFile(c TestStruct, t *testing.T) {
 
 	yml, err := os.ReadFile(fixtureFile)
 	require.NoError(t, err)
-	assert.YAMLEq(t, string(yml), string(data))
+	expected := strings.Split(string(yml), "---\n")
+	actual := strings.Split(data, "---\n")
+	require.Len(t, actual, len(expected))
+	for i := range expected {
+		assert.YAMLEq(t, expected[i], actual[i])
+	}
 }
 
 func TestIngressRoutes(t *testing.T) {
@@ -103,3 +140,609 @@ func TestMiddleWares(t *testing.T) {
 		})
 	}
 }
+
+func TestIngresses(t *testing.T) {
+	testCases := []TestStruct{
+		{
+			ingressRouteFile: "ingress_annotations.yaml",
+		},
+		{
+			ingressRouteFile: "ingress_templated_path.yaml",
+		},
+	}
+	for _, test := range testCases {
+		t.Run(test.ingressRouteFile, func(t *testing.T) {
+			testFile(test, t)
+		})
+	}
+}
+
+func TestIngressesToIngressRoutes(t *testing.T) {
+	testFile(TestStruct{ingressRouteFile: "ingress_to_ingressroute.yaml", ingressRoutes: true}, t)
+}
+
+func TestMiddlewareRefs(t *testing.T) {
+	middlewares := newMiddlewareIndex()
+	middlewares.add("a-b", "c")
+	middlewares.add("a", "b-c")
+	middlewares.add("apps", "office")
+
+	assert.Equal(t, []string{"a-b/c", "a/b-c"}, middlewares.lookup("a-b-c"))
+	assert.Equal(t, []string{"apps/office"}, middlewares.lookup("apps-office"))
+
+	r := report.New()
+	refs := middlewares.checkRefs("Ingress apps/shop", "apps", "a-b-c@kubernetescrd, apps-office@kubernetescrd", r)
+	assert.Equal(t, "a-b-c@kubernetescrd,apps-office@kubernetescrd", refs)
+	assert.Equal(t, []report.Entry{
+		{Source: "Ingress apps/shop", Message: "middleware a-b-c@kubernetescrd is ambiguous, it matches the middlewares a-b/c, a/b-c"},
+	}, r.Entries())
+}
+
+func TestLists(t *testing.T) {
+	testCases := []TestStruct{
+		{
+			ingressRouteFile: "list.yaml",
+		},
+		{
+			ingressRouteFile: "list_server_fields.yaml",
+		},
+	}
+	for _, test := range testCases {
+		t.Run(test.ingressRouteFile, func(t *testing.T) {
+			testFile(test, t)
+		})
+	}
+}
+
+func TestRBAC(t *testing.T) {
+	testCases := []TestStruct{
+		{
+			ingressRouteFile: "rbac.yaml",
+		},
+		{
+			ingressRouteFile: "rbac_final.yaml",
+			rbacMode:         RBACFinal,
+		},
+	}
+	for _, test := range testCases {
+		t.Run(test.ingressRouteFile, func(t *testing.T) {
+			testFile(test, t)
+		})
+	}
+}
+
+func TestReferences(t *testing.T) {
+	testFile(TestStruct{ingressRouteFile: "references.yaml"}, t)
+
+	src, err := os.ReadFile(filepath.Join("fixtures", "input", "references.yaml"))
+	require.NoError(t, err)
+
+	objects, err := parser.ParseManifest(bytes.NewReader(src))
+	require.NoError(t, err)
+
+	r := report.New()
+	converter, err := New(r)
+	require.NoError(t, err)
+
+	_, err = converter.Do(objects)
+	require.NoError(t, err)
+
+	expected := []report.Entry{
+		{Source: "Application argocd/traefik-routes", Message: "spec.ignoreDifferences[0].group now references traefik.io"},
+		{Source: "AppProject argocd/platform", Message: "spec.namespaceResourceWhitelist[0].group now references traefik.io"},
+		{Source: "ConfigMap argocd/argocd-cm", Message: "data.resource.customizations now references traefik.io"},
+		{Source: "ConfigMap argocd/argocd-cm", Message: "data.resource.customizations.ignoreDifferences.traefik.containo.us_Middleware now references traefik.io"},
+		{Source: "ConfigMap argocd/argocd-cm", Message: "data.resource.exclusions now references traefik.io"},
+		{Source: "ClusterPolicy require-tls", Message: "spec.rules[0].match.any[0].resources.kinds[0] now references traefik.io"},
+		{Source: "K8sRequiredLabels routes-must-have-owner", Message: "spec.match.kinds[0].apiGroups[0] now references traefik.io"},
+		{Source: "Deployment kube-system/external-dns", Message: "spec.template.spec.containers[0].args now references traefik.io"},
+		{Source: "VerticalPodAutoscaler apps/routes-operator", Message: "spec.targetRef.apiVersion now references traefik.io"},
+	}
+	assert.Equal(t, expected, r.Entries())
+	assert.True(t, HasTraefikResources(objects[5:6]))
+}
+
+func TestUnknownKinds(t *testing.T) {
+	src, err := os.ReadFile(filepath.Join("fixtures", "input", "unknown_kinds.yaml"))
+	require.NoError(t, err)
+
+	objects, err := parser.ParseManifest(bytes.NewReader(src))
+	require.NoError(t, err)
+	require.Len(t, objects, 3)
+
+	converter, err := New(report.New())
+	require.NoError(t, err)
+
+	converted, err := converter.Do(objects)
+	require.NoError(t, err)
+
+	var fragments []string
+	for _, object := range converted {
+		s, err := converter.EncodeYaml(object)
+		require.NoError(t, err)
+
+		fragments = append(fragments, string(s))
+	}
+
+	fixtureFile := filepath.Join("fixtures", "output", "unknown_kinds.yaml")
+	data := strings.Join(fragments, "---\n")
+
+	if *updateExpected {
+		require.NoError(t, os.WriteFile(fixtureFile, []byte(data), 0o666))
+	}
+
+	expected, err := os.ReadFile(fixtureFile)
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), data)
+
+	// unknown kinds are written back byte for byte, without the lines of
+	// the server populated fields.
+	documents := strings.Split(string(src), "---\n")
+	assert.Equal(t, documents[0], fragments[0])
+	assert.Equal(t, strings.Replace(documents[2], "  resourceVersion: \"42\"\n", "", 1), fragments[2])
+}
+
+func TestHasTraefikResources(t *testing.T) {
+	testCases := []struct {
+		file     string
+		expected bool
+	}{
+		{file: "ingressroute_host.yaml", expected: true},
+		{file: "ingress_annotations.yaml", expected: true},
+		{file: "unknown_kinds.yaml", expected: true},
+		{file: "rbac.yaml", expected: true},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.file, func(t *testing.T) {
+			src, err := os.ReadFile(filepath.Join("fixtures", "input", test.file))
+			require.NoError(t, err)
+
+			objects, err := parser.ParseManifest(bytes.NewReader(src))
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expected, HasTraefikResources(objects))
+		})
+	}
+
+	objects, err := parser.ParseManifest(strings.NewReader("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: settings\n"))
+	require.NoError(t, err)
+	assert.False(t, HasTraefikResources(objects))
+}
+
+func TestCRDs(t *testing.T) {
+	testCases := []struct {
+		mode     CRDMode
+		expected []string
+	}{
+		{
+			mode: CRDAugment,
+			expected: []string{
+				"traefik",
+				"ingressroutes.traefik.containo.us",
+				"ingressroutes.traefik.io",
+				"middlewares.traefik.io",
+				"ingressroutetcps.traefik.io",
+				"ingressrouteudps.traefik.io",
+				"middlewaretcps.traefik.io",
+				"serverstransports.traefik.io",
+				"serverstransporttcps.traefik.io",
+				"tlsoptions.traefik.io",
+				"tlsstores.traefik.io",
+				"traefikservices.traefik.io",
+				"certificates.cert-manager.io",
+			},
+		},
+		{
+			mode: CRDReplace,
+			expected: []string{
+				"traefik",
+				"ingressroutes.traefik.io",
+				"middlewares.traefik.io",
+				"ingressroutetcps.traefik.io",
+				"ingressrouteudps.traefik.io",
+				"middlewaretcps.traefik.io",
+				"serverstransports.traefik.io",
+				"serverstransporttcps.traefik.io",
+				"tlsoptions.traefik.io",
+				"tlsstores.traefik.io",
+				"traefikservices.traefik.io",
+				"certificates.cert-manager.io",
+			},
+		},
+	}
+
+	snapshot, err := os.ReadFile(filepath.Join("fixtures", "input", "cluster_snapshot.yaml"))
+	require.NoError(t, err)
+	stored, err := parser.ParseManifest(bytes.NewReader(snapshot))
+	require.NoError(t, err)
+
+	for _, test := range testCases {
+		t.Run(string(test.mode), func(t *testing.T) {
+			src, err := os.ReadFile(filepath.Join("fixtures", "input", "crds.yaml"))
+			require.NoError(t, err)
+
+			objects, err := parser.ParseManifest(bytes.NewReader(src))
+			require.NoError(t, err)
+
+			r := report.New()
+			converter, err := New(r)
+			require.NoError(t, err)
+			require.NoError(t, converter.SetCRDMode(test.mode))
+			converter.SetClusterSnapshot(stored)
+
+			converted, err := converter.Do(objects)
+			require.NoError(t, err)
+
+			var names []string
+			for _, object := range converted {
+				accessor, err := meta.Accessor(object)
+				require.NoError(t, err)
+				names = append(names, accessor.GetName())
+			}
+			assert.Equal(t, test.expected, names)
+
+			v3, ok := converted[len(converted)-2].(*parser.Unknown)
+			require.True(t, ok)
+			data, err := converter.EncodeYaml(v3)
+			require.NoError(t, err)
+			assert.Contains(t, string(data), "group: traefik.io")
+
+			assert.Equal(t, []report.Entry{{
+				Source:  "CustomResourceDefinition ingressroutes.traefik.containo.us",
+				Message: "2 objects are still stored in the cluster snapshot, they must be migrated before the definition is removed",
+			}}, r.Entries())
+		})
+	}
+}
+
+func TestCRDsBatch(t *testing.T) {
+	testCases := []struct {
+		mode     CRDMode
+		first    int
+		expected []string
+		entries  []report.Entry
+	}{
+		{
+			mode:     CRDAugment,
+			first:    13,
+			expected: []string{"traefik", "ingressroutes.traefik.containo.us", "middlewares.traefik.io", "certificates.cert-manager.io"},
+		},
+		{
+			mode:     CRDReplace,
+			first:    12,
+			expected: []string{"traefik", "middlewares.traefik.io", "certificates.cert-manager.io"},
+			entries: []report.Entry{{
+				Source:  "CustomResourceDefinition ingressroutes.traefik.containo.us",
+				Message: "definition was removed, ingressroutes.traefik.io was added to a previous file",
+			}},
+		},
+	}
+
+	src, err := os.ReadFile(filepath.Join("fixtures", "input", "crds.yaml"))
+	require.NoError(t, err)
+
+	for _, test := range testCases {
+		t.Run(string(test.mode), func(t *testing.T) {
+			emitted := map[string]bool{}
+			var batch [][]string
+			var r *report.Report
+			for i := 0; i < 2; i++ {
+				objects, err := parser.ParseManifest(bytes.NewReader(src))
+				require.NoError(t, err)
+
+				r = report.New()
+				converter, err := New(r)
+				require.NoError(t, err)
+				require.NoError(t, converter.SetCRDMode(test.mode))
+				converter.SetEmittedCRDs(emitted)
+
+				converted, err := converter.Do(objects)
+				require.NoError(t, err)
+
+				var names []string
+				for _, object := range converted {
+					accessor, err := meta.Accessor(object)
+					require.NoError(t, err)
+					names = append(names, accessor.GetName())
+				}
+				batch = append(batch, names)
+			}
+
+			// the missing v3 definitions are only added to the first file,
+			// the ones of the manifests are still replaced.
+			assert.Len(t, batch[0], test.first)
+			assert.Equal(t, test.expected, batch[1])
+			assert.Equal(t, test.entries, r.Entries())
+		})
+	}
+}
+
+func TestGatewayAPI(t *testing.T) {
+	testFile(TestStruct{ingressRouteFile: "gateway_api.yaml", target: TargetGatewayAPI}, t)
+
+	src, err := os.ReadFile(filepath.Join("fixtures", "input", "gateway_api.yaml"))
+	require.NoError(t, err)
+
+	objects, err := parser.ParseManifest(bytes.NewReader(src))
+	require.NoError(t, err)
+
+	r := report.New()
+	converter, err := New(r)
+	require.NoError(t, err)
+	require.NoError(t, converter.SetTarget(TargetGatewayAPI))
+	require.NoError(t, converter.SetGateway("edge/public"))
+
+	converted, err := converter.Do(objects)
+	require.NoError(t, err)
+
+	var kinds []string
+	for _, object := range converted {
+		kinds = append(kinds, object.GetObjectKind().GroupVersionKind().Kind)
+	}
+	assert.Equal(t, []string{"Middleware", "Middleware", "Middleware", "Middleware", "HTTPRoute", "HTTPRoute", "HTTPRoute", "TCPRoute", "TLSRoute"}, kinds)
+
+	source := "IngressRoute default/web"
+	expected := []report.Entry{
+		{Source: "Middleware default/strip-api", Message: "StripPrefix.ForceSlash was removed in v3 and must be fixed manually"},
+		{Source: source, Message: "TLS is configured on the listeners of Gateway edge/public, not on the routes"},
+		{Source: source, Message: "priority 10 of rule Host(`example.com`) && PathPrefix(`/api/`) has no Gateway API equivalent"},
+		{Source: source, Message: "option sticky of service api has no Gateway API equivalent"},
+		{Source: source, Message: "port http of service web is named, Gateway API backends need the port number"},
+		{Source: source, Message: "ClientIP(`10.0.0.0/8`) of rule Host(`example.com`) && (Query(`debug`, `1`) || ClientIP(`10.0.0.0/8`)) " +
+			"can't be expressed in Gateway API, the matching requests are not routed"},
+		{Source: source, Message: "service monitoring/health is in another namespace, it must be allowed by a ReferenceGrant"},
+	}
+	assert.Equal(t, expected, r.Entries())
+
+	assert.Equal(t, "*.example.org", wildcardHost("^(?P<subdomain>[a-z0-9-]+)\\.example\\.org$"))
+	assert.Empty(t, wildcardHost("^[a-z]+-api\\.example\\.org$"))
+}
+
+func TestReverse(t *testing.T) {
+	testFile(TestStruct{ingressRouteFile: "reverse.yaml", reverse: true}, t)
+}
+
+func TestReverseUnsupported(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		manifest string
+		expected string
+	}{
+		{
+			desc: "v3 only middleware",
+			manifest: `apiVersion: traefik.io/v1alpha1
+kind: Middleware
+metadata:
+  name: grpc
+  namespace: default
+spec:
+  grpcWeb:
+    allowOrigins:
+      - "*"
+`,
+			expected: "Middleware default/grpc: spec.grpcWeb not supported by v2",
+		},
+		{
+			desc: "reject status code of an IP allow list",
+			manifest: `apiVersion: traefik.io/v1alpha1
+kind: Middleware
+metadata:
+  name: allow-internal
+  namespace: default
+spec:
+  ipAllowList:
+    sourceRange:
+      - 10.0.0.0/8
+    rejectStatusCode: 404
+`,
+			expected: "Middleware default/allow-internal: spec.ipAllowList.rejectStatusCode not supported by v2",
+		},
+		{
+			desc: "v3 only kind",
+			manifest: `apiVersion: traefik.io/v1alpha1
+kind: ServersTransportTCP
+metadata:
+  name: transport
+  namespace: default
+spec:
+  dialTimeout: 30s
+`,
+			expected: "ServersTransportTCP default/transport: kind ServersTransportTCP has no v2 equivalent",
+		},
+		{
+			desc: "regular expression with repetitions",
+			manifest: "apiVersion: traefik.io/v1alpha1\n" +
+				"kind: IngressRoute\n" +
+				"metadata:\n  name: web\n  namespace: default\n" +
+				"spec:\n  routes:\n    - match: PathRegexp(`^/[a-z]{2}/`)\n      kind: Rule\n" +
+				"      services:\n        - name: web\n          port: 80\n",
+			expected: "IngressRoute default/web: regular expression ^/[a-z]{2}/ can't be expressed as a v2 template",
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			objects, err := parser.ParseManifest(strings.NewReader(test.manifest))
+			require.NoError(t, err)
+
+			converter, err := New(report.New())
+			require.NoError(t, err)
+			require.NoError(t, converter.Reverse())
+
+			_, err = converter.Do(objects)
+			assert.EqualError(t, err, test.expected)
+		})
+	}
+}
+
+func TestIngressesV1(t *testing.T) {
+	testFile(TestStruct{ingressRouteFile: "ingress_v1.yaml", v1: true}, t)
+
+	src, err := os.ReadFile(filepath.Join("fixtures", "input", "ingress_v1.yaml"))
+	require.NoError(t, err)
+
+	objects, err := parser.ParseManifest(bytes.NewReader(src))
+	require.NoError(t, err)
+
+	r := report.New()
+	converter, err := New(r)
+	require.NoError(t, err)
+	converter.ConvertV1()
+
+	_, err = converter.Do(objects)
+	require.NoError(t, err)
+
+	expected := []report.Entry{
+		{Source: "ingress default/api", Message: "annotation traefik.ingress.kubernetes.io/session-cookie-hash has no v3 equivalent and was dropped"},
+		{Source: "service default/api", Message: "annotation traefik.ingress.kubernetes.io/load-balancer-method has no v3 equivalent and was dropped"},
+	}
+	assert.Equal(t, expected, r.Entries())
+}
+
+func TestIngressNginx(t *testing.T) {
+	testFile(TestStruct{ingressRouteFile: "ingress_nginx.yaml", ingressNginx: true}, t)
+
+	src, err := os.ReadFile(filepath.Join("fixtures", "input", "ingress_nginx.yaml"))
+	require.NoError(t, err)
+
+	objects, err := parser.ParseManifest(bytes.NewReader(src))
+	require.NoError(t, err)
+
+	r := report.New()
+	converter, err := New(r)
+	require.NoError(t, err)
+	converter.ConvertIngressNginx()
+
+	_, err = converter.Do(objects)
+	require.NoError(t, err)
+
+	expected := []report.Entry{
+		{Source: "ingress default/shop", Message: "annotation nginx.ingress.kubernetes.io/configuration-snippet has no Traefik equivalent and was dropped"},
+		{Source: "ingress default/shop", Message: "annotation nginx.ingress.kubernetes.io/proxy-read-timeout has no Traefik equivalent and was dropped"},
+		{Source: "ingress default/shop", Message: "https redirection is served by IngressRoute shop-http on the web entry point, which must exist"},
+		{Source: "ingress default/admin", Message: "annotation nginx.ingress.kubernetes.io/auth-signin has no Traefik equivalent and was dropped"},
+	}
+	assert.Equal(t, expected, r.Entries())
+}
+
+func TestGatewayUpgrade(t *testing.T) {
+	testFile(TestStruct{ingressRouteFile: "gateway_upgrade.yaml"}, t)
+
+	src, err := os.ReadFile(filepath.Join("fixtures", "input", "gateway_upgrade.yaml"))
+	require.NoError(t, err)
+
+	objects, err := parser.ParseManifest(bytes.NewReader(src))
+	require.NoError(t, err)
+	assert.True(t, HasTraefikResources(objects[4:5]))
+	assert.False(t, HasTraefikResources(objects[5:]))
+
+	r := report.New()
+	converter, err := New(r)
+	require.NoError(t, err)
+
+	_, err = converter.Do(objects)
+	require.NoError(t, err)
+
+	expected := []report.Entry{
+		{Source: "GatewayClass traefik", Message: "controllerName traefik.containo.us/gateway-controller was replaced by traefik.io/gateway-controller, the one of Traefik v3"},
+		{Source: "GatewayClass traefik", Message: "spec.parametersRef.scope was removed, the scope is Namespace when spec.parametersRef.namespace is set"},
+		{Source: "HTTPRoute default/whoami", Message: "spec.rules[0].filters[0].extensionRef.group now references traefik.io"},
+		{Source: "TCPRoute default/database", Message: "TCPRoute is only served in v1alpha2, the kubernetesGateway provider must enable experimentalChannel"},
+		{Source: "ReferencePolicy default/allow-traefik", Message: "ReferencePolicy was renamed ReferenceGrant in gateway.networking.k8s.io/v1beta1"},
+	}
+	assert.Equal(t, expected, r.Entries())
+}
+
+func TestSourceGroup(t *testing.T) {
+	testFile(TestStruct{ingressRouteFile: "source_group.yaml"}, t)
+
+	src, err := os.ReadFile(filepath.Join("fixtures", "input", "source_group.yaml"))
+	require.NoError(t, err)
+
+	objects, err := parser.ParseManifest(bytes.NewReader(src))
+	require.NoError(t, err)
+
+	r := report.New()
+	converter, err := New(r)
+	require.NoError(t, err)
+
+	_, err = converter.Do(objects)
+	require.NoError(t, err)
+
+	expected := []report.Entry{
+		{Source: "IngressRoute default/legacy", Message: "rule Host(`example.com`) && Headers(`X-Version`, `2`) uses the v2 syntax, " +
+			"rule Host(`example.com`) && Path(`/users/{id:[0-9]+}`) uses the v2 syntax in traefik.io, converted as a v2 object"},
+		{Source: "Middleware default/office", Message: "ipWhiteList is deprecated in traefik.io, converted as a v2 object"},
+		{Source: "IngressRouteTCP default/database", Message: "rule HostSNI(`db.example.com`, `db2.example.com`) uses the v2 syntax in traefik.io, converted as a v2 object"},
+		{Source: "MiddlewareTCP default/office-tcp", Message: "ipWhiteList is deprecated in traefik.io, converted as a v2 object"},
+	}
+	assert.Equal(t, expected, r.Entries())
+
+	manifest := `apiVersion: traefik.io/v1alpha1
+kind: IngressRoute
+metadata:
+  name: www
+  namespace: default
+spec:
+  routes:
+    - match: Host(` + "`example.com`, `www.example.com`" + `)
+      kind: Rule
+      syntax: v2
+      services:
+        - name: whoami
+          port: 80
+`
+	objects, err = parser.ParseManifest(strings.NewReader(manifest))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		group    SourceGroup
+		expected string
+	}{
+		{group: SourceGroupAuto, expected: "Host(`example.com`, `www.example.com`)"},
+		{group: SourceGroupContainous, expected: "Host(`example.com`, `www.example.com`)"},
+		{group: SourceGroupTraefik, expected: "Host(`example.com`) || Host(`www.example.com`)"},
+	}
+	for _, test := range testCases {
+		t.Run(string(test.group), func(t *testing.T) {
+			r := report.New()
+			converter, err := New(r)
+			require.NoError(t, err)
+			require.NoError(t, converter.SetSourceGroup(test.group))
+
+			converted, err := converter.Do(objects)
+			require.NoError(t, err)
+
+			data, err := converter.EncodeYaml(converted[0])
+			require.NoError(t, err)
+			assert.Contains(t, string(data), test.expected)
+		})
+	}
+
+	// routes only valid in v3 are kept when every traefik.io object is
+	// converted.
+	objects, err = parser.ParseManifest(bytes.NewReader(src))
+	require.NoError(t, err)
+
+	r = report.New()
+	converter, err = New(r)
+	require.NoError(t, err)
+	require.NoError(t, converter.SetSourceGroup(SourceGroupTraefik))
+
+	converted, err := converter.Do(objects)
+	require.NoError(t, err)
+
+	data, err := converter.EncodeYaml(converted[1])
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "Host(`example.com`) && Header(`X-Version`, `3`)")
+	assert.Contains(t, string(data), "(Host(`example.com`) || Host(`www.example.com`))")
+	assert.Contains(t, r.Entries(), report.Entry{
+		Source:  "IngressRoute default/current",
+		Message: "rule Host(`example.com`) && Header(`X-Version`, `3`) is only valid in v3 and was kept as it is",
+	})
+
+	converter, err = New(report.New())
+	require.NoError(t, err)
+	assert.Error(t, converter.SetSourceGroup("traefik"))
+}
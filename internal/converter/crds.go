@@ -0,0 +1,174 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/databotic/traefik-migration-tool/internal/crd"
+	"github.com/databotic/traefik-migration-tool/internal/parser"
+	"github.com/databotic/traefik-migration-tool/internal/report"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const crdGroupKind = "CustomResourceDefinition.apiextensions.k8s.io"
+
+// CRDMode selects what happens to the v2 CustomResourceDefinitions.
+type CRDMode string
+
+const (
+	// CRDAugment keeps the v2 definitions and adds the v3 ones next to
+	// them.
+	CRDAugment CRDMode = "augment"
+	// CRDReplace replaces the v2 definitions with the v3 ones.
+	CRDReplace CRDMode = "replace"
+)
+
+// crds replaces the Traefik CustomResourceDefinitions of the manifests with
+// the v3 definitions bundled in the tool.
+type crds struct {
+	mode        CRDMode
+	definitions []*parser.Unknown
+	// emitted records the v3 definitions already written, so each is only
+	// written once per run.
+	emitted map[string]bool
+	// stored counts the objects of a cluster snapshot by group kind.
+	stored map[string]int
+	report *report.Report
+}
+
+func newCRDs(r *report.Report) *crds {
+	return &crds{mode: CRDAugment, emitted: map[string]bool{}, stored: map[string]int{}, report: r}
+}
+
+// SetCRDMode selects what happens to the v2 CustomResourceDefinitions.
+func (c *Converter) SetCRDMode(mode CRDMode) error {
+	switch mode {
+	case CRDAugment, CRDReplace:
+	default:
+		return fmt.Errorf("unknown CRD mode %s, expected %s or %s", mode, CRDAugment, CRDReplace)
+	}
+	c.crds.mode = mode
+	return nil
+}
+
+// SetClusterSnapshot records the objects stored in a cluster, so the v2
+// definitions which still have objects are reported.
+func (c *Converter) SetClusterSnapshot(objects []runtime.Object) {
+	c.crds.stored = map[string]int{}
+	for _, o := range objects {
+		gk := o.GetObjectKind().GroupVersionKind().GroupKind()
+		if gk.Group == containousGroup {
+			c.crds.stored[gk.String()]++
+		}
+	}
+}
+
+// SetEmittedCRDs shares the record of the v3 definitions already written
+// with the converters of the other files of a batch, so the definitions are
+// only added to the first file holding Traefik definitions.
+func (c *Converter) SetEmittedCRDs(emitted map[string]bool) {
+	c.crds.emitted = emitted
+}
+
+// convert replaces the Traefik definitions of objects with the v3 ones.
+// The v3 definitions missing from the manifests, and not written before, are
+// added after the last Traefik definition.
+func (c *crds) convert(objects []runtime.Object) ([]runtime.Object, error) {
+	converted := make([]runtime.Object, 0, len(objects))
+	// written records the v3 definitions written to this file, while
+	// c.emitted holds the ones of the whole run.
+	written := map[string]bool{}
+	last := -1
+
+	for _, o := range objects {
+		u, group, ok := traefikCRD(o)
+		if !ok {
+			converted = append(converted, o)
+			continue
+		}
+
+		if c.definitions == nil {
+			definitions, err := crd.Definitions()
+			if err != nil {
+				return nil, fmt.Errorf("error loading the v3 definitions: %v", err)
+			}
+			c.definitions = definitions
+		}
+
+		name := u.GetName()
+		source := "CustomResourceDefinition " + name
+		if group == containousGroup {
+			c.reportStored(source, u)
+			if c.mode == CRDAugment {
+				converted = append(converted, o)
+			}
+			name = strings.TrimSuffix(name, containousGroup) + traefikGroup
+		}
+
+		definition := c.definition(name)
+		switch {
+		case definition == nil:
+			c.report.Add(source, "definition is not part of Traefik %s and was left unchanged", crd.Version)
+			if group == traefikGroup || c.mode == CRDReplace {
+				converted = append(converted, o)
+			}
+		case written[name]:
+		case !c.emitted[name] || group == traefikGroup:
+			// a v3 definition already written to a previous file is
+			// still replaced, so the file keeps it.
+			c.emitted[name], written[name] = true, true
+			converted = append(converted, definition.DeepCopyObject())
+		case c.mode == CRDReplace:
+			c.report.Add(source, "definition was removed, %s was added to a previous file", name)
+		}
+		last = len(converted)
+	}
+
+	if last < 0 {
+		return converted, nil
+	}
+
+	var missing []runtime.Object
+	for _, definition := range c.definitions {
+		if !c.emitted[definition.GetName()] {
+			c.emitted[definition.GetName()] = true
+			written[definition.GetName()] = true
+			missing = append(missing, definition.DeepCopyObject())
+		}
+	}
+	return append(converted[:last], append(missing, converted[last:]...)...), nil
+}
+
+func (c *crds) definition(name string) *parser.Unknown {
+	for _, definition := range c.definitions {
+		if definition.GetName() == name {
+			return definition
+		}
+	}
+	return nil
+}
+
+func (c *crds) reportStored(source string, u *parser.Unknown) {
+	kind, _, _ := unstructured.NestedString(u.Object, "spec", "names", "kind")
+	if n := c.stored[kind+"."+containousGroup]; n > 0 {
+		c.report.Add(source, "%d objects are still stored in the cluster snapshot, "+
+			"they must be migrated before the definition is removed", n)
+	}
+}
+
+// traefikCRD returns the definition held by o, with its group, when o is a
+// definition of a Traefik resource.
+func traefikCRD(o runtime.Object) (*parser.Unknown, string, bool) {
+	u, ok := o.(*parser.Unknown)
+	if !ok || u.GroupVersionKind().GroupKind().String() != crdGroupKind {
+		return nil, "", false
+	}
+
+	group, _, _ := unstructured.NestedString(u.Object, "spec", "group")
+	switch group {
+	case containousGroup, traefikGroup:
+		return u, group, true
+	}
+	return nil, "", false
+}
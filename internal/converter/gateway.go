@@ -0,0 +1,699 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/databotic/traefik-migration-tool/internal/report"
+	"github.com/databotic/traefik-migration-tool/internal/utils"
+	containous "github.com/traefik/traefik/v2/pkg/provider/kubernetes/crd/traefikcontainous/v1alpha1"
+	traefikio "github.com/traefik/traefik/v3/pkg/provider/kubernetes/crd/traefikio/v1alpha1"
+	"github.com/traefik/traefik/v3/pkg/rules"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// Target is the kind of objects the routing configuration is converted to.
+type Target string
+
+const (
+	// TargetCRD converts the routing configuration to the v3 Traefik CRDs.
+	TargetCRD Target = "crd"
+	// TargetGatewayAPI converts the IngressRoutes to Gateway API routes.
+	TargetGatewayAPI Target = "gateway-api"
+	// TargetFileProvider converts the routing configuration to the v3 Traefik
+	// CRDs, which are then exported to a file provider dynamic configuration.
+	TargetFileProvider Target = "file-provider"
+)
+
+var (
+	httpMatchers = []string{
+		"Host", "HostRegexp", "Path", "PathPrefix", "PathRegexp", "Header", "HeaderRegexp",
+		"Query", "QueryRegexp", "Method", "ClientIP",
+	}
+	tcpMatchers = []string{"HostSNI", "HostSNIRegexp", "ClientIP", "ALPN"}
+
+	// wildcardHostPattern matches the host regular expressions equivalent
+	// to a Gateway API wildcard hostname.
+	wildcardHostPattern = regexp.MustCompile(`^\^?(?:\(\?P<\w+>)?(?:\[a-z0-9-\]\+|\[\^\.\]\+|\.\+)\)?\\\.((?:[a-zA-Z0-9-]+\\\.)*[a-zA-Z0-9-]+)\$?$`)
+
+	// routeBackendFields are the fields of a service which have a Gateway
+	// API equivalent.
+	routeBackendFields = []string{"kind", "name", "namespace", "port", "weight"}
+)
+
+// ruleParser parses a router rule to its tree of matchers.
+type ruleParser interface {
+	Parse(rule string) (interface{}, error)
+}
+
+// MultiConvertFactory is implemented by the converters which produce several
+// objects from a single one. Do prefers it over Transform, unless TransformAll
+// returns no objects.
+type MultiConvertFactory interface {
+	ConvertFactory
+	TransformAll(object runtime.Object) ([]runtime.Object, error)
+}
+
+// GatewayAPI converts IngressRoutes to HTTPRoutes and IngressRouteTCPs to
+// TCPRoutes and TLSRoutes. Middlewares are mapped to the equivalent filters,
+// or referenced as ExtensionRef filters.
+type GatewayAPI struct {
+	rules     *IngressRoute
+	tcpRules  *TCPRule
+	http, tcp ruleParser
+	parent    parentReference
+
+	// middlewares are the v3 specs of the middlewares of the manifests, by
+	// namespace/name.
+	middlewares map[string]*traefikio.MiddlewareSpec
+	report      *report.Report
+}
+
+func NewGatewayAPI(ingressRoutes *IngressRoute, tcpRules *TCPRule, r *report.Report) (*GatewayAPI, error) {
+	http, err := rules.NewParser(httpMatchers)
+	if err != nil {
+		return nil, err
+	}
+	tcp, err := rules.NewParser(tcpMatchers)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GatewayAPI{
+		rules:       ingressRoutes,
+		tcpRules:    tcpRules,
+		http:        http,
+		tcp:         tcp,
+		parent:      parentReference{Namespace: "traefik", Name: "traefik-gateway"},
+		middlewares: map[string]*traefikio.MiddlewareSpec{},
+		report:      r,
+	}, nil
+}
+
+// SetTarget selects the kind of objects the routing configuration is
+// converted to.
+func (c *Converter) SetTarget(target Target) error {
+	switch target {
+	case TargetCRD, TargetFileProvider:
+		c.gateway = nil
+		return nil
+	case TargetGatewayAPI:
+	default:
+		return fmt.Errorf("unknown target %s, expected %s, %s or %s", target, TargetCRD, TargetGatewayAPI, TargetFileProvider)
+	}
+
+	ingressRoutes, err := NewIngressRoute()
+	if err != nil {
+		return err
+	}
+	tcpRules, err := NewTCPRule()
+	if err != nil {
+		return err
+	}
+	gateway, err := NewGatewayAPI(ingressRoutes, tcpRules, c.report)
+	if err != nil {
+		return err
+	}
+
+	c.gateway = gateway
+	for _, kind := range []string{"IngressRoute", "IngressRouteTCP"} {
+		c.converters[kind+"."+containousGroup] = gateway
+		c.converters[kind+"."+traefikGroup] = gateway
+	}
+	return nil
+}
+
+// SetGateway sets the Gateway the generated routes are attached to, as
+// namespace/name.
+func (c *Converter) SetGateway(gateway string) error {
+	if c.gateway == nil {
+		return nil
+	}
+	namespace, name, found := strings.Cut(gateway, "/")
+	if !found || namespace == "" || name == "" {
+		return fmt.Errorf("invalid gateway %s, expected namespace/name", gateway)
+	}
+	c.gateway.parent = parentReference{Namespace: namespace, Name: name}
+	return nil
+}
+
+// indexMiddlewares records the v3 specs of the middlewares of the manifests,
+// so they can be mapped to filters.
+func (g *GatewayAPI) indexMiddlewares(objects []runtime.Object) {
+	for _, o := range objects {
+		switch m := o.(type) {
+		case *containous.Middleware:
+			// the deprecated options are reported by the conversion of
+			// the middleware itself.
+			spec, err := middlewareSpec(m)
+			if err != nil {
+				continue
+			}
+			g.middlewares[m.Namespace+"/"+m.Name] = spec
+		case *traefikio.Middleware:
+			g.middlewares[m.Namespace+"/"+m.Name] = m.Spec.DeepCopy()
+		}
+	}
+}
+
+func (g *GatewayAPI) Transform(object runtime.Object) (runtime.Object, error) {
+	objects, err := g.TransformAll(object)
+	if err != nil {
+		return nil, err
+	}
+	if len(objects) != 1 {
+		return nil, fmt.Errorf("%s converts to %d routes", objectName(object), len(objects))
+	}
+	return objects[0], nil
+}
+
+func (g *GatewayAPI) TransformAll(object runtime.Object) ([]runtime.Object, error) {
+	switch o := object.(type) {
+	case *containous.IngressRoute:
+		converted, err := g.rules.Transform(o)
+		if err != nil {
+			return nil, err
+		}
+		return g.httpRoutes(converted.(*traefikio.IngressRoute))
+	case *traefikio.IngressRoute:
+		return g.httpRoutes(o)
+	case *containous.IngressRouteTCP:
+		ingressRoute, err := utils.AsType[traefikio.IngressRouteTCP](o)
+		if err != nil {
+			return nil, err
+		}
+		for i, route := range ingressRoute.Spec.Routes {
+			if ingressRoute.Spec.Routes[i].Match, err = g.tcpRules.ConvertRule(route.Match); err != nil {
+				return nil, err
+			}
+		}
+		return g.tcpRoutes(ingressRoute)
+	case *traefikio.IngressRouteTCP:
+		return g.tcpRoutes(o)
+	default:
+		return nil, fmt.Errorf("unexpected object %T, expected an ingress route", object)
+	}
+}
+
+// conjunction is a set of matchers which must all match.
+type conjunction []*rules.Tree
+
+// disjunctiveForm expands a rule tree into the conjunctions it is the union
+// of.
+func disjunctiveForm(tree *rules.Tree) []conjunction {
+	switch tree.Matcher {
+	case "or":
+		return append(disjunctiveForm(tree.RuleLeft), disjunctiveForm(tree.RuleRight)...)
+	case "and":
+		var expanded []conjunction
+		for _, left := range disjunctiveForm(tree.RuleLeft) {
+			for _, right := range disjunctiveForm(tree.RuleRight) {
+				c := append(append(conjunction{}, left...), right...)
+				expanded = append(expanded, c)
+			}
+		}
+		return expanded
+	default:
+		return []conjunction{{tree}}
+	}
+}
+
+func parseRule(p ruleParser, rule string) (*rules.Tree, error) {
+	parsed, err := p.Parse(rule)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing rule %s: %w", rule, err)
+	}
+	builder, ok := parsed.(rules.TreeBuilder)
+	if !ok {
+		return nil, fmt.Errorf("error parsing rule %s", rule)
+	}
+	return builder(), nil
+}
+
+func formatMatcher(tree *rules.Tree) string {
+	values := make([]string, len(tree.Value))
+	for i, v := range tree.Value {
+		values[i] = "`" + v + "`"
+	}
+	matcher := fmt.Sprintf("%s(%s)", tree.Matcher, strings.Join(values, ", "))
+	if tree.Not {
+		return "!" + matcher
+	}
+	return matcher
+}
+
+// hostRule groups the matches of a route sharing the same hostname.
+type hostRule struct {
+	hostname string
+	matches  []httpRouteMatch
+}
+
+func (g *GatewayAPI) httpRoutes(ingressRoute *traefikio.IngressRoute) ([]runtime.Object, error) {
+	source := "IngressRoute " + objectName(ingressRoute)
+
+	if ingressRoute.Spec.TLS != nil {
+		g.report.Add(source, "TLS is configured on the listeners of Gateway %s/%s, not on the routes", g.parent.Namespace, g.parent.Name)
+	}
+
+	var hostnames []string
+	byHostname := map[string]*httpRouteSpec{}
+
+	for _, route := range ingressRoute.Spec.Routes {
+		tree, err := parseRule(g.http, route.Match)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", source, err)
+		}
+		if route.Priority != 0 {
+			g.report.Add(source, "priority %d of rule %s has no Gateway API equivalent", route.Priority, route.Match)
+		}
+
+		var groups []*hostRule
+		for _, c := range disjunctiveForm(tree) {
+			hostname, match, ok := g.httpMatch(source, route.Match, c)
+			if !ok {
+				continue
+			}
+
+			var group *hostRule
+			for _, h := range groups {
+				if h.hostname == hostname {
+					group = h
+				}
+			}
+			if group == nil {
+				group = &hostRule{hostname: hostname}
+				groups = append(groups, group)
+			}
+			group.matches = append(group.matches, match)
+		}
+
+		if len(groups) == 0 {
+			continue
+		}
+
+		backendRefs := g.backendRefs(source, ingressRoute.Namespace, route.Services)
+		for _, group := range groups {
+			spec, ok := byHostname[group.hostname]
+			if !ok {
+				spec = &httpRouteSpec{ParentRefs: g.parentRefs(ingressRoute.Spec.EntryPoints)}
+				if group.hostname != "" {
+					spec.Hostnames = []string{group.hostname}
+				}
+				byHostname[group.hostname] = spec
+				hostnames = append(hostnames, group.hostname)
+			}
+
+			spec.Rules = append(spec.Rules, httpRouteRule{
+				Matches:     group.matches,
+				Filters:     g.filters(source, ingressRoute.Namespace, route.Middlewares, group.matches),
+				BackendRefs: backendRefs,
+			})
+		}
+	}
+
+	objects := []runtime.Object{}
+	for i, hostname := range hostnames {
+		meta := routeMeta(ingressRoute.ObjectMeta, i, len(hostnames))
+		route, err := newGatewayObject(gatewayObject{
+			APIVersion: gatewayAPIVersion,
+			Kind:       "HTTPRoute",
+			Metadata:   meta,
+			Spec:       byHostname[hostname],
+		})
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, route)
+	}
+	return objects, nil
+}
+
+// httpMatch maps a conjunction of matchers to a match of the routes of a
+// hostname. Conjunctions which can't be expressed are reported and skipped,
+// since dropping a matcher would widen the route.
+func (g *GatewayAPI) httpMatch(source, rule string, c conjunction) (string, httpRouteMatch, bool) {
+	var hostname string
+	var match httpRouteMatch
+
+	for _, m := range c {
+		expressed := !m.Not
+		switch {
+		case !expressed:
+		case m.Matcher == "Host":
+			expressed = hostname == "" || hostname == m.Value[0]
+			hostname = m.Value[0]
+		case m.Matcher == "HostRegexp":
+			wildcard := wildcardHost(m.Value[0])
+			expressed = wildcard != "" && (hostname == "" || hostname == wildcard)
+			hostname = wildcard
+		case m.Matcher == "Path", m.Matcher == "PathPrefix", m.Matcher == "PathRegexp":
+			expressed = match.Path == nil
+			match.Path = &httpMatchValue{Type: pathMatchType(m.Matcher), Value: m.Value[0]}
+			if m.Matcher == "PathPrefix" && m.Value[0] != "/" && !strings.HasSuffix(m.Value[0], "/") {
+				g.report.Add(source, "%s of rule %s only matches whole path segments in Gateway API", formatMatcher(m), rule)
+			}
+		case m.Matcher == "Header", m.Matcher == "HeaderRegexp":
+			match.Headers = append(match.Headers, httpMatchValue{Type: valueMatchType(m.Matcher), Name: m.Value[0], Value: m.Value[1]})
+		case m.Matcher == "Query", m.Matcher == "QueryRegexp":
+			expressed = len(m.Value) == 2
+			if expressed {
+				match.QueryParams = append(match.QueryParams, httpMatchValue{Type: valueMatchType(m.Matcher), Name: m.Value[0], Value: m.Value[1]})
+			}
+		case m.Matcher == "Method":
+			method := strings.ToUpper(m.Value[0])
+			expressed = match.Method == "" || match.Method == method
+			match.Method = method
+		default:
+			expressed = false
+		}
+
+		if !expressed {
+			g.report.Add(source, "%s of rule %s can't be expressed in Gateway API, the matching requests are not routed", formatMatcher(m), rule)
+			return "", httpRouteMatch{}, false
+		}
+	}
+
+	return hostname, match, true
+}
+
+func pathMatchType(matcher string) string {
+	switch matcher {
+	case "Path":
+		return "Exact"
+	case "PathPrefix":
+		return "PathPrefix"
+	default:
+		return "RegularExpression"
+	}
+}
+
+func valueMatchType(matcher string) string {
+	if strings.HasSuffix(matcher, "Regexp") {
+		return "RegularExpression"
+	}
+	return "Exact"
+}
+
+// wildcardHost returns the wildcard hostname equivalent to a host regular
+// expression, if any.
+func wildcardHost(pattern string) string {
+	m := wildcardHostPattern.FindStringSubmatch(pattern)
+	if m == nil {
+		return ""
+	}
+	return "*." + strings.ReplaceAll(m[1], `\.`, ".")
+}
+
+func (g *GatewayAPI) parentRefs(entryPoints []string) []parentReference {
+	if len(entryPoints) == 0 {
+		return []parentReference{g.parent}
+	}
+
+	var refs []parentReference
+	for _, entryPoint := range entryPoints {
+		ref := g.parent
+		ref.SectionName = entryPoint
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+func (g *GatewayAPI) backendRefs(source, namespace string, services []traefikio.Service) []backendRef {
+	var refs []backendRef
+	for _, service := range services {
+		ref := backendRef{Name: service.Name}
+		if service.Namespace != "" && service.Namespace != namespace {
+			ref.Namespace = service.Namespace
+			g.report.Add(source, "service %s/%s is in another namespace, it must be allowed by a ReferenceGrant", service.Namespace, service.Name)
+		}
+		if service.Weight != nil {
+			weight := int32(*service.Weight)
+			ref.Weight = &weight
+		}
+
+		switch {
+		case service.Kind == "TraefikService":
+			ref.Group = traefikGroup
+			ref.Kind = "TraefikService"
+		case service.Port.Type == intstr.String:
+			g.report.Add(source, "port %s of service %s is named, Gateway API backends need the port number", service.Port.StrVal, service.Name)
+		default:
+			port := service.Port.IntVal
+			ref.Port = &port
+		}
+
+		g.reportServiceOptions(source, service.Name, service.LoadBalancerSpec)
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+func (g *GatewayAPI) reportServiceOptions(source, name string, spec interface{}) {
+	options, err := utils.ToMap(spec)
+	if err != nil {
+		return
+	}
+
+	var lost []string
+	for option := range options {
+		if !contains(routeBackendFields, option) {
+			lost = append(lost, option)
+		}
+	}
+	sort.Strings(lost)
+	for _, option := range lost {
+		g.report.Add(source, "option %s of service %s has no Gateway API equivalent", option, name)
+	}
+}
+
+// filters maps the middlewares of a route to filters. The middlewares with no
+// equivalent filter are referenced as ExtensionRef filters.
+func (g *GatewayAPI) filters(source, namespace string, refs []traefikio.MiddlewareRef, matches []httpRouteMatch) []httpRouteFilter {
+	var filters []httpRouteFilter
+	used := map[string]bool{}
+
+	for _, ref := range refs {
+		if strings.Contains(ref.Name, "@") {
+			g.report.Add(source, "middleware %s of another provider can't be referenced from Gateway API and was dropped", ref.Name)
+			continue
+		}
+		if ref.Namespace != "" && ref.Namespace != namespace {
+			g.report.Add(source, "middleware %s/%s of another namespace can't be referenced from Gateway API and was dropped", ref.Namespace, ref.Name)
+			continue
+		}
+
+		mapped := g.middlewareFilters(g.middlewares[namespace+"/"+ref.Name], matches)
+		for _, filter := range mapped {
+			if used[filter.Type] {
+				mapped = nil
+			}
+		}
+		if mapped == nil {
+			mapped = []httpRouteFilter{{
+				Type:         gatewayFilterExtRef,
+				ExtensionRef: &localObjectReference{Group: traefikGroup, Kind: "Middleware", Name: ref.Name},
+			}}
+		}
+
+		for _, filter := range mapped {
+			if filter.Type != gatewayFilterExtRef {
+				used[filter.Type] = true
+			}
+		}
+		filters = append(filters, mapped...)
+	}
+	return filters
+}
+
+// middlewareFilters returns the filters equivalent to a middleware, or nil
+// when it has none.
+func (g *GatewayAPI) middlewareFilters(spec *traefikio.MiddlewareSpec, matches []httpRouteMatch) []httpRouteFilter {
+	if spec == nil {
+		return nil
+	}
+	if options, err := utils.ToMap(spec); err != nil || len(options) != 1 {
+		return nil
+	}
+
+	switch {
+	case spec.Headers != nil:
+		options, err := utils.ToMap(spec.Headers)
+		if err != nil {
+			return nil
+		}
+		delete(options, "customRequestHeaders")
+		delete(options, "customResponseHeaders")
+		if len(options) > 0 {
+			return nil
+		}
+
+		var filters []httpRouteFilter
+		if len(spec.Headers.CustomRequestHeaders) > 0 {
+			filters = append(filters, httpRouteFilter{
+				Type:                  gatewayFilterRequest,
+				RequestHeaderModifier: newHeaderModifier(spec.Headers.CustomRequestHeaders),
+			})
+		}
+		if len(spec.Headers.CustomResponseHeaders) > 0 {
+			filters = append(filters, httpRouteFilter{
+				Type:                   gatewayFilterResponse,
+				ResponseHeaderModifier: newHeaderModifier(spec.Headers.CustomResponseHeaders),
+			})
+		}
+		return filters
+
+	case spec.RedirectScheme != nil:
+		redirect := &requestRedirect{Scheme: spec.RedirectScheme.Scheme, StatusCode: 302}
+		if spec.RedirectScheme.Permanent {
+			redirect.StatusCode = 301
+		}
+		if spec.RedirectScheme.Port != "" {
+			port, err := strconv.Atoi(spec.RedirectScheme.Port)
+			if err != nil {
+				return nil
+			}
+			redirect.Port = &port
+		}
+		return []httpRouteFilter{{Type: gatewayFilterRedirect, RequestRedirect: redirect}}
+
+	case spec.ReplacePath != nil:
+		return []httpRouteFilter{{
+			Type:       gatewayFilterRewrite,
+			URLRewrite: &urlRewrite{Path: &httpPathModifier{Type: "ReplaceFullPath", ReplaceFullPath: spec.ReplacePath.Path}},
+		}}
+
+	case spec.StripPrefix != nil:
+		// the prefix is replaced only when it is the one the route matched.
+		if len(spec.StripPrefix.Prefixes) != 1 || len(matches) == 0 {
+			return nil
+		}
+		prefix := strings.TrimSuffix(spec.StripPrefix.Prefixes[0], "/")
+		for _, match := range matches {
+			if match.Path == nil || match.Path.Type != "PathPrefix" || strings.TrimSuffix(match.Path.Value, "/") != prefix {
+				return nil
+			}
+		}
+		return []httpRouteFilter{{
+			Type:       gatewayFilterRewrite,
+			URLRewrite: &urlRewrite{Path: &httpPathModifier{Type: "ReplacePrefixMatch", ReplacePrefixMatch: "/"}},
+		}}
+	}
+
+	return nil
+}
+
+func newHeaderModifier(headers map[string]string) *headerModifier {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	modifier := &headerModifier{}
+	for _, name := range names {
+		if headers[name] == "" {
+			modifier.Remove = append(modifier.Remove, name)
+			continue
+		}
+		modifier.Set = append(modifier.Set, httpHeader{Name: name, Value: headers[name]})
+	}
+	return modifier
+}
+
+func (g *GatewayAPI) tcpRoutes(ingressRoute *traefikio.IngressRouteTCP) ([]runtime.Object, error) {
+	source := "IngressRouteTCP " + objectName(ingressRoute)
+
+	if tls := ingressRoute.Spec.TLS; tls != nil && !tls.Passthrough {
+		g.report.Add(source, "TLS is terminated by the listeners of Gateway %s/%s, they must be configured accordingly", g.parent.Namespace, g.parent.Name)
+	}
+
+	objects := []runtime.Object{}
+	for i, route := range ingressRoute.Spec.Routes {
+		tree, err := parseRule(g.tcp, route.Match)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", source, err)
+		}
+		if len(route.Middlewares) > 0 {
+			g.report.Add(source, "middlewares of rule %s have no Gateway API equivalent and were dropped", route.Match)
+		}
+
+		var hostnames []string
+		expressed := true
+		for _, c := range disjunctiveForm(tree) {
+			for _, m := range c {
+				switch {
+				case m.Matcher == "HostSNI" && !m.Not && m.Value[0] == "*":
+				case m.Matcher == "HostSNI" && !m.Not:
+					hostnames = append(hostnames, m.Value[0])
+				default:
+					g.report.Add(source, "%s of rule %s can't be expressed in Gateway API, the matching connections are not routed", formatMatcher(m), route.Match)
+					expressed = false
+				}
+			}
+		}
+		if !expressed {
+			continue
+		}
+
+		var refs []backendRef
+		for _, service := range route.Services {
+			ref := backendRef{Name: service.Name}
+			if service.Namespace != "" && service.Namespace != ingressRoute.Namespace {
+				ref.Namespace = service.Namespace
+				g.report.Add(source, "service %s/%s is in another namespace, it must be allowed by a ReferenceGrant", service.Namespace, service.Name)
+			}
+			if service.Port.Type == intstr.String {
+				g.report.Add(source, "port %s of service %s is named, Gateway API backends need the port number", service.Port.StrVal, service.Name)
+			} else {
+				port := service.Port.IntVal
+				ref.Port = &port
+			}
+			if service.Weight != nil {
+				weight := int32(*service.Weight)
+				ref.Weight = &weight
+			}
+			g.reportServiceOptions(source, service.Name, service)
+			refs = append(refs, ref)
+		}
+
+		kind := "TCPRoute"
+		if len(hostnames) > 0 {
+			kind = "TLSRoute"
+		}
+		object, err := newGatewayObject(gatewayObject{
+			APIVersion: gatewayAlphaVersion,
+			Kind:       kind,
+			Metadata:   routeMeta(ingressRoute.ObjectMeta, i, len(ingressRoute.Spec.Routes)),
+			Spec: tcpRouteSpec{
+				ParentRefs: g.parentRefs(ingressRoute.Spec.EntryPoints),
+				Hostnames:  hostnames,
+				Rules:      []tcpRouteRule{{BackendRefs: refs}},
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, object)
+	}
+	return objects, nil
+}
+
+// routeMeta returns the metadata of the i-th of n routes generated from an
+// object, suffixing their names when there are several of them.
+func routeMeta(m v1.ObjectMeta, i, n int) v1.ObjectMeta {
+	meta := v1.ObjectMeta{
+		Name:        m.Name,
+		Namespace:   m.Namespace,
+		Labels:      m.Labels,
+		Annotations: utils.FilterAnnotations(m.Annotations),
+	}
+	if n > 1 {
+		meta.Name = fmt.Sprintf("%s-%d", m.Name, i+1)
+	}
+	return meta
+}
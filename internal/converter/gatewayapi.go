@@ -0,0 +1,133 @@
+package converter
+
+import (
+	"github.com/databotic/traefik-migration-tool/internal/parser"
+	"github.com/databotic/traefik-migration-tool/internal/utils"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The types below mirror the subset of the Kubernetes Gateway API used by the
+// generated routes.
+
+const (
+	gatewayGroup          = "gateway.networking.k8s.io"
+	gatewayAPIVersion     = gatewayGroup + "/v1"
+	gatewayAlphaVersion   = gatewayGroup + "/v1alpha2"
+	gatewayFilterRedirect = "RequestRedirect"
+	gatewayFilterRewrite  = "URLRewrite"
+	gatewayFilterRequest  = "RequestHeaderModifier"
+	gatewayFilterResponse = "ResponseHeaderModifier"
+	gatewayFilterExtRef   = "ExtensionRef"
+)
+
+type gatewayObject struct {
+	APIVersion string        `json:"apiVersion"`
+	Kind       string        `json:"kind"`
+	Metadata   v1.ObjectMeta `json:"metadata"`
+	Spec       interface{}   `json:"spec"`
+}
+
+type parentReference struct {
+	Name        string `json:"name"`
+	Namespace   string `json:"namespace,omitempty"`
+	SectionName string `json:"sectionName,omitempty"`
+}
+
+type httpRouteSpec struct {
+	ParentRefs []parentReference `json:"parentRefs,omitempty"`
+	Hostnames  []string          `json:"hostnames,omitempty"`
+	Rules      []httpRouteRule   `json:"rules,omitempty"`
+}
+
+type httpRouteRule struct {
+	Matches     []httpRouteMatch  `json:"matches,omitempty"`
+	Filters     []httpRouteFilter `json:"filters,omitempty"`
+	BackendRefs []backendRef      `json:"backendRefs,omitempty"`
+}
+
+type httpRouteMatch struct {
+	Path        *httpMatchValue  `json:"path,omitempty"`
+	Headers     []httpMatchValue `json:"headers,omitempty"`
+	QueryParams []httpMatchValue `json:"queryParams,omitempty"`
+	Method      string           `json:"method,omitempty"`
+}
+
+type httpMatchValue struct {
+	Type  string `json:"type"`
+	Name  string `json:"name,omitempty"`
+	Value string `json:"value"`
+}
+
+type httpRouteFilter struct {
+	Type                   string                `json:"type"`
+	RequestHeaderModifier  *headerModifier       `json:"requestHeaderModifier,omitempty"`
+	ResponseHeaderModifier *headerModifier       `json:"responseHeaderModifier,omitempty"`
+	RequestRedirect        *requestRedirect      `json:"requestRedirect,omitempty"`
+	URLRewrite             *urlRewrite           `json:"urlRewrite,omitempty"`
+	ExtensionRef           *localObjectReference `json:"extensionRef,omitempty"`
+}
+
+type headerModifier struct {
+	Set    []httpHeader `json:"set,omitempty"`
+	Remove []string     `json:"remove,omitempty"`
+}
+
+type httpHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type requestRedirect struct {
+	Scheme     string `json:"scheme,omitempty"`
+	Port       *int   `json:"port,omitempty"`
+	StatusCode int    `json:"statusCode,omitempty"`
+}
+
+type urlRewrite struct {
+	Path *httpPathModifier `json:"path,omitempty"`
+}
+
+type httpPathModifier struct {
+	Type               string `json:"type"`
+	ReplaceFullPath    string `json:"replaceFullPath,omitempty"`
+	ReplacePrefixMatch string `json:"replacePrefixMatch,omitempty"`
+}
+
+type localObjectReference struct {
+	Group string `json:"group"`
+	Kind  string `json:"kind"`
+	Name  string `json:"name"`
+}
+
+type backendRef struct {
+	Group     string `json:"group,omitempty"`
+	Kind      string `json:"kind,omitempty"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	Port      *int32 `json:"port,omitempty"`
+	Weight    *int32 `json:"weight,omitempty"`
+}
+
+type tcpRouteSpec struct {
+	ParentRefs []parentReference `json:"parentRefs,omitempty"`
+	Hostnames  []string          `json:"hostnames,omitempty"`
+	Rules      []tcpRouteRule    `json:"rules,omitempty"`
+}
+
+type tcpRouteRule struct {
+	BackendRefs []backendRef `json:"backendRefs,omitempty"`
+}
+
+// newGatewayObject builds an object of the Gateway API, which is not
+// registered in the scheme and is encoded as such.
+func newGatewayObject(object gatewayObject) (*parser.Unknown, error) {
+	content, err := utils.ToMap(object)
+	if err != nil {
+		return nil, err
+	}
+
+	u := &parser.Unknown{}
+	u.Object = content
+	u.Source, err = utils.EncodeYaml(content)
+	return u, err
+}
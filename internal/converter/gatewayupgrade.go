@@ -0,0 +1,165 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/databotic/traefik-migration-tool/internal/parser"
+	"github.com/databotic/traefik-migration-tool/internal/report"
+	"github.com/databotic/traefik-migration-tool/internal/utils"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	gatewayBetaVersion = gatewayGroup + "/v1beta1"
+
+	// gatewayControllerName is the controllerName of the GatewayClasses
+	// handled by Traefik.
+	gatewayControllerName = "traefik.io/gateway-controller"
+)
+
+// gatewayUpgradeKinds are the Gateway API kinds read by the v2 Gateway
+// provider, which used the v1alpha2 version of Gateway API v0.4.
+var gatewayUpgradeKinds = []string{"GatewayClass", "Gateway", "HTTPRoute", "TCPRoute", "TLSRoute", "ReferencePolicy", "ReferenceGrant"}
+
+// GatewayUpgrade moves the Gateway API objects read by the v2 Gateway
+// provider to the versions read by v3: GatewayClass, Gateway and HTTPRoute
+// to v1, ReferencePolicy to the v1beta1 ReferenceGrant. TCPRoute and TLSRoute
+// only exist in v1alpha2 and are kept in it.
+type GatewayUpgrade struct {
+	report *report.Report
+}
+
+func NewGatewayUpgrade(r *report.Report) *GatewayUpgrade {
+	return &GatewayUpgrade{report: r}
+}
+
+func (g *GatewayUpgrade) Transform(object runtime.Object) (runtime.Object, error) {
+	u, ok := object.(*parser.Unknown)
+	if !ok {
+		return object.DeepCopyObject(), nil
+	}
+	upgraded := u.DeepCopyObject().(*parser.Unknown)
+	source := objectSource(upgraded)
+	content := upgraded.Object
+
+	changed := false
+	if apiVersion, kind, ok := gatewayUpgradeVersion(upgraded); ok {
+		if kind != upgraded.GetKind() {
+			g.report.Add(source, "%s was renamed %s in %s", upgraded.GetKind(), kind, apiVersion)
+		}
+		upgraded.SetAPIVersion(apiVersion)
+		upgraded.SetKind(kind)
+		changed = true
+	}
+
+	switch upgraded.GetKind() {
+	case "GatewayClass":
+		controllerName, _, _ := unstructured.NestedString(content, "spec", "controllerName")
+		if controllerName != gatewayControllerName && strings.Contains(controllerName, "traefik") {
+			g.report.Add(source, "controllerName %s was replaced by %s, the one of Traefik v3", controllerName, gatewayControllerName)
+			if err := unstructured.SetNestedField(content, gatewayControllerName, "spec", "controllerName"); err != nil {
+				return nil, err
+			}
+			changed = true
+		}
+		if _, ok, _ := unstructured.NestedFieldNoCopy(content, "spec", "parametersRef", "scope"); ok {
+			g.report.Add(source, "spec.parametersRef.scope was removed, the scope is Namespace when spec.parametersRef.namespace is set")
+			unstructured.RemoveNestedField(content, "spec", "parametersRef", "scope")
+			changed = true
+		}
+	case "HTTPRoute", "TCPRoute", "TLSRoute":
+		for _, location := range g.rewriteTraefikGroups(content) {
+			g.report.Add(source, "%s now references %s", location, traefikGroup)
+			changed = true
+		}
+		if upgraded.GetKind() != "HTTPRoute" {
+			g.report.Add(source, "%s is only served in v1alpha2, the kubernetesGateway provider must enable experimentalChannel", upgraded.GetKind())
+		}
+	}
+
+	if !changed {
+		return upgraded, nil
+	}
+
+	var err error
+	upgraded.Source, err = utils.EncodeYaml(content)
+	return upgraded, err
+}
+
+// rewriteTraefikGroups moves the extensionRef filters and backendRefs
+// referencing traefik.containo.us objects to traefik.io, and returns the
+// rewritten locations.
+func (g *GatewayUpgrade) rewriteTraefikGroups(content map[string]interface{}) []string {
+	var locations []string
+	rewrite := func(path string, ref map[string]interface{}) {
+		if group, _ := ref["group"].(string); group == containousGroup {
+			ref["group"] = traefikGroup
+			locations = append(locations, path+".group")
+		}
+	}
+
+	rules, _, _ := unstructured.NestedSlice(content, "spec", "rules")
+	for i, r := range rules {
+		rule, _ := r.(map[string]interface{})
+		prefix := fmt.Sprintf("spec.rules[%d]", i)
+
+		filters, _ := rule["filters"].([]interface{})
+		rewriteFilters(prefix, filters, rewrite)
+
+		backendRefs, _ := rule["backendRefs"].([]interface{})
+		for j, b := range backendRefs {
+			backendRef, _ := b.(map[string]interface{})
+			path := fmt.Sprintf("%s.backendRefs[%d]", prefix, j)
+			rewrite(path, backendRef)
+
+			filters, _ := backendRef["filters"].([]interface{})
+			rewriteFilters(path, filters, rewrite)
+		}
+	}
+	if locations != nil {
+		// NestedSlice returns a copy of the rules.
+		_ = unstructured.SetNestedSlice(content, rules, "spec", "rules")
+	}
+	return locations
+}
+
+func rewriteFilters(prefix string, filters []interface{}, rewrite func(string, map[string]interface{})) {
+	for i, f := range filters {
+		filter, _ := f.(map[string]interface{})
+		if ref, ok := filter["extensionRef"].(map[string]interface{}); ok {
+			rewrite(fmt.Sprintf("%s.filters[%d].extensionRef", prefix, i), ref)
+		}
+	}
+}
+
+// gatewayUpgradeVersion returns the apiVersion and kind a Gateway API object
+// is moved to, if it is not read by v3 as it is.
+func gatewayUpgradeVersion(object runtime.Object) (string, string, bool) {
+	gvk := object.GetObjectKind().GroupVersionKind()
+	if gvk.Group != gatewayGroup {
+		return "", "", false
+	}
+
+	switch gvk.Kind {
+	case "GatewayClass", "Gateway", "HTTPRoute":
+		if gvk.Version == "v1alpha2" || gvk.Version == "v1beta1" {
+			return gatewayAPIVersion, gvk.Kind, true
+		}
+	case "ReferencePolicy":
+		return gatewayBetaVersion, "ReferenceGrant", true
+	case "ReferenceGrant":
+		if gvk.Version == "v1alpha2" {
+			return gatewayBetaVersion, gvk.Kind, true
+		}
+	}
+	return "", "", false
+}
+
+// outdatedGatewayObject reports whether object is a Gateway API object which
+// must be moved to another version for v3.
+func outdatedGatewayObject(object runtime.Object) bool {
+	_, _, ok := gatewayUpgradeVersion(object)
+	return ok
+}
@@ -0,0 +1,204 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/databotic/traefik-migration-tool/internal/report"
+	"github.com/databotic/traefik-migration-tool/internal/utils"
+	"github.com/traefik/traefik/v3/pkg/config/dynamic"
+	"github.com/traefik/traefik/v3/pkg/config/label"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	annotationsPrefix          = "traefik.ingress.kubernetes.io/"
+	annotationRouterMiddleware = annotationsPrefix + "router.middlewares"
+	annotationPathMatcher      = annotationsPrefix + "router.pathmatcher"
+	annotationServiceScheme    = annotationsPrefix + "service.serversscheme"
+	annotationServiceNativeLB  = annotationsPrefix + "service.nativelb"
+
+	defaultPathMatcher = "PathPrefix"
+)
+
+var annotationsRegex = regexp.MustCompile(`(.+)\.(\w+)\.(\d+)\.(.+)`)
+
+// routerConfig holds the router annotations of an Ingress, decoded the way
+// the kubernetes ingress provider does.
+type routerConfig struct {
+	Router *ingressRouter `json:"router,omitempty"`
+}
+
+type ingressRouter struct {
+	PathMatcher string                   `json:"pathMatcher,omitempty"`
+	EntryPoints []string                 `json:"entryPoints,omitempty"`
+	Middlewares []string                 `json:"middlewares,omitempty"`
+	Priority    int                      `json:"priority,omitempty"`
+	TLS         *dynamic.RouterTLSConfig `json:"tls,omitempty" label:"allowEmpty"`
+}
+
+// serviceConfig holds the service annotations of a Service, decoded the way
+// the kubernetes ingress provider does.
+type serviceConfig struct {
+	Service *ingressService `json:"service,omitempty"`
+}
+
+type ingressService struct {
+	ServersScheme    string          `json:"serversScheme,omitempty"`
+	ServersTransport string          `json:"serversTransport,omitempty"`
+	PassHostHeader   *bool           `json:"passHostHeader"`
+	Sticky           *dynamic.Sticky `json:"sticky,omitempty" label:"allowEmpty"`
+	NativeLB         *bool           `json:"nativeLB,omitempty"`
+}
+
+// Ingress validates and rewrites the Traefik annotations of
+// networking.k8s.io/v1 Ingress objects.
+type Ingress struct {
+	middlewares *middlewareIndex
+	report      *report.Report
+}
+
+func NewIngress(middlewares *middlewareIndex, r *report.Report) *Ingress {
+	return &Ingress{middlewares: middlewares, report: r}
+}
+
+func (i *Ingress) Transform(object runtime.Object) (runtime.Object, error) {
+	v2Ingress, ok := object.(*networkingv1.Ingress)
+	if !ok {
+		return nil, fmt.Errorf("unexpected object %T, expected an ingress", object)
+	}
+
+	ing := v2Ingress.DeepCopy()
+	source := fmt.Sprintf("ingress %s/%s", ing.Namespace, ing.Name)
+	if len(ing.Annotations) > 0 {
+		ing.Annotations = utils.FilterAnnotations(ing.Annotations)
+	}
+
+	if refs, ok := ing.Annotations[annotationRouterMiddleware]; ok {
+		ing.Annotations[annotationRouterMiddleware] = i.middlewares.checkRefs(source, ing.Namespace, refs, i.report)
+	}
+
+	config := &routerConfig{}
+	if err := label.Decode(convertAnnotations(ing.Annotations), config, "traefik.router."); err != nil {
+		i.report.Add(source, "invalid router annotations: %v", err)
+	}
+
+	matcher := defaultPathMatcher
+	if config.Router != nil && config.Router.PathMatcher != "" {
+		matcher = config.Router.PathMatcher
+	}
+	switch matcher {
+	case "Path", "PathPrefix":
+		if err := i.transformPaths(source, ing, matcher); err != nil {
+			return nil, err
+		}
+	case "PathRegexp":
+	default:
+		i.report.Add(source, "path matcher %s is not supported by v3", matcher)
+	}
+
+	return ing, nil
+}
+
+// transformPaths rewrites templated paths, which are matched literally in v3,
+// to regular expressions matched with PathRegexp.
+func (i *Ingress) transformPaths(source string, ing *networkingv1.Ingress, matcher string) error {
+	var paths []*networkingv1.HTTPIngressPath
+	templated := false
+	for _, rule := range ing.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for j := range rule.HTTP.Paths {
+			path := &rule.HTTP.Paths[j]
+			if path.PathType != nil && *path.PathType != networkingv1.PathTypeImplementationSpecific {
+				continue
+			}
+			paths = append(paths, path)
+			templated = templated || strings.Contains(path.Path, "{")
+		}
+	}
+	if !templated {
+		return nil
+	}
+
+	typ := utils.RegexpTypePrefix
+	if matcher == "Path" {
+		typ = utils.RegexpTypePath
+	}
+
+	for _, path := range paths {
+		pattern, err := utils.RouteRegexp(path.Path, typ)
+		if err != nil {
+			return fmt.Errorf("%s: %w", source, err)
+		}
+		if pattern == path.Path {
+			pattern = "^" + regexp.QuoteMeta(path.Path)
+			if typ == utils.RegexpTypePath {
+				pattern += "$"
+			}
+		}
+		path.Path = pattern
+	}
+
+	if ing.Annotations == nil {
+		ing.Annotations = map[string]string{}
+	}
+	ing.Annotations[annotationPathMatcher] = "PathRegexp"
+	i.report.Add(source, "templated paths are matched literally by %s in v3, they were rewritten to PathRegexp", matcher)
+	return nil
+}
+
+// Service validates the Traefik annotations of Service objects, which are
+// read by the kubernetes ingress provider.
+type Service struct {
+	report *report.Report
+}
+
+func NewService(r *report.Report) *Service {
+	return &Service{report: r}
+}
+
+func (s *Service) Transform(object runtime.Object) (runtime.Object, error) {
+	labels := convertAnnotations(annotationsOf(object))
+	if len(labels) == 0 {
+		return object, nil
+	}
+
+	source := "service " + objectName(object)
+	config := &serviceConfig{}
+	if err := label.Decode(labels, config, "traefik.service."); err != nil {
+		s.report.Add(source, "invalid service annotations: %v", err)
+	}
+
+	annotations := annotationsOf(object)
+	if scheme, ok := annotations[annotationServiceScheme]; ok && scheme != "http" && scheme != "https" && scheme != "h2c" {
+		s.report.Add(source, "unsupported servers scheme %s", scheme)
+	}
+	if _, ok := annotations[annotationServiceNativeLB]; ok {
+		s.report.Add(source, "%s now overrides the nativeLBByDefault option of the provider", annotationServiceNativeLB)
+	}
+
+	return object, nil
+}
+
+func convertAnnotations(annotations map[string]string) map[string]string {
+	result := make(map[string]string)
+
+	for key, value := range annotations {
+		if !strings.HasPrefix(key, annotationsPrefix) {
+			continue
+		}
+
+		newKey := strings.ReplaceAll(key, "ingress.kubernetes.io/", "")
+		if annotationsRegex.MatchString(newKey) {
+			newKey = annotationsRegex.ReplaceAllString(newKey, "$1.$2[$3].$4")
+		}
+
+		result[newKey] = value
+	}
+
+	return result
+}
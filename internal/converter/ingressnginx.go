@@ -0,0 +1,510 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/databotic/traefik-migration-tool/internal/report"
+	"github.com/databotic/traefik-migration-tool/internal/utils"
+	"github.com/traefik/traefik/v3/pkg/config/dynamic"
+	traefikio "github.com/traefik/traefik/v3/pkg/provider/kubernetes/crd/traefikio/v1alpha1"
+	networkingv1 "k8s.io/api/networking/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const nginxAnnotationsPrefix = "nginx.ingress.kubernetes.io/"
+
+// nginxHTTPEntryPoint is the entry point the https redirections of the TLS
+// Ingresses are served on, the plain http one of the Traefik Helm chart.
+const nginxHTTPEntryPoint = "web"
+
+// nginx defaults of the annotations mapped to middleware options.
+const (
+	nginxBurstMultiplier = 5
+	nginxCORSMethods     = "GET, PUT, POST, DELETE, PATCH, OPTIONS"
+	nginxCORSHeaders     = "DNT,Keep-Alive,User-Agent,X-Requested-With,If-Modified-Since,Cache-Control,Content-Type,Range,Authorization"
+	nginxCORSMaxAge      = 1728000
+	nginxSessionCookie   = "INGRESSCOOKIE"
+)
+
+// nginxAnnotations are the ingress-nginx annotations converted to IngressRoute
+// and Middleware options.
+var nginxAnnotations = []string{
+	"affinity",
+	"app-root",
+	"auth-realm",
+	"auth-response-headers",
+	"auth-secret",
+	"auth-type",
+	"auth-url",
+	"backend-protocol",
+	"cors-allow-credentials",
+	"cors-allow-headers",
+	"cors-allow-methods",
+	"cors-allow-origin",
+	"cors-expose-headers",
+	"cors-max-age",
+	"enable-cors",
+	"force-ssl-redirect",
+	"limit-burst-multiplier",
+	"limit-connections",
+	"limit-rpm",
+	"limit-rps",
+	"permanent-redirect",
+	"proxy-body-size",
+	"session-cookie-name",
+	"session-cookie-samesite",
+	"session-cookie-secure",
+	"ssl-redirect",
+	"temporal-redirect",
+	"rewrite-target",
+	"upstream-vhost",
+	"use-regex",
+	"whitelist-source-range",
+	"x-forwarded-prefix",
+}
+
+var nginxCaptureGroup = regexp.MustCompile(`\$(\d+)`)
+
+// IngressNginx generates IngressRoutes and Middlewares from the Ingress
+// objects handled by ingress-nginx. The other Ingress objects are given to
+// the converter it replaced.
+type IngressNginx struct {
+	ingresses *IngressToIngressRoute
+	fallback  ConvertFactory
+	report    *report.Report
+}
+
+// ConvertIngressNginx generates IngressRoutes and Middlewares from the
+// Ingress objects of the nginx class or carrying ingress-nginx annotations.
+func (c *Converter) ConvertIngressNginx() {
+	rules := c.converters["IngressRoute.traefik.containo.us"].(*IngressRoute)
+	ingresses := NewIngressToIngressRoute(rules, c.middlewares, c.report)
+
+	for _, kind := range []string{"Ingress.networking.k8s.io", "Ingress.extensions"} {
+		c.converters[kind] = &IngressNginx{ingresses: ingresses, fallback: c.converters[kind], report: c.report}
+	}
+}
+
+func (i *IngressNginx) Transform(object runtime.Object) (runtime.Object, error) {
+	if i.fallback == nil {
+		return object.DeepCopyObject(), nil
+	}
+	return i.fallback.Transform(object)
+}
+
+func (i *IngressNginx) TransformAll(object runtime.Object) ([]runtime.Object, error) {
+	ing, err := networkingIngress(object)
+	if err != nil {
+		return nil, err
+	}
+	if !isNginxIngress(ing) {
+		if fallback, ok := i.fallback.(MultiConvertFactory); ok {
+			return fallback.TransformAll(object)
+		}
+		return nil, nil
+	}
+
+	source := fmt.Sprintf("ingress %s/%s", ing.Namespace, ing.Name)
+	annotations := map[string]string{}
+	for _, key := range sortedKeys(ing.Annotations) {
+		name, ok := strings.CutPrefix(key, nginxAnnotationsPrefix)
+		if !ok {
+			continue
+		}
+		if !contains(nginxAnnotations, name) {
+			i.report.Add(source, "annotation %s has no Traefik equivalent and was dropped", key)
+			continue
+		}
+		annotations[name] = ing.Annotations[key]
+	}
+
+	ingressRoute := &traefikio.IngressRoute{
+		TypeMeta:   v1.TypeMeta{Kind: "IngressRoute", APIVersion: utils.APIVersion},
+		ObjectMeta: objectMeta(ing.ObjectMeta),
+	}
+	ingressRoute.Annotations = withoutNginxAnnotations(ingressRoute.Annotations)
+
+	middlewares, err := i.middlewares(source, ing, annotations)
+	if err != nil {
+		return nil, err
+	}
+	var refs []traefikio.MiddlewareRef
+	for _, m := range middlewares {
+		refs = append(refs, traefikio.MiddlewareRef{Name: m.Name})
+	}
+
+	regex := annotations["use-regex"] == "true" || annotations["rewrite-target"] != ""
+	paths := nginxPaths(ing)
+
+	for _, r := range ing.Spec.Rules {
+		if r.HTTP == nil {
+			continue
+		}
+		for _, path := range r.HTTP.Paths {
+			pathMatcher := ""
+			if regex {
+				// ingress-nginx matches the paths of regex locations as case
+				// insensitive prefixes, whatever their type.
+				path.Path = "(?i)^" + path.Path
+				path.PathType = nil
+				pathMatcher = "PathRegexp"
+			}
+			match, err := i.ingresses.match(r.Host, path, pathMatcher)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", source, err)
+			}
+
+			route, ok := i.route(source, annotations, path.Backend, refs)
+			if !ok {
+				continue
+			}
+			route.Match = match
+
+			if _, ok := annotations["rewrite-target"]; ok {
+				route.Middlewares = append(route.Middlewares, traefikio.MiddlewareRef{
+					Name: rewriteTargetName(ing, paths, strings.TrimPrefix(path.Path, "(?i)^")),
+				})
+			}
+			ingressRoute.Spec.Routes = append(ingressRoute.Spec.Routes, route)
+		}
+	}
+
+	if ing.Spec.DefaultBackend != nil {
+		if route, ok := i.route(source, annotations, *ing.Spec.DefaultBackend, refs); ok {
+			// the default backend catches the requests no rule matched.
+			route.Match = "PathPrefix(`/`)"
+			route.Priority = 1
+			ingressRoute.Spec.Routes = append(ingressRoute.Spec.Routes, route)
+		}
+	}
+
+	ingressRoute.Spec.TLS = i.ingresses.tls(source, ing, nil)
+
+	objects := []runtime.Object{ingressRoute}
+	if ingressRoute.Spec.TLS != nil && redirectsHTTPS(middlewares) {
+		objects = append(objects, i.httpRedirect(source, ingressRoute, ing.Name+"-redirect-https"))
+	}
+	for _, m := range middlewares {
+		objects = append(objects, m)
+	}
+	if target, ok := annotations["rewrite-target"]; ok {
+		for _, path := range paths {
+			middleware := newMiddleware(ing, rewriteTargetName(ing, paths, path))
+			// nginx rewrites the whole path of the requests matching the
+			// location regex.
+			middleware.Spec.ReplacePathRegex = &dynamic.ReplacePathRegex{
+				Regex:       "(?i)^" + path + ".*",
+				Replacement: nginxCaptureGroup.ReplaceAllString(target, "$${$1}"),
+			}
+			objects = append(objects, middleware)
+		}
+	}
+	return objects, nil
+}
+
+// httpRedirect moves the https redirection of a TLS IngressRoute, whose
+// routers only serve https, to an IngressRoute of the plain http entry point
+// with the same routes.
+func (i *IngressNginx) httpRedirect(source string, ingressRoute *traefikio.IngressRoute, redirect string) *traefikio.IngressRoute {
+	http := ingressRoute.DeepCopy()
+	http.Name += "-http"
+	http.Spec.EntryPoints = []string{nginxHTTPEntryPoint}
+	http.Spec.TLS = nil
+
+	for j := range ingressRoute.Spec.Routes {
+		route := &ingressRoute.Spec.Routes[j]
+		var refs []traefikio.MiddlewareRef
+		for _, ref := range route.Middlewares {
+			if ref.Name != redirect {
+				refs = append(refs, ref)
+			}
+		}
+		route.Middlewares = refs
+		http.Spec.Routes[j].Middlewares = []traefikio.MiddlewareRef{{Name: redirect}}
+	}
+
+	i.report.Add(source, "https redirection is served by IngressRoute %s on the %s entry point, which must exist", http.Name, nginxHTTPEntryPoint)
+	return http
+}
+
+// redirectsHTTPS reports whether middlewares hold the https redirection.
+func redirectsHTTPS(middlewares []*traefikio.Middleware) bool {
+	for _, m := range middlewares {
+		if strings.HasSuffix(m.Name, "-redirect-https") {
+			return true
+		}
+	}
+	return false
+}
+
+// route builds the route of an Ingress backend, with the service options of
+// the annotations.
+func (i *IngressNginx) route(source string, annotations map[string]string, backend networkingv1.IngressBackend, refs []traefikio.MiddlewareRef) (traefikio.Route, bool) {
+	service, ok := i.ingresses.service(source, backend)
+	if !ok {
+		return traefikio.Route{}, false
+	}
+
+	switch protocol := strings.ToUpper(annotations["backend-protocol"]); protocol {
+	case "", "HTTP":
+	case "HTTPS", "GRPCS":
+		service.Scheme = "https"
+	case "GRPC":
+		service.Scheme = "h2c"
+	default:
+		i.report.Add(source, "backend protocol %s is not supported by IngressRoutes", protocol)
+	}
+
+	if annotations["affinity"] == "cookie" {
+		cookie := &dynamic.Cookie{
+			Name:     nginxSessionCookie,
+			Secure:   annotations["session-cookie-secure"] == "true",
+			SameSite: strings.ToLower(annotations["session-cookie-samesite"]),
+		}
+		if name, ok := annotations["session-cookie-name"]; ok {
+			cookie.Name = name
+		}
+		service.Sticky = &dynamic.Sticky{Cookie: cookie}
+	}
+
+	return traefikio.Route{
+		Kind:        "Rule",
+		Services:    []traefikio.Service{service},
+		Middlewares: append([]traefikio.MiddlewareRef{}, refs...),
+	}, true
+}
+
+// middlewares builds the middlewares of the Ingress annotations, shared by
+// all its routes.
+func (i *IngressNginx) middlewares(source string, ing *networkingv1.Ingress, annotations map[string]string) ([]*traefikio.Middleware, error) {
+	var middlewares []*traefikio.Middleware
+	add := func(suffix string, spec traefikio.MiddlewareSpec) {
+		middleware := newMiddleware(ing, ing.Name+"-"+suffix)
+		middleware.Spec = spec
+		middlewares = append(middlewares, middleware)
+	}
+
+	// ingress-nginx redirects to https by default when the Ingress has TLS.
+	if annotations["force-ssl-redirect"] == "true" || (len(ing.Spec.TLS) > 0 && annotations["ssl-redirect"] != "false") {
+		add("redirect-https", traefikio.MiddlewareSpec{RedirectScheme: &dynamic.RedirectScheme{Scheme: "https", Permanent: true}})
+	}
+	if target, ok := annotations["permanent-redirect"]; ok {
+		add("redirect", traefikio.MiddlewareSpec{RedirectRegex: &dynamic.RedirectRegex{Regex: ".*", Replacement: target, Permanent: true}})
+	} else if target, ok := annotations["temporal-redirect"]; ok {
+		add("redirect", traefikio.MiddlewareSpec{RedirectRegex: &dynamic.RedirectRegex{Regex: ".*", Replacement: target}})
+	}
+	if root, ok := annotations["app-root"]; ok {
+		add("app-root", traefikio.MiddlewareSpec{RedirectRegex: &dynamic.RedirectRegex{
+			Regex:       `^(https?://[^/]+)/$`,
+			Replacement: "${1}" + root,
+		}})
+	}
+
+	if sourceRange, ok := annotations["whitelist-source-range"]; ok {
+		add("ipallowlist", traefikio.MiddlewareSpec{IPAllowList: &dynamic.IPAllowList{SourceRange: splitList(sourceRange, ",")}})
+	}
+
+	if auth := i.auth(source, annotations); auth != nil {
+		add("auth", *auth)
+	}
+
+	multiplier := int64(nginxBurstMultiplier)
+	if value, ok := annotations["limit-burst-multiplier"]; ok {
+		m, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid limit-burst-multiplier %s", source, value)
+		}
+		multiplier = m
+	}
+	for _, limit := range []struct{ annotation, suffix, period string }{
+		{"limit-rps", "ratelimit-rps", "1s"},
+		{"limit-rpm", "ratelimit-rpm", "1m"},
+	} {
+		value, ok := annotations[limit.annotation]
+		if !ok {
+			continue
+		}
+		average, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid %s %s", source, limit.annotation, value)
+		}
+		period := intstr.FromString(limit.period)
+		burst := average * multiplier
+		add(limit.suffix, traefikio.MiddlewareSpec{RateLimit: &traefikio.RateLimit{Average: average, Period: &period, Burst: &burst}})
+	}
+	if value, ok := annotations["limit-connections"]; ok {
+		amount, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid limit-connections %s", source, value)
+		}
+		add("inflightreq", traefikio.MiddlewareSpec{InFlightReq: &dynamic.InFlightReq{Amount: amount}})
+	}
+
+	if annotations["enable-cors"] == "true" {
+		add("cors", traefikio.MiddlewareSpec{Headers: nginxCORS(annotations)})
+	}
+
+	headers := &dynamic.Headers{CustomRequestHeaders: map[string]string{}}
+	if host, ok := annotations["upstream-vhost"]; ok {
+		headers.CustomRequestHeaders["Host"] = host
+	}
+	if prefix, ok := annotations["x-forwarded-prefix"]; ok {
+		headers.CustomRequestHeaders["X-Forwarded-Prefix"] = prefix
+	}
+	if len(headers.CustomRequestHeaders) > 0 {
+		add("headers", traefikio.MiddlewareSpec{Headers: headers})
+	}
+
+	if value, ok := annotations["proxy-body-size"]; ok {
+		size, err := nginxSize(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid proxy-body-size %s", source, value)
+		}
+		if size > 0 {
+			add("buffering", traefikio.MiddlewareSpec{Buffering: &dynamic.Buffering{MaxRequestBodyBytes: size}})
+		}
+	}
+
+	return middlewares, nil
+}
+
+// auth maps the external and basic authentication annotations to an
+// authentication middleware.
+func (i *IngressNginx) auth(source string, annotations map[string]string) *traefikio.MiddlewareSpec {
+	if url, ok := annotations["auth-url"]; ok {
+		return &traefikio.MiddlewareSpec{ForwardAuth: &traefikio.ForwardAuth{
+			Address:             url,
+			AuthResponseHeaders: splitList(annotations["auth-response-headers"], ","),
+		}}
+	}
+
+	authType, ok := annotations["auth-type"]
+	if !ok {
+		return nil
+	}
+	secret := annotations["auth-secret"]
+	if _, name, found := strings.Cut(secret, "/"); found {
+		i.report.Add(source, "auth secret %s must be in the namespace of the ingress, its name was kept", secret)
+		secret = name
+	}
+	i.report.Add(source, "auth secret %s must hold the htpasswd entries in a users key instead of auth", secret)
+
+	switch authType {
+	case "basic":
+		return &traefikio.MiddlewareSpec{BasicAuth: &traefikio.BasicAuth{Secret: secret, Realm: annotations["auth-realm"]}}
+	case "digest":
+		return &traefikio.MiddlewareSpec{DigestAuth: &traefikio.DigestAuth{Secret: secret, Realm: annotations["auth-realm"]}}
+	}
+	i.report.Add(source, "unknown auth type %s, the authentication was dropped", authType)
+	return nil
+}
+
+// nginxCORS returns the headers middleware answering CORS requests as
+// ingress-nginx does, with its defaults.
+func nginxCORS(annotations map[string]string) *dynamic.Headers {
+	value := func(name, def string) string {
+		if v, ok := annotations[name]; ok {
+			return v
+		}
+		return def
+	}
+
+	headers := &dynamic.Headers{
+		AccessControlAllowOriginList:  splitList(value("cors-allow-origin", "*"), ","),
+		AccessControlAllowMethods:     splitList(value("cors-allow-methods", nginxCORSMethods), ","),
+		AccessControlAllowHeaders:     splitList(value("cors-allow-headers", nginxCORSHeaders), ","),
+		AccessControlAllowCredentials: value("cors-allow-credentials", "true") == "true",
+		AccessControlExposeHeaders:    splitList(annotations["cors-expose-headers"], ","),
+		AccessControlMaxAge:           nginxCORSMaxAge,
+	}
+	if maxAge, err := strconv.ParseInt(annotations["cors-max-age"], 10, 64); err == nil {
+		headers.AccessControlMaxAge = maxAge
+	}
+	return headers
+}
+
+// nginxSize parses an nginx size, in bytes or with a k, m or g suffix.
+func nginxSize(value string) (int64, error) {
+	if value == "" {
+		return 0, fmt.Errorf("empty size")
+	}
+
+	multiplier := int64(1)
+	switch strings.ToLower(value[len(value)-1:]) {
+	case "k":
+		multiplier = 1 << 10
+	case "m":
+		multiplier = 1 << 20
+	case "g":
+		multiplier = 1 << 30
+	}
+	if multiplier > 1 {
+		value = value[:len(value)-1]
+	}
+
+	size, err := strconv.ParseInt(value, 10, 64)
+	return size * multiplier, err
+}
+
+// isNginxIngress reports whether an Ingress is handled by ingress-nginx.
+func isNginxIngress(ing *networkingv1.Ingress) bool {
+	class := ingressClass(ing)
+	if class == "" && ing.Spec.IngressClassName != nil {
+		class = *ing.Spec.IngressClassName
+	}
+	if class != "" {
+		return strings.Contains(class, "nginx")
+	}
+
+	for key := range ing.Annotations {
+		if strings.HasPrefix(key, nginxAnnotationsPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// nginxPaths returns the distinct paths of an Ingress, in order.
+func nginxPaths(ing *networkingv1.Ingress) []string {
+	var paths []string
+	for _, path := range ingressPaths(ing) {
+		if !contains(paths, path) {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+func withoutNginxAnnotations(annotations map[string]string) map[string]string {
+	kept := map[string]string{}
+	for key, value := range annotations {
+		if strings.HasPrefix(key, nginxAnnotationsPrefix) || key == annotationIngressClass {
+			continue
+		}
+		kept[key] = value
+	}
+	if len(kept) == 0 {
+		return nil
+	}
+	return kept
+}
+
+// rewriteTargetName returns the name of the middleware rewriting path. The
+// rewrite depends on the path regex, so each path has its own middleware.
+func rewriteTargetName(ing *networkingv1.Ingress, paths []string, path string) string {
+	name := ing.Name + "-rewrite-target"
+	if len(paths) < 2 {
+		return name
+	}
+	for i, p := range paths {
+		if p == path {
+			return name + "-" + strconv.Itoa(i+1)
+		}
+	}
+	return name
+}
@@ -47,11 +47,8 @@ func (t *IngressRoute) Transform(object runtime.Object) (runtime.Object, error)
 	}
 
 	v3IngressRoute := &traefikio.IngressRoute{
-		TypeMeta: v1.TypeMeta{Kind: ingressRoute.Kind, APIVersion: utils.APIVersion},
-		ObjectMeta: v1.ObjectMeta{
-			Name: ingressRoute.ObjectMeta.Name, Namespace: ingressRoute.ObjectMeta.Namespace,
-			Annotations: utils.FilterAnnotations(ingressRoute.Annotations), Labels: ingressRoute.Labels,
-		},
+		TypeMeta:   v1.TypeMeta{Kind: ingressRoute.Kind, APIVersion: utils.APIVersion},
+		ObjectMeta: objectMeta(ingressRoute.ObjectMeta),
 		Spec: traefikio.IngressRouteSpec{
 			EntryPoints: ingressRoute.Spec.EntryPoints,
 		},
@@ -79,34 +76,47 @@ func (t *IngressRoute) Transform(object runtime.Object) (runtime.Object, error)
 func (t *IngressRoute) transformRoute(v2Routes []containous.Route) ([]traefikio.Route, error) {
 	var routes []traefikio.Route
 	for _, r := range v2Routes {
-		if err := t.checkRoute(r.Match, "v2"); err != nil {
-			return nil, err
-		}
-
 		route, err := utils.AsType[traefikio.Route](r)
 		if err != nil {
 			return nil, err
 		}
 
-		if m := t.transformRule(r.Match); len(m) > 0 {
-			if err := t.checkRoute(m, "v3"); err != nil {
-				return nil, err
-			}
-			route.Match = m
+		m, err := t.ConvertRule(r.Match)
+		if err != nil {
+			return nil, err
 		}
+		route.Match = m
 
 		routes = append(routes, *route)
 	}
 	return routes, nil
 }
 
-func (t *IngressRoute) transformRule(rule string) string {
+// ConvertRule rewrites a v2 router rule to the v3 syntax. Both the given rule
+// and the result are validated against the muxer of their syntax.
+func (t *IngressRoute) ConvertRule(rule string) (string, error) {
+	if err := t.checkRoute(rule, "v2"); err != nil {
+		return "", err
+	}
+
+	m := transformRule(rule, rewriteHTTPFunc)
+	if len(m) == 0 {
+		return rule, nil
+	}
+
+	if err := t.checkRoute(m, "v3"); err != nil {
+		return "", err
+	}
+	return m, nil
+}
+
+func transformRule(rule string, rewriteFunc map[string]func(v []string) string) string {
 	return rulePattern.ReplaceAllStringFunc(rule, func(match string) string {
 		functionName := rulePattern.FindStringSubmatch(match)[1]
 		vaules := rulePattern.FindStringSubmatch(match)[2]
 		arguments := strings.Split(strings.ReplaceAll(strings.ReplaceAll(vaules, "`", ""), " ", ""), ",")
 
-		if funcName, ok := rewriteHTTPFunc[functionName]; ok {
+		if funcName, ok := rewriteFunc[functionName]; ok {
 			return funcName(arguments)
 		}
 
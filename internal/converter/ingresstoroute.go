@@ -0,0 +1,317 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/databotic/traefik-migration-tool/internal/report"
+	"github.com/databotic/traefik-migration-tool/internal/utils"
+	"github.com/traefik/traefik/v3/pkg/config/dynamic"
+	"github.com/traefik/traefik/v3/pkg/config/label"
+	traefikio "github.com/traefik/traefik/v3/pkg/provider/kubernetes/crd/traefikio/v1alpha1"
+	networkingv1 "k8s.io/api/networking/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const annotationIngressClass = "kubernetes.io/ingress.class"
+
+// routerAnnotations are the router annotations carried over to the
+// IngressRoutes, the other Traefik annotations are reported.
+var routerAnnotations = []string{
+	"router.entrypoints",
+	"router.middlewares",
+	"router.pathmatcher",
+	"router.priority",
+	"router.tls",
+}
+
+// IngressToIngressRoute generates an IngressRoute from each Ingress handled
+// by Traefik.
+type IngressToIngressRoute struct {
+	rules       *IngressRoute
+	middlewares *middlewareIndex
+	report      *report.Report
+}
+
+func NewIngressToIngressRoute(rules *IngressRoute, middlewares *middlewareIndex, r *report.Report) *IngressToIngressRoute {
+	return &IngressToIngressRoute{rules: rules, middlewares: middlewares, report: r}
+}
+
+// ConvertIngresses generates IngressRoutes from the Ingress objects, instead
+// of checking their annotations.
+func (c *Converter) ConvertIngresses() {
+	rules := c.converters["IngressRoute.traefik.containo.us"].(*IngressRoute)
+	c.converters["Ingress.networking.k8s.io"] = NewIngressToIngressRoute(rules, c.middlewares, c.report)
+}
+
+func (i *IngressToIngressRoute) Transform(object runtime.Object) (runtime.Object, error) {
+	ing, ok := object.(*networkingv1.Ingress)
+	if !ok {
+		return nil, fmt.Errorf("unexpected object %T, expected an ingress", object)
+	}
+
+	source := fmt.Sprintf("ingress %s/%s", ing.Namespace, ing.Name)
+	if class := ingressClass(ing); class != "" && !strings.Contains(class, "traefik") {
+		i.report.Add(source, "ingress class %s is not handled by Traefik, the ingress was not converted", class)
+		return ing.DeepCopy(), nil
+	}
+
+	annotations := map[string]string{}
+	for key, value := range ing.Annotations {
+		if isRouterAnnotation(key) {
+			annotations[key] = value
+		}
+	}
+
+	config := &routerConfig{}
+	if err := label.Decode(convertAnnotations(annotations), config, "traefik.router."); err != nil {
+		return nil, fmt.Errorf("%s: invalid router annotations: %v", source, err)
+	}
+	router := config.Router
+	if router == nil {
+		router = &ingressRouter{}
+	}
+
+	ingressRoute := &traefikio.IngressRoute{
+		TypeMeta:   v1.TypeMeta{Kind: "IngressRoute", APIVersion: utils.APIVersion},
+		ObjectMeta: objectMeta(ing.ObjectMeta),
+		Spec:       traefikio.IngressRouteSpec{EntryPoints: router.EntryPoints},
+	}
+	ingressRoute.Annotations = i.dropAnnotations(source, ingressRoute.Annotations)
+
+	middlewares := i.middlewareRefs(source, ing)
+
+	for _, rule := range ing.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, path := range rule.HTTP.Paths {
+			match, err := i.match(rule.Host, path, router.PathMatcher)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", source, err)
+			}
+
+			service, ok := i.service(source, path.Backend)
+			if !ok {
+				continue
+			}
+
+			ingressRoute.Spec.Routes = append(ingressRoute.Spec.Routes, traefikio.Route{
+				Match:       match,
+				Kind:        "Rule",
+				Priority:    router.Priority,
+				Services:    []traefikio.Service{service},
+				Middlewares: middlewares,
+			})
+		}
+	}
+
+	if ing.Spec.DefaultBackend != nil {
+		if service, ok := i.service(source, *ing.Spec.DefaultBackend); ok {
+			// the default backend catches the requests no rule matched.
+			ingressRoute.Spec.Routes = append(ingressRoute.Spec.Routes, traefikio.Route{
+				Match:       "PathPrefix(`/`)",
+				Kind:        "Rule",
+				Priority:    1,
+				Services:    []traefikio.Service{service},
+				Middlewares: middlewares,
+			})
+		}
+	}
+
+	ingressRoute.Spec.TLS = i.tls(source, ing, router.TLS)
+
+	return ingressRoute, nil
+}
+
+// match builds the rule of an Ingress path, and validates it against the v3
+// muxer.
+func (i *IngressToIngressRoute) match(host string, path networkingv1.HTTPIngressPath, pathMatcher string) (string, error) {
+	var matchers []string
+
+	if host != "" {
+		if strings.HasPrefix(host, "*.") {
+			matchers = append(matchers, fmt.Sprintf("HostRegexp(`^[a-z0-9-]+%s$`)", regexp.QuoteMeta(host[1:])))
+		} else {
+			matchers = append(matchers, fmt.Sprintf("Host(`%s`)", host))
+		}
+	}
+
+	p := path.Path
+	if p == "" {
+		p = "/"
+	}
+
+	matcher := defaultPathMatcher
+	if pathMatcher != "" {
+		matcher = pathMatcher
+	}
+	if path.PathType != nil {
+		switch *path.PathType {
+		case networkingv1.PathTypeExact:
+			matcher = "Path"
+		case networkingv1.PathTypePrefix:
+			matcher = "PathPrefix"
+		}
+	}
+
+	switch matcher {
+	case "Path", "PathPrefix":
+		if strings.Contains(p, "{") {
+			typ := utils.RegexpTypePrefix
+			if matcher == "Path" {
+				typ = utils.RegexpTypePath
+			}
+			pattern, err := utils.RouteRegexp(p, typ)
+			if err != nil {
+				return "", err
+			}
+			matchers = append(matchers, fmt.Sprintf("PathRegexp(`%s`)", pattern))
+		} else if matcher != "PathPrefix" || p != "/" || host == "" {
+			matchers = append(matchers, fmt.Sprintf("%s(`%s`)", matcher, p))
+		}
+	case "PathRegexp":
+		matchers = append(matchers, fmt.Sprintf("PathRegexp(`%s`)", p))
+	default:
+		return "", fmt.Errorf("path matcher %s is not supported by v3", matcher)
+	}
+
+	match := strings.Join(matchers, " && ")
+	if err := i.rules.checkRoute(match, "v3"); err != nil {
+		return "", fmt.Errorf("invalid rule %s: %w", match, err)
+	}
+	return match, nil
+}
+
+func (i *IngressToIngressRoute) service(source string, backend networkingv1.IngressBackend) (traefikio.Service, bool) {
+	if backend.Service == nil {
+		i.report.Add(source, "resource backends are not supported by IngressRoutes, the path was not converted")
+		return traefikio.Service{}, false
+	}
+
+	port := intstr.FromInt32(backend.Service.Port.Number)
+	if backend.Service.Port.Name != "" {
+		port = intstr.FromString(backend.Service.Port.Name)
+	}
+	return traefikio.Service{LoadBalancerSpec: traefikio.LoadBalancerSpec{
+		Name: backend.Service.Name,
+		Port: port,
+	}}, true
+}
+
+// middlewareRefs turns the router.middlewares annotation into middleware
+// references. Middlewares of the converted manifests are referenced by name
+// and namespace, the other ones by their qualified name.
+func (i *IngressToIngressRoute) middlewareRefs(source string, ing *networkingv1.Ingress) []traefikio.MiddlewareRef {
+	refs, ok := ing.Annotations[annotationRouterMiddleware]
+	if !ok {
+		return nil
+	}
+
+	var middlewares []traefikio.MiddlewareRef
+	for _, ref := range strings.Split(i.middlewares.checkRefs(source, ing.Namespace, refs, i.report), ",") {
+		if ref = strings.TrimSpace(ref); ref == "" {
+			continue
+		}
+
+		// ambiguous references were reported by checkRefs and are kept
+		// qualified.
+		name, provider, _ := strings.Cut(ref, "@")
+		matches := i.middlewares.lookup(name)
+		if provider != kubernetesCRDProvider || len(matches) != 1 {
+			middlewares = append(middlewares, traefikio.MiddlewareRef{Name: ref})
+			continue
+		}
+
+		namespace, name, _ := strings.Cut(matches[0], "/")
+		middleware := traefikio.MiddlewareRef{Name: name}
+		if namespace != ing.Namespace {
+			middleware.Namespace = namespace
+		}
+		middlewares = append(middlewares, middleware)
+	}
+	return middlewares
+}
+
+// tls merges the TLS sections of an Ingress with its router.tls
+// annotations. An IngressRoute holds a single certificate secret.
+func (i *IngressToIngressRoute) tls(source string, ing *networkingv1.Ingress, router *dynamic.RouterTLSConfig) *traefikio.TLS {
+	if len(ing.Spec.TLS) == 0 && router == nil {
+		return nil
+	}
+
+	tls := &traefikio.TLS{}
+	for _, t := range ing.Spec.TLS {
+		switch {
+		case t.SecretName == "" || t.SecretName == tls.SecretName:
+		case tls.SecretName == "":
+			tls.SecretName = t.SecretName
+		default:
+			i.report.Add(source, "IngressRoutes hold a single certificate, secret %s must be added to a TLSStore", t.SecretName)
+		}
+	}
+
+	if router != nil {
+		tls.CertResolver = router.CertResolver
+		tls.Domains = router.Domains
+		if router.Options != "" {
+			tls.Options = &traefikio.TLSOptionRef{Name: router.Options}
+		}
+	}
+	return tls
+}
+
+// dropAnnotations removes the Traefik annotations from the IngressRoute,
+// reporting the ones which are not carried over.
+func (i *IngressToIngressRoute) dropAnnotations(source string, annotations map[string]string) map[string]string {
+	kept := map[string]string{}
+	var dropped []string
+	for key, value := range annotations {
+		if key == annotationIngressClass {
+			continue
+		}
+		if !strings.HasPrefix(key, annotationsPrefix) {
+			kept[key] = value
+			continue
+		}
+		if !isRouterAnnotation(key) {
+			dropped = append(dropped, key)
+		}
+	}
+
+	sort.Strings(dropped)
+	for _, key := range dropped {
+		i.report.Add(source, "annotation %s has no IngressRoute equivalent and was dropped", key)
+	}
+
+	if len(kept) == 0 {
+		return nil
+	}
+	return kept
+}
+
+// isRouterAnnotation reports whether the annotation is carried over to the
+// IngressRoutes.
+func isRouterAnnotation(key string) bool {
+	if !strings.HasPrefix(key, annotationsPrefix) {
+		return false
+	}
+	name := strings.TrimPrefix(key, annotationsPrefix)
+	for _, annotation := range routerAnnotations {
+		if name == annotation || strings.HasPrefix(name, annotation+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func ingressClass(ing *networkingv1.Ingress) string {
+	if ing.Spec.IngressClassName != nil {
+		return *ing.Spec.IngressClassName
+	}
+	return ing.Annotations[annotationIngressClass]
+}
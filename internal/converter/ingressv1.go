@@ -0,0 +1,865 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/databotic/traefik-migration-tool/internal/report"
+	"github.com/databotic/traefik-migration-tool/internal/utils"
+	"github.com/traefik/traefik/v3/pkg/config/dynamic"
+	traefikio "github.com/traefik/traefik/v3/pkg/provider/kubernetes/crd/traefikio/v1alpha1"
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// v1AnnotationPrefixes are the prefixes of the annotations read by the
+// Traefik 1.7 kubernetes provider, which accepts both of them.
+var v1AnnotationPrefixes = []string{"traefik.ingress.kubernetes.io/", "ingress.kubernetes.io/"}
+
+// v1LegacyAnnotations are the annotations of older 1.x releases still read by
+// Traefik 1.7, by the name of their current equivalent.
+var v1LegacyAnnotations = map[string]string{
+	"traefik.frontend.rule.type": "rule-type",
+	"traefik.frontend.priority":  "priority",
+}
+
+// v1ServiceAnnotations are the Service annotations carried over to the
+// services of the IngressRoutes.
+var v1ServiceAnnotations = []string{
+	"affinity",
+	"circuit-breaker-expression",
+	"responseforwarding.flushinterval",
+	"session-cookie-name",
+}
+
+// v1RuleTypes are the rule types of Traefik 1.7, by path matcher and whether
+// the matched path is stripped.
+var v1RuleTypes = map[string]struct {
+	matcher string
+	strip   bool
+}{
+	"Path":            {matcher: "Path"},
+	"PathPrefix":      {matcher: "PathPrefix"},
+	"PathStrip":       {matcher: "Path", strip: true},
+	"PathPrefixStrip": {matcher: "PathPrefix", strip: true},
+}
+
+var v1RequestModifierPattern = regexp.MustCompile(`^\s*(\w+)\s*:\s*(.+?)\s*$`)
+
+// IngressV1 generates IngressRoutes and Middlewares from the Ingress objects
+// annotated for Traefik 1.7.
+type IngressV1 struct {
+	ingresses *IngressToIngressRoute
+	// services are the annotations of the Services of the manifests, by
+	// namespace/name.
+	services map[string]map[string]string
+	// ports are the ports of the Services of the manifests, by
+	// namespace/name.
+	ports map[string][]corev1.ServicePort
+	// breakers records the Services whose circuit breaker middleware was
+	// already generated, by namespace/name, as several Ingresses may use
+	// the same Service.
+	breakers map[string]bool
+	report   *report.Report
+}
+
+func NewIngressV1(ingresses *IngressToIngressRoute, r *report.Report) *IngressV1 {
+	return &IngressV1{
+		ingresses: ingresses,
+		services:  map[string]map[string]string{},
+		ports:     map[string][]corev1.ServicePort{},
+		breakers:  map[string]bool{},
+		report:    r,
+	}
+}
+
+// ConvertV1 generates v3 IngressRoutes and Middlewares from the Ingress
+// objects annotated for Traefik 1.7, and removes the Traefik 1.7 annotations
+// of the Services.
+func (c *Converter) ConvertV1() {
+	rules := c.converters["IngressRoute.traefik.containo.us"].(*IngressRoute)
+	c.ingressesV1 = NewIngressV1(NewIngressToIngressRoute(rules, c.middlewares, c.report), c.report)
+
+	for _, kind := range []string{"Ingress.networking.k8s.io", "Ingress.extensions"} {
+		c.converters[kind] = c.ingressesV1
+	}
+	c.converters["Service"] = &ServiceV1{report: c.report}
+}
+
+// indexServices records the annotations and ports of the Services of the
+// manifests, which are carried over to the routes using them.
+func (i *IngressV1) indexServices(objects []runtime.Object) {
+	for _, o := range objects {
+		service, ok := o.(*corev1.Service)
+		if !ok {
+			continue
+		}
+		key := service.Namespace + "/" + service.Name
+		i.services[key], _ = v1Annotations(service.Annotations)
+		i.ports[key] = service.Spec.Ports
+	}
+}
+
+func (i *IngressV1) Transform(object runtime.Object) (runtime.Object, error) {
+	objects, err := i.TransformAll(object)
+	if err != nil {
+		return nil, err
+	}
+	if len(objects) != 1 {
+		return nil, fmt.Errorf("%s converts to %d objects", objectName(object), len(objects))
+	}
+	return objects[0], nil
+}
+
+func (i *IngressV1) TransformAll(object runtime.Object) ([]runtime.Object, error) {
+	ing, err := networkingIngress(object)
+	if err != nil {
+		return nil, err
+	}
+
+	source := fmt.Sprintf("ingress %s/%s", ing.Namespace, ing.Name)
+	if class := ingressClass(ing); class != "" && !strings.Contains(class, "traefik") {
+		i.report.Add(source, "ingress class %s is not handled by Traefik, the ingress was not converted", class)
+		return []runtime.Object{object.DeepCopyObject()}, nil
+	}
+
+	annotations, unknown := v1Annotations(ing.Annotations)
+	for _, key := range unknown {
+		i.report.Add(source, "annotation %s has no v3 equivalent and was dropped", key)
+	}
+
+	ruleType := "PathPrefix"
+	if t, ok := annotations["rule-type"]; ok {
+		ruleType = t
+	}
+	rule, ok := v1RuleTypes[ruleType]
+	if !ok {
+		return nil, fmt.Errorf("%s: unknown rule type %s", source, ruleType)
+	}
+
+	ingressRoute := &traefikio.IngressRoute{
+		TypeMeta:   v1.TypeMeta{Kind: "IngressRoute", APIVersion: utils.APIVersion},
+		ObjectMeta: objectMeta(ing.ObjectMeta),
+	}
+	ingressRoute.Annotations = withoutV1Annotations(ingressRoute.Annotations)
+
+	if entryPoints, ok := annotations["frontend-entry-points"]; ok {
+		ingressRoute.Spec.EntryPoints = splitList(entryPoints, ",")
+	}
+
+	priority := 0
+	if p, ok := annotations["priority"]; ok {
+		if priority, err = strconv.Atoi(p); err != nil {
+			return nil, fmt.Errorf("%s: invalid priority %s", source, p)
+		}
+	}
+
+	middlewares, err := i.middlewares(source, ing, annotations, rule.strip)
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []traefikio.MiddlewareRef
+	for _, m := range middlewares {
+		refs = append(refs, traefikio.MiddlewareRef{Name: m.Name})
+	}
+
+	for _, r := range ing.Spec.Rules {
+		if r.HTTP == nil {
+			continue
+		}
+		for _, path := range r.HTTP.Paths {
+			match, err := i.ingresses.match(r.Host, path, rule.matcher)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", source, err)
+			}
+
+			route, ok := i.route(source, ing, annotations, path.Backend)
+			if !ok {
+				continue
+			}
+			route.Match = match
+			route.Priority = priority
+			route.Middlewares = append(append([]traefikio.MiddlewareRef{}, refs...), route.Middlewares...)
+			ingressRoute.Spec.Routes = append(ingressRoute.Spec.Routes, route)
+		}
+	}
+
+	if ing.Spec.DefaultBackend != nil {
+		if route, ok := i.route(source, ing, annotations, *ing.Spec.DefaultBackend); ok {
+			// the default backend catches the requests no rule matched.
+			route.Match = "PathPrefix(`/`)"
+			route.Priority = 1
+			route.Middlewares = append(append([]traefikio.MiddlewareRef{}, refs...), route.Middlewares...)
+			ingressRoute.Spec.Routes = append(ingressRoute.Spec.Routes, route)
+		}
+	}
+
+	ingressRoute.Spec.TLS = i.ingresses.tls(source, ing, nil)
+
+	objects := []runtime.Object{ingressRoute}
+	for _, m := range middlewares {
+		objects = append(objects, m)
+	}
+	for _, m := range i.circuitBreakers(ing) {
+		objects = append(objects, m)
+	}
+	return objects, nil
+}
+
+// route builds the route of an Ingress backend, with the options of the
+// Ingress and of its Service.
+func (i *IngressV1) route(source string, ing *networkingv1.Ingress, annotations map[string]string, backend networkingv1.IngressBackend) (traefikio.Route, bool) {
+	service, ok := i.ingresses.service(source, backend)
+	if !ok {
+		return traefikio.Route{}, false
+	}
+
+	if annotations["preserve-host"] == "false" {
+		passHostHeader := false
+		service.PassHostHeader = &passHostHeader
+	}
+	if protocol, ok := annotations["protocol"]; ok {
+		service.Scheme = protocol
+	}
+
+	route := traefikio.Route{Kind: "Rule"}
+
+	serviceAnnotations := i.services[ing.Namespace+"/"+service.Name]
+	if serviceAnnotations["affinity"] == "true" {
+		service.Sticky = &dynamic.Sticky{Cookie: &dynamic.Cookie{Name: serviceAnnotations["session-cookie-name"]}}
+	}
+	if interval, ok := serviceAnnotations["responseforwarding.flushinterval"]; ok {
+		service.ResponseForwarding = &traefikio.ResponseForwarding{FlushInterval: interval}
+	}
+	if _, ok := serviceAnnotations["circuit-breaker-expression"]; ok {
+		route.Middlewares = append(route.Middlewares, traefikio.MiddlewareRef{Name: service.Name + "-circuit-breaker"})
+	}
+
+	route.Services = []traefikio.Service{service}
+	return route, true
+}
+
+// circuitBreakers returns the middlewares holding the circuit breakers of the
+// Services used by an Ingress, which are attached to the services in 1.7.
+// The middlewares generated for a previous Ingress are not returned again.
+func (i *IngressV1) circuitBreakers(ing *networkingv1.Ingress) []*traefikio.Middleware {
+	var names []string
+	for _, backend := range ingressBackends(ing) {
+		if backend.Service == nil {
+			continue
+		}
+		key := ing.Namespace + "/" + backend.Service.Name
+		if i.breakers[key] {
+			continue
+		}
+		if _, ok := i.services[key]["circuit-breaker-expression"]; ok {
+			i.breakers[key] = true
+			names = append(names, backend.Service.Name)
+		}
+	}
+
+	var middlewares []*traefikio.Middleware
+	for _, name := range names {
+		expression := i.services[ing.Namespace+"/"+name]["circuit-breaker-expression"]
+		middleware := newMiddleware(ing, name+"-circuit-breaker")
+		middleware.Spec.CircuitBreaker = &traefikio.CircuitBreaker{Expression: expression}
+		middlewares = append(middlewares, middleware)
+	}
+	return middlewares
+}
+
+// middlewares builds the middlewares of the Ingress annotations, in the
+// order Traefik 1.7 applies them.
+func (i *IngressV1) middlewares(source string, ing *networkingv1.Ingress, annotations map[string]string, strip bool) ([]*traefikio.Middleware, error) {
+	var middlewares []*traefikio.Middleware
+	add := func(suffix string, spec traefikio.MiddlewareSpec) {
+		middleware := newMiddleware(ing, ing.Name+"-"+suffix)
+		middleware.Spec = spec
+		middlewares = append(middlewares, middleware)
+	}
+
+	if redirect := i.redirect(source, annotations); redirect != nil {
+		add("redirect", *redirect)
+	}
+	if root, ok := annotations["app-root"]; ok {
+		add("app-root", traefikio.MiddlewareSpec{RedirectRegex: &dynamic.RedirectRegex{
+			Regex:       `^(https?://[^/]+)/$`,
+			Replacement: "${1}" + root,
+		}})
+	}
+
+	if sourceRange, ok := annotations["whitelist-source-range"]; ok {
+		allowList := &dynamic.IPAllowList{SourceRange: splitList(sourceRange, ",")}
+		if annotations["whitelist-x-forwarded-for"] == "true" {
+			allowList.IPStrategy = &dynamic.IPStrategy{Depth: 1}
+			i.report.Add(source, "whitelist-x-forwarded-for checked every X-Forwarded-For address, the allow list now checks the closest one")
+		}
+		add("ipallowlist", traefikio.MiddlewareSpec{IPAllowList: allowList})
+	}
+
+	if auth := i.auth(source, annotations); auth != nil {
+		add("auth", *auth)
+	}
+
+	if value, ok := annotations["rate-limit"]; ok {
+		rateLimits, err := v1RateLimits(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid rate-limit annotation: %v", source, err)
+		}
+		for _, name := range sortedKeys(rateLimits) {
+			suffix := "ratelimit"
+			if len(rateLimits) > 1 {
+				suffix += "-" + name
+			}
+			add(suffix, traefikio.MiddlewareSpec{RateLimit: rateLimits[name]})
+		}
+	}
+
+	if value, ok := annotations["max-conn"]; ok {
+		inFlightReq, err := v1InFlightReq(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid max-conn annotation: %v", source, err)
+		}
+		add("inflightreq", traefikio.MiddlewareSpec{InFlightReq: inFlightReq})
+	}
+
+	if headers := i.headers(source, annotations); headers != nil {
+		add("headers", traefikio.MiddlewareSpec{Headers: headers})
+	}
+
+	if value, ok := annotations["error-pages"]; ok {
+		errorPages, err := i.errorPages(source, ing.Namespace, value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid error-pages annotation: %v", source, err)
+		}
+		for _, name := range sortedKeys(errorPages) {
+			add("errors-"+name, traefikio.MiddlewareSpec{Errors: errorPages[name]})
+		}
+	}
+
+	if value, ok := annotations["buffering"]; ok {
+		buffering := &dynamic.Buffering{}
+		if err := yaml.Unmarshal([]byte(value), buffering); err != nil {
+			return nil, fmt.Errorf("%s: invalid buffering annotation: %v", source, err)
+		}
+		add("buffering", traefikio.MiddlewareSpec{Buffering: buffering})
+	}
+
+	if annotations["pass-tls-cert"] == "true" {
+		add("passtlsclientcert", traefikio.MiddlewareSpec{PassTLSClientCert: &dynamic.PassTLSClientCert{PEM: true}})
+	}
+
+	if strip {
+		var prefixes []string
+		for _, path := range ingressPaths(ing) {
+			if path != "" && path != "/" && !contains(prefixes, path) {
+				prefixes = append(prefixes, path)
+			}
+		}
+		if len(prefixes) > 0 {
+			add("stripprefix", traefikio.MiddlewareSpec{StripPrefix: &dynamic.StripPrefix{Prefixes: prefixes}})
+		}
+	}
+
+	if target, ok := annotations["rewrite-target"]; ok {
+		var paths []string
+		for _, path := range ingressPaths(ing) {
+			paths = append(paths, regexp.QuoteMeta(strings.TrimSuffix(path, "/")))
+		}
+		replacement := strings.TrimSuffix(target, "/") + "/$1"
+		add("rewrite-target", traefikio.MiddlewareSpec{ReplacePathRegex: &dynamic.ReplacePathRegex{
+			Regex:       "^(?:" + strings.Join(paths, "|") + ")/?(.*)",
+			Replacement: replacement,
+		}})
+	}
+
+	if modifier, ok := annotations["request-modifier"]; ok {
+		spec, err := v1RequestModifier(modifier)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", source, err)
+		}
+		add("request-modifier", *spec)
+	}
+
+	return middlewares, nil
+}
+
+// redirect maps the redirection annotations to a redirection middleware.
+func (i *IngressV1) redirect(source string, annotations map[string]string) *traefikio.MiddlewareSpec {
+	permanent := annotations["redirect-permanent"] == "true"
+
+	switch {
+	case annotations["ssl-redirect"] == "true":
+		return &traefikio.MiddlewareSpec{RedirectScheme: &dynamic.RedirectScheme{
+			Scheme:    "https",
+			Permanent: annotations["ssl-temporary-redirect"] != "true",
+		}}
+	case annotations["ssl-temporary-redirect"] == "true":
+		return &traefikio.MiddlewareSpec{RedirectScheme: &dynamic.RedirectScheme{Scheme: "https"}}
+	case annotations["redirect-entry-point"] != "":
+		entryPoint := annotations["redirect-entry-point"]
+		if entryPoint != "https" && entryPoint != "websecure" {
+			i.report.Add(source, "redirection to entry point %s was converted to a redirection to https", entryPoint)
+		}
+		return &traefikio.MiddlewareSpec{RedirectScheme: &dynamic.RedirectScheme{Scheme: "https", Permanent: permanent}}
+	case annotations["redirect-regex"] != "":
+		return &traefikio.MiddlewareSpec{RedirectRegex: &dynamic.RedirectRegex{
+			Regex:       annotations["redirect-regex"],
+			Replacement: annotations["redirect-replacement"],
+			Permanent:   permanent,
+		}}
+	}
+	return nil
+}
+
+// auth maps the authentication annotations to an authentication middleware.
+func (i *IngressV1) auth(source string, annotations map[string]string) *traefikio.MiddlewareSpec {
+	authType, ok := annotations["auth-type"]
+	if !ok {
+		return nil
+	}
+
+	removeHeader := annotations["auth-remove-header"] == "true"
+	switch authType {
+	case "basic":
+		return &traefikio.MiddlewareSpec{BasicAuth: &traefikio.BasicAuth{
+			Secret:       annotations["auth-secret"],
+			Realm:        annotations["auth-realm"],
+			RemoveHeader: removeHeader,
+			HeaderField:  annotations["auth-header-field"],
+		}}
+	case "digest":
+		return &traefikio.MiddlewareSpec{DigestAuth: &traefikio.DigestAuth{
+			Secret:       annotations["auth-secret"],
+			Realm:        annotations["auth-realm"],
+			RemoveHeader: removeHeader,
+			HeaderField:  annotations["auth-header-field"],
+		}}
+	case "forward":
+		if _, ok := annotations["auth-tls-secret"]; ok {
+			i.report.Add(source, "auth-tls-secret must be split into the tls.caSecret and tls.certSecret options of the forwardAuth middleware")
+		}
+		return &traefikio.MiddlewareSpec{ForwardAuth: &traefikio.ForwardAuth{
+			Address:             annotations["auth-url"],
+			TrustForwardHeader:  annotations["auth-trust-headers"] == "true",
+			AuthResponseHeaders: splitList(annotations["auth-response-headers"], ","),
+		}}
+	}
+
+	i.report.Add(source, "unknown auth type %s, the authentication was dropped", authType)
+	return nil
+}
+
+// headers maps the security and custom headers annotations to a headers
+// middleware.
+func (i *IngressV1) headers(source string, annotations map[string]string) *dynamic.Headers {
+	headers := &dynamic.Headers{}
+	for _, key := range sortedKeys(annotations) {
+		value := annotations[key]
+		switch key {
+		case "custom-request-headers":
+			headers.CustomRequestHeaders = v1HeadersMap(value)
+		case "custom-response-headers":
+			headers.CustomResponseHeaders = v1HeadersMap(value)
+		case "ssl-proxy-headers":
+			headers.SSLProxyHeaders = v1HeadersMap(value)
+		case "allowed-hosts":
+			headers.AllowedHosts = splitList(value, ",")
+		case "proxy-headers":
+			headers.HostsProxyHeaders = splitList(value, ",")
+		case "hsts-max-age":
+			headers.STSSeconds, _ = strconv.ParseInt(value, 10, 64)
+		case "hsts-include-subdomains":
+			headers.STSIncludeSubdomains = value == "true"
+		case "hsts-preload":
+			headers.STSPreload = value == "true"
+		case "force-hsts":
+			headers.ForceSTSHeader = value == "true"
+		case "frame-deny":
+			headers.FrameDeny = value == "true"
+		case "custom-frame-options-value":
+			headers.CustomFrameOptionsValue = value
+		case "content-type-nosniff":
+			headers.ContentTypeNosniff = value == "true"
+		case "browser-xss-filter":
+			headers.BrowserXSSFilter = value == "true"
+		case "custom-browser-xss-value":
+			headers.CustomBrowserXSSValue = value
+		case "content-security-policy":
+			headers.ContentSecurityPolicy = value
+		case "public-key":
+			headers.PublicKey = value
+		case "referrer-policy":
+			headers.ReferrerPolicy = value
+		case "is-development":
+			headers.IsDevelopment = value == "true"
+		case "ssl-host", "ssl-force-host":
+			i.report.Add(source, "%s was removed in v3, the host redirection must be done with a redirectRegex middleware", key)
+		}
+	}
+
+	if options, err := utils.ToMap(headers); err != nil || len(options) == 0 {
+		return nil
+	}
+	return headers
+}
+
+// errorPages maps the error-pages annotation to errors middlewares, by name.
+// The backend of an error page is a Service, served on its first port.
+func (i *IngressV1) errorPages(source, namespace, value string) (map[string]*traefikio.ErrorPage, error) {
+	var pages map[string]struct {
+		Status  []string `yaml:"status"`
+		Backend string   `yaml:"backend"`
+		Query   string   `yaml:"query"`
+	}
+	if err := yaml.Unmarshal([]byte(value), &pages); err != nil {
+		return nil, err
+	}
+
+	errorPages := map[string]*traefikio.ErrorPage{}
+	for name, page := range pages {
+		port := intstr.FromInt32(80)
+		if ports := i.ports[namespace+"/"+page.Backend]; len(ports) > 0 {
+			port = intstr.FromInt32(ports[0].Port)
+		} else {
+			i.report.Add(source, "service %s of error page %s is not part of the manifests, port 80 is assumed", page.Backend, name)
+		}
+
+		errorPages[name] = &traefikio.ErrorPage{
+			Status:  page.Status,
+			Service: traefikio.Service{LoadBalancerSpec: traefikio.LoadBalancerSpec{Name: page.Backend, Port: port}},
+			Query:   page.Query,
+		}
+	}
+	return errorPages, nil
+}
+
+// v1RateLimits maps the rate sets of the rate-limit annotation to rateLimit
+// middlewares, by name. Requests must fit every rate set, as they do when the
+// middlewares are chained.
+func v1RateLimits(value string) (map[string]*traefikio.RateLimit, error) {
+	var config struct {
+		ExtractorFunc string `yaml:"extractorfunc"`
+		RateSet       map[string]struct {
+			Period  string `yaml:"period"`
+			Average int64  `yaml:"average"`
+			Burst   int64  `yaml:"burst"`
+		} `yaml:"rateset"`
+	}
+	if err := yaml.Unmarshal([]byte(value), &config); err != nil {
+		return nil, err
+	}
+
+	criterion, err := v1SourceCriterion(config.ExtractorFunc)
+	if err != nil {
+		return nil, err
+	}
+
+	rateLimits := map[string]*traefikio.RateLimit{}
+	for name, rate := range config.RateSet {
+		rateLimit := &traefikio.RateLimit{Average: rate.Average, SourceCriterion: criterion}
+		if rate.Period != "" {
+			period := intstr.FromString(rate.Period)
+			rateLimit.Period = &period
+		}
+		if rate.Burst != 0 {
+			burst := rate.Burst
+			rateLimit.Burst = &burst
+		}
+		rateLimits[name] = rateLimit
+	}
+	return rateLimits, nil
+}
+
+func v1InFlightReq(value string) (*dynamic.InFlightReq, error) {
+	var config struct {
+		Amount        int64  `yaml:"amount"`
+		ExtractorFunc string `yaml:"extractorfunc"`
+	}
+	if err := yaml.Unmarshal([]byte(value), &config); err != nil {
+		return nil, err
+	}
+
+	criterion, err := v1SourceCriterion(config.ExtractorFunc)
+	if err != nil {
+		return nil, err
+	}
+	return &dynamic.InFlightReq{Amount: config.Amount, SourceCriterion: criterion}, nil
+}
+
+// v1SourceCriterion maps an extractor function to a source criterion.
+func v1SourceCriterion(extractorFunc string) (*dynamic.SourceCriterion, error) {
+	switch {
+	case extractorFunc == "", extractorFunc == "client.ip":
+		return nil, nil
+	case extractorFunc == "request.host":
+		return &dynamic.SourceCriterion{RequestHost: true}, nil
+	case strings.HasPrefix(extractorFunc, "request.header."):
+		return &dynamic.SourceCriterion{RequestHeaderName: strings.TrimPrefix(extractorFunc, "request.header.")}, nil
+	}
+	return nil, fmt.Errorf("unknown extractor function %s", extractorFunc)
+}
+
+// v1RequestModifier maps the request-modifier annotation to a path
+// middleware.
+func v1RequestModifier(value string) (*traefikio.MiddlewareSpec, error) {
+	m := v1RequestModifierPattern.FindStringSubmatch(value)
+	if m == nil {
+		return nil, fmt.Errorf("invalid request-modifier annotation %s", value)
+	}
+
+	switch m[1] {
+	case "AddPrefix":
+		return &traefikio.MiddlewareSpec{AddPrefix: &dynamic.AddPrefix{Prefix: m[2]}}, nil
+	case "ReplacePath":
+		return &traefikio.MiddlewareSpec{ReplacePath: &dynamic.ReplacePath{Path: m[2]}}, nil
+	case "ReplacePathRegex":
+		regex, replacement, found := strings.Cut(m[2], " ")
+		if !found {
+			return nil, fmt.Errorf("invalid request-modifier annotation %s, expected a regex and a replacement", value)
+		}
+		return &traefikio.MiddlewareSpec{ReplacePathRegex: &dynamic.ReplacePathRegex{
+			Regex:       regex,
+			Replacement: strings.TrimSpace(replacement),
+		}}, nil
+	}
+	return nil, fmt.Errorf("unknown request modifier %s", m[1])
+}
+
+// v1HeadersMap parses the Name:value||Name:value headers of an annotation.
+func v1HeadersMap(value string) map[string]string {
+	headers := map[string]string{}
+	for _, header := range strings.Split(value, "||") {
+		name, v, found := strings.Cut(header, ":")
+		if !found {
+			continue
+		}
+		headers[strings.TrimSpace(name)] = strings.TrimSpace(v)
+	}
+	return headers
+}
+
+// v1Annotations returns the Traefik 1.7 annotations by name, without their
+// prefix, and the keys of the ones which have no v3 equivalent.
+func v1Annotations(annotations map[string]string) (map[string]string, []string) {
+	result := map[string]string{}
+	var unknown []string
+
+	for key, value := range annotations {
+		name, ok := v1AnnotationName(key)
+		if !ok {
+			continue
+		}
+		if !v1Supported(name) {
+			unknown = append(unknown, key)
+			continue
+		}
+		result[name] = value
+	}
+
+	sort.Strings(unknown)
+	return result, unknown
+}
+
+func v1AnnotationName(key string) (string, bool) {
+	if name, ok := v1LegacyAnnotations[key]; ok {
+		return name, true
+	}
+	for _, prefix := range v1AnnotationPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return strings.TrimPrefix(key, prefix), true
+		}
+	}
+	return "", false
+}
+
+func v1Supported(name string) bool {
+	switch name {
+	case "rule-type", "priority", "frontend-entry-points", "preserve-host", "protocol",
+		"redirect-entry-point", "redirect-permanent", "redirect-regex", "redirect-replacement", "app-root",
+		"ssl-redirect", "ssl-temporary-redirect", "ssl-host", "ssl-force-host",
+		"whitelist-source-range", "whitelist-x-forwarded-for",
+		"auth-type", "auth-secret", "auth-realm", "auth-remove-header", "auth-header-field",
+		"auth-url", "auth-trust-headers", "auth-response-headers", "auth-tls-secret",
+		"rate-limit", "max-conn", "error-pages", "buffering", "pass-tls-cert",
+		"rewrite-target", "request-modifier",
+		"custom-request-headers", "custom-response-headers", "ssl-proxy-headers", "allowed-hosts", "proxy-headers",
+		"hsts-max-age", "hsts-include-subdomains", "hsts-preload", "force-hsts", "frame-deny",
+		"custom-frame-options-value", "content-type-nosniff", "browser-xss-filter", "custom-browser-xss-value",
+		"content-security-policy", "public-key", "referrer-policy", "is-development":
+		return true
+	}
+	return contains(v1ServiceAnnotations, name)
+}
+
+// withoutV1Annotations removes the Traefik 1.7 annotations. The ingress class
+// is kept, as the kubernetesCRD provider filters IngressRoutes on it.
+func withoutV1Annotations(annotations map[string]string) map[string]string {
+	kept := map[string]string{}
+	for key, value := range annotations {
+		if _, ok := v1AnnotationName(key); ok {
+			continue
+		}
+		kept[key] = value
+	}
+	if len(kept) == 0 {
+		return nil
+	}
+	return kept
+}
+
+func newMiddleware(ing *networkingv1.Ingress, name string) *traefikio.Middleware {
+	return &traefikio.Middleware{
+		TypeMeta:   v1.TypeMeta{Kind: "Middleware", APIVersion: utils.APIVersion},
+		ObjectMeta: v1.ObjectMeta{Name: name, Namespace: ing.Namespace},
+	}
+}
+
+func ingressBackends(ing *networkingv1.Ingress) []networkingv1.IngressBackend {
+	var backends []networkingv1.IngressBackend
+	for _, rule := range ing.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, path := range rule.HTTP.Paths {
+			backends = append(backends, path.Backend)
+		}
+	}
+	if ing.Spec.DefaultBackend != nil {
+		backends = append(backends, *ing.Spec.DefaultBackend)
+	}
+	return backends
+}
+
+func ingressPaths(ing *networkingv1.Ingress) []string {
+	var paths []string
+	for _, rule := range ing.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, path := range rule.HTTP.Paths {
+			paths = append(paths, path.Path)
+		}
+	}
+	return paths
+}
+
+// networkingIngress returns object as a networking.k8s.io/v1 Ingress. The
+// v1beta1 Ingresses still used with Traefik 1.7 reference their backends by
+// serviceName and servicePort.
+func networkingIngress(object runtime.Object) (*networkingv1.Ingress, error) {
+	if ing, ok := object.(*networkingv1.Ingress); ok {
+		return ing, nil
+	}
+
+	content, err := utils.ToUnstructured(object)
+	if err != nil {
+		return nil, err
+	}
+	spec, _ := content["spec"].(map[string]interface{})
+	if backend, ok := spec["backend"]; ok {
+		spec["defaultBackend"] = backend
+		delete(spec, "backend")
+	}
+	convertV1Beta1Backend(spec["defaultBackend"])
+
+	rules, _ := spec["rules"].([]interface{})
+	for _, r := range rules {
+		rule, _ := r.(map[string]interface{})
+		http, _ := rule["http"].(map[string]interface{})
+		paths, _ := http["paths"].([]interface{})
+		for _, p := range paths {
+			path, _ := p.(map[string]interface{})
+			convertV1Beta1Backend(path["backend"])
+		}
+	}
+
+	ing := &networkingv1.Ingress{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(content, ing); err != nil {
+		return nil, fmt.Errorf("invalid ingress %s: %v", objectName(object), err)
+	}
+	return ing, nil
+}
+
+func convertV1Beta1Backend(b interface{}) {
+	backend, ok := b.(map[string]interface{})
+	if !ok {
+		return
+	}
+	name, ok := backend["serviceName"]
+	if !ok {
+		return
+	}
+
+	port := map[string]interface{}{}
+	switch p := backend["servicePort"].(type) {
+	case string:
+		port["name"] = p
+	default:
+		port["number"] = p
+	}
+	backend["service"] = map[string]interface{}{"name": name, "port": port}
+	delete(backend, "serviceName")
+	delete(backend, "servicePort")
+}
+
+func splitList(value, separator string) []string {
+	var items []string
+	for _, item := range strings.Split(value, separator) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ServiceV1 removes the Traefik 1.7 annotations of the Services, reporting the
+// ones which are not carried over to the IngressRoutes.
+type ServiceV1 struct {
+	report *report.Report
+}
+
+func (s *ServiceV1) Transform(object runtime.Object) (runtime.Object, error) {
+	service, ok := object.(*corev1.Service)
+	if !ok {
+		return nil, fmt.Errorf("unexpected object %T, expected a service", object)
+	}
+
+	converted := service.DeepCopy()
+	source := "service " + objectName(service)
+
+	var dropped []string
+	for key := range converted.Annotations {
+		name, ok := v1AnnotationName(key)
+		if !ok {
+			continue
+		}
+		if !contains(v1ServiceAnnotations, name) {
+			dropped = append(dropped, key)
+		}
+		delete(converted.Annotations, key)
+	}
+
+	sort.Strings(dropped)
+	for _, key := range dropped {
+		s.report.Add(source, "annotation %s has no v3 equivalent and was dropped", key)
+	}
+	return converted, nil
+}
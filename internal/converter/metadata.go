@@ -0,0 +1,175 @@
+package converter
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/databotic/traefik-migration-tool/internal/parser"
+	"github.com/databotic/traefik-migration-tool/internal/utils"
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/api/meta"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ServerFields are the fields populated by the API server. They are
+// stripped from the converted objects, so they can be applied again.
+var ServerFields = []string{
+	"creationTimestamp",
+	"generation",
+	"managedFields",
+	"resourceVersion",
+	"selfLink",
+	"status",
+	"uid",
+}
+
+// objectMeta returns a copy of the metadata of a v2 object, without the
+// annotations which don't apply to v3.
+func objectMeta(m v1.ObjectMeta) v1.ObjectMeta {
+	converted := *m.DeepCopy()
+	converted.Annotations = utils.FilterAnnotations(m.Annotations)
+	return converted
+}
+
+// KeepServerFields keeps the given server populated fields in the converted
+// objects.
+func (c *Converter) KeepServerFields(fields ...string) error {
+	for _, field := range fields {
+		known := false
+		for _, f := range ServerFields {
+			if f == field {
+				known = true
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown server field %s, expected one of %s", field, strings.Join(ServerFields, ", "))
+		}
+		c.keep[field] = true
+	}
+	return nil
+}
+
+// stripServerFields removes the server populated fields of an object,
+// except the ones to keep.
+func (c *Converter) stripServerFields(object runtime.Object) error {
+	if u, ok := object.(*parser.Unknown); ok {
+		return c.stripUnknownServerFields(u)
+	}
+
+	accessor, err := meta.Accessor(object)
+	if err != nil {
+		return err
+	}
+
+	for _, field := range ServerFields {
+		if c.keep[field] {
+			continue
+		}
+
+		switch field {
+		case "creationTimestamp":
+			accessor.SetCreationTimestamp(v1.Time{})
+		case "generation":
+			accessor.SetGeneration(0)
+		case "managedFields":
+			accessor.SetManagedFields(nil)
+		case "resourceVersion":
+			accessor.SetResourceVersion("")
+		case "selfLink":
+			accessor.SetSelfLink("")
+		case "uid":
+			accessor.SetUID("")
+		case "status":
+			if u, ok := object.(*unstructured.Unstructured); ok {
+				unstructured.RemoveNestedField(u.Object, "status")
+				continue
+			}
+			status := reflect.Indirect(reflect.ValueOf(object)).FieldByName("Status")
+			if status.IsValid() && status.CanSet() {
+				status.Set(reflect.Zero(status.Type()))
+			}
+		}
+	}
+
+	return nil
+}
+
+// stripUnknownServerFields removes the server populated fields of an object
+// of an unknown kind. Their lines are removed from its source, so the
+// document keeps its comments and formatting.
+func (c *Converter) stripUnknownServerFields(u *parser.Unknown) error {
+	var removed [][]string
+	for _, field := range ServerFields {
+		if c.keep[field] {
+			continue
+		}
+
+		path := []string{"metadata", field}
+		if field == "status" {
+			path = []string{"status"}
+		}
+		if _, found, _ := unstructured.NestedFieldNoCopy(u.Object, path...); found {
+			unstructured.RemoveNestedField(u.Object, path...)
+			removed = append(removed, path)
+		}
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+
+	source, err := removeYAMLFields(u.Source, removed)
+	if err != nil {
+		// flow mappings, as in JSON documents, can't be edited line by
+		// line, the object is encoded again.
+		source, err = utils.EncodeYaml(u.Object)
+	}
+	u.Source = source
+	return err
+}
+
+// removeYAMLFields removes the fields at the given paths from a YAML
+// document made of block mappings.
+func removeYAMLFields(src []byte, paths [][]string) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(src, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, fmt.Errorf("empty document")
+	}
+
+	var edits []utils.TextEdit
+	for _, path := range paths {
+		node := doc.Content[0]
+		for i, field := range path {
+			if node.Kind != yaml.MappingNode || node.Style&yaml.FlowStyle != 0 {
+				return nil, fmt.Errorf("%s is not a block mapping", strings.Join(path[:i], "."))
+			}
+
+			var key, value *yaml.Node
+			for j := 0; j+1 < len(node.Content); j += 2 {
+				if node.Content[j].Value == field {
+					key, value = node.Content[j], node.Content[j+1]
+				}
+			}
+			if key == nil {
+				return nil, fmt.Errorf("%s not found", strings.Join(path[:i+1], "."))
+			}
+			if i < len(path)-1 {
+				node = value
+				continue
+			}
+
+			start, end, err := utils.YAMLPairSpan(src, key, value)
+			if err != nil {
+				return nil, err
+			}
+			edits = append(edits, utils.TextEdit{Start: start, End: end})
+		}
+	}
+
+	return utils.ApplyEdits(src, edits)
+}
@@ -3,6 +3,7 @@ package converter
 import (
 	"fmt"
 
+	"github.com/databotic/traefik-migration-tool/internal/report"
 	"github.com/databotic/traefik-migration-tool/internal/utils"
 	containous "github.com/traefik/traefik/v2/pkg/provider/kubernetes/crd/traefikcontainous/v1alpha1"
 	"github.com/traefik/traefik/v3/pkg/config/dynamic"
@@ -11,10 +12,12 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
-type MiddleWare struct{}
+type MiddleWare struct {
+	report *report.Report
+}
 
-func NewMiddleWare() *MiddleWare {
-	return &MiddleWare{}
+func NewMiddleWare(r *report.Report) *MiddleWare {
+	return &MiddleWare{report: r}
 }
 
 func (m *MiddleWare) Transform(object runtime.Object) (runtime.Object, error) {
@@ -23,18 +26,24 @@ func (m *MiddleWare) Transform(object runtime.Object) (runtime.Object, error) {
 		return nil, fmt.Errorf("err")
 	}
 
-	if utils.HasDepricatedMiddleWareOptions(v2MiddleWare) {
-		fmt.Printf("middleware %s has depricated options and it should be fixed manually\n", v2MiddleWare.Name)
+	for _, option := range utils.DepricatedOptions(v2MiddleWare.Spec) {
+		m.report.Add(objectSource(v2MiddleWare), "%s was removed in v3 and must be fixed manually", option)
 	}
 
-	middleware := &traefikio.Middleware{
-		TypeMeta: v1.TypeMeta{Kind: v2MiddleWare.Kind, APIVersion: utils.APIVersion},
-		ObjectMeta: v1.ObjectMeta{
-			Name: v2MiddleWare.ObjectMeta.Name, Namespace: v2MiddleWare.ObjectMeta.Namespace,
-			Annotations: utils.FilterAnnotations(v2MiddleWare.Annotations), Labels: v2MiddleWare.Labels,
-		},
+	spec, err := middlewareSpec(v2MiddleWare)
+	if err != nil {
+		return nil, err
 	}
 
+	return &traefikio.Middleware{
+		TypeMeta:   v1.TypeMeta{Kind: v2MiddleWare.Kind, APIVersion: utils.APIVersion},
+		ObjectMeta: objectMeta(v2MiddleWare.ObjectMeta),
+		Spec:       *spec,
+	}, nil
+}
+
+// middlewareSpec returns the v3 spec of a v2 middleware.
+func middlewareSpec(v2MiddleWare *containous.Middleware) (*traefikio.MiddlewareSpec, error) {
 	spec, err := utils.AsType[traefikio.MiddlewareSpec](v2MiddleWare.Spec)
 	if err != nil {
 		return nil, fmt.Errorf("error converting middleware to v3 %v", err)
@@ -43,12 +52,11 @@ func (m *MiddleWare) Transform(object runtime.Object) (runtime.Object, error) {
 	if spec.IPWhiteList != nil {
 		ipAllowList, err := utils.AsType[dynamic.IPAllowList](v2MiddleWare.Spec.IPWhiteList)
 		if err != nil {
-			fmt.Println("error converting IPWhiteList to IPAllowList")
+			return nil, fmt.Errorf("error converting IPWhiteList to IPAllowList %v", err)
 		}
 		spec.IPWhiteList = nil
 		spec.IPAllowList = ipAllowList
 	}
 
-	middleware.Spec = *spec
-	return middleware, nil
+	return spec, nil
 }
@@ -0,0 +1,93 @@
+package converter
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/databotic/traefik-migration-tool/internal/report"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const kubernetesCRDProvider = "kubernetescrd"
+
+// middlewareIndex tracks the Middlewares of the converted manifests, so
+// references to them can be validated.
+type middlewareIndex struct {
+	known map[string]bool
+}
+
+func newMiddlewareIndex() *middlewareIndex {
+	return &middlewareIndex{known: map[string]bool{}}
+}
+
+func (m *middlewareIndex) add(namespace, name string) {
+	m.known[namespace+"/"+name] = true
+}
+
+// checkRefs reports the invalid references of a comma separated list of
+// middleware references, as found in the router.middlewares annotation, and
+// returns the list without its blank entries.
+func (m *middlewareIndex) checkRefs(source, namespace, refs string, r *report.Report) string {
+	var checked []string
+	for _, ref := range strings.Split(refs, ",") {
+		ref = strings.TrimSpace(ref)
+		if ref == "" {
+			continue
+		}
+
+		name, provider, found := strings.Cut(ref, "@")
+		if !found {
+			r.Add(source, "middleware reference %s has no provider, use %s@%s", ref, namespace+"-"+name, kubernetesCRDProvider)
+			checked = append(checked, ref)
+			continue
+		}
+		if provider != kubernetesCRDProvider {
+			checked = append(checked, ref)
+			continue
+		}
+
+		switch matches := m.lookup(name); {
+		case len(matches) > 1:
+			r.Add(source, "middleware %s is ambiguous, it matches the middlewares %s", ref, strings.Join(matches, ", "))
+		case len(matches) == 0 && len(m.known) > 0:
+			r.Add(source, "middleware %s is not part of the converted manifests", ref)
+		}
+		checked = append(checked, ref)
+	}
+
+	return strings.Join(checked, ",")
+}
+
+// lookup returns the sorted known middlewares a <namespace>-<name>
+// reference resolves to. Namespaces and names may both contain dashes, so
+// a reference can match several middlewares.
+func (m *middlewareIndex) lookup(ref string) []string {
+	var matches []string
+	for key := range m.known {
+		if strings.Replace(key, "/", "-", 1) == ref {
+			matches = append(matches, key)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+func annotationsOf(object runtime.Object) map[string]string {
+	accessor, err := meta.Accessor(object)
+	if err != nil {
+		return nil
+	}
+	return accessor.GetAnnotations()
+}
+
+func objectName(object runtime.Object) string {
+	accessor, err := meta.Accessor(object)
+	if err != nil {
+		return object.GetObjectKind().GroupVersionKind().Kind
+	}
+	if accessor.GetNamespace() == "" {
+		return accessor.GetName()
+	}
+	return accessor.GetNamespace() + "/" + accessor.GetName()
+}
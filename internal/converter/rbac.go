@@ -0,0 +1,146 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	containousGroup = "traefik.containo.us"
+	traefikGroup    = "traefik.io"
+)
+
+// RBACMode selects how the rules granting access to the v2 group are
+// rewritten.
+type RBACMode string
+
+const (
+	// RBACTransition grants both groups, so the rules keep working while
+	// Traefik v2 and v3 run side by side.
+	RBACTransition RBACMode = "transition"
+	// RBACFinal replaces the v2 group with the v3 one.
+	RBACFinal RBACMode = "final"
+)
+
+// v3Resources are the resources added in v3, granted along the v2 resource
+// they derive from.
+var v3Resources = map[string][]string{
+	"serverstransports": {"serverstransporttcps"},
+}
+
+// RBAC rewrites the rules of ClusterRoles and Roles granting access to the
+// traefik.containo.us group, so they grant access to traefik.io as well.
+type RBAC struct {
+	mode RBACMode
+}
+
+func NewRBAC() *RBAC {
+	return &RBAC{mode: RBACTransition}
+}
+
+// SetRBACMode selects how the rules granting access to the v2 group are
+// rewritten.
+func (c *Converter) SetRBACMode(mode RBACMode) error {
+	switch mode {
+	case RBACTransition, RBACFinal:
+	default:
+		return fmt.Errorf("unknown RBAC mode %s, expected %s or %s", mode, RBACTransition, RBACFinal)
+	}
+	c.rbac.mode = mode
+	return nil
+}
+
+func (r *RBAC) Transform(object runtime.Object) (runtime.Object, error) {
+	switch role := object.(type) {
+	case *rbacv1.ClusterRole:
+		converted := role.DeepCopy()
+		converted.Rules = r.convertRules(role.Rules)
+		return converted, nil
+	case *rbacv1.Role:
+		converted := role.DeepCopy()
+		converted.Rules = r.convertRules(role.Rules)
+		return converted, nil
+	default:
+		return nil, fmt.Errorf("unexpected object %T, expected a role", object)
+	}
+}
+
+// grantsContainous reports whether object is a role granting access to the
+// v2 group.
+func grantsContainous(object runtime.Object) bool {
+	var rules []rbacv1.PolicyRule
+	switch role := object.(type) {
+	case *rbacv1.ClusterRole:
+		rules = role.Rules
+	case *rbacv1.Role:
+		rules = role.Rules
+	}
+	for _, rule := range rules {
+		if contains(rule.APIGroups, containousGroup) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RBAC) convertRules(rules []rbacv1.PolicyRule) []rbacv1.PolicyRule {
+	var converted []rbacv1.PolicyRule
+	for _, rule := range rules {
+		if !contains(rule.APIGroups, containousGroup) {
+			converted = append(converted, rule)
+			continue
+		}
+
+		rule = *rule.DeepCopy()
+		if r.mode == RBACFinal {
+			rule.APIGroups = remove(rule.APIGroups, containousGroup)
+		}
+		if !contains(rule.APIGroups, traefikGroup) {
+			rule.APIGroups = append(rule.APIGroups, traefikGroup)
+		}
+		rule.Resources = addV3Resources(rule.Resources)
+
+		converted = append(converted, rule)
+	}
+	return converted
+}
+
+// addV3Resources adds the v3 resources deriving from the granted ones,
+// together with their subresources.
+func addV3Resources(resources []string) []string {
+	var added []string
+	for _, resource := range resources {
+		name, subresource, _ := strings.Cut(resource, "/")
+		for _, v3 := range v3Resources[name] {
+			if subresource != "" {
+				v3 += "/" + subresource
+			}
+			if !contains(resources, v3) && !contains(added, v3) {
+				added = append(added, v3)
+			}
+		}
+	}
+	return append(resources, added...)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func remove(values []string, value string) []string {
+	var kept []string
+	for _, v := range values {
+		if v != value {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,395 @@
+package converter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/databotic/traefik-migration-tool/internal/parser"
+	"github.com/databotic/traefik-migration-tool/internal/utils"
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ReferenceRewriter rewrites the references to the traefik.containo.us group
+// held by the objects of third-party kinds, such as policies or GitOps
+// settings.
+type ReferenceRewriter interface {
+	// Matches reports whether the objects of the group kind hold
+	// references handled by the rewriter.
+	Matches(gk schema.GroupKind, object map[string]interface{}) bool
+	// Rewrite rewrites the references of the object and returns the paths
+	// of the fields it changed.
+	Rewrite(object map[string]interface{}) ([]string, error)
+}
+
+// DefaultReferenceRewriters returns the rewriters of the well-known kinds
+// referencing Traefik resources.
+func DefaultReferenceRewriters() []ReferenceRewriter {
+	return []ReferenceRewriter{
+		&groupReferences{kinds: []string{
+			"Application.argoproj.io",
+			"ApplicationSet.argoproj.io",
+			"AppProject.argoproj.io",
+		}},
+		&groupReferences{kinds: []string{
+			"ClusterPolicy.kyverno.io",
+			"Policy.kyverno.io",
+		}},
+		&groupReferences{
+			kinds:  []string{"Config.config.gatekeeper.sh"},
+			groups: []string{"constraints.gatekeeper.sh"},
+		},
+		&groupReferences{kinds: []string{
+			"VerticalPodAutoscaler.autoscaling.k8s.io",
+			"HorizontalPodAutoscaler.autoscaling",
+		}},
+		&argoCDConfig{},
+		&externalDNS{},
+	}
+}
+
+// RegisterReferenceRewriter adds a rewriter of the references held by
+// third-party kinds.
+func (c *Converter) RegisterReferenceRewriter(r ReferenceRewriter) {
+	c.references = append(c.references, r)
+}
+
+// rewriteReferences applies the matching reference rewriters to object,
+// reporting every location they changed.
+func (c *Converter) rewriteReferences(object runtime.Object) (runtime.Object, error) {
+	u, unknown := object.(*parser.Unknown)
+
+	var content map[string]interface{}
+	if unknown {
+		content = u.Object
+	} else {
+		var err error
+		if content, err = utils.ToUnstructured(object); err != nil {
+			return nil, err
+		}
+	}
+
+	gk := object.GetObjectKind().GroupVersionKind().GroupKind()
+	var locations []string
+	for _, r := range c.references {
+		if !r.Matches(gk, content) {
+			continue
+		}
+		changed, err := r.Rewrite(content)
+		if err != nil {
+			return nil, fmt.Errorf("error rewriting the references of %s: %v", objectSource(object), err)
+		}
+		locations = append(locations, changed...)
+	}
+	if len(locations) == 0 {
+		return object, nil
+	}
+
+	for _, location := range locations {
+		c.report.Add(objectSource(object), "%s now references %s", location, traefikGroup)
+	}
+
+	if unknown {
+		source, err := utils.EncodeYaml(u.Object)
+		if err != nil {
+			return nil, err
+		}
+		u.Source = source
+		return u, nil
+	}
+	converted := reflect.New(reflect.TypeOf(object).Elem()).Interface().(runtime.Object)
+	return converted, runtime.DefaultUnstructuredConverter.FromUnstructured(content, converted)
+}
+
+// referencesTraefik reports whether the default rewriters would change the
+// references held by object.
+func referencesTraefik(object runtime.Object) bool {
+	content, err := utils.ToUnstructured(object)
+	if err != nil {
+		return false
+	}
+
+	gk := object.GetObjectKind().GroupVersionKind().GroupKind()
+	for _, r := range DefaultReferenceRewriters() {
+		if !r.Matches(gk, content) {
+			continue
+		}
+		if changed, err := r.Rewrite(runtime.DeepCopyJSON(content)); err == nil && len(changed) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func objectSource(object runtime.Object) string {
+	return object.GetObjectKind().GroupVersionKind().Kind + " " + objectName(object)
+}
+
+// groupReferences rewrites the group references found in the spec of the
+// objects of some kinds: group and apiGroups fields, apiVersion fields and
+// the group/version/Kind strings of kinds lists.
+type groupReferences struct {
+	kinds  []string
+	groups []string
+}
+
+func (g *groupReferences) Matches(gk schema.GroupKind, _ map[string]interface{}) bool {
+	for _, kind := range g.kinds {
+		if gk.String() == kind {
+			return true
+		}
+	}
+	for _, group := range g.groups {
+		if gk.Group == group {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *groupReferences) Rewrite(object map[string]interface{}) ([]string, error) {
+	var changed []string
+	rewriteGroups("spec", object["spec"], &changed)
+	sort.Strings(changed)
+	return changed, nil
+}
+
+func rewriteGroups(path string, value interface{}, changed *[]string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			fieldPath := path + "." + key
+			switch key {
+			case "group":
+				if s, ok := field.(string); ok && s == containousGroup {
+					v[key] = traefikGroup
+					*changed = append(*changed, fieldPath)
+					continue
+				}
+			case "apiVersion":
+				if s, ok := field.(string); ok && strings.HasPrefix(s, containousGroup+"/") {
+					v[key] = traefikGroup + strings.TrimPrefix(s, containousGroup)
+					*changed = append(*changed, fieldPath)
+					continue
+				}
+			case "apiGroups", "kinds":
+				if rewriteGroupList(fieldPath, field, changed) {
+					continue
+				}
+			}
+			rewriteGroups(fieldPath, field, changed)
+		}
+	case []interface{}:
+		for i, item := range v {
+			rewriteGroups(fmt.Sprintf("%s[%d]", path, i), item, changed)
+		}
+	}
+}
+
+// rewriteGroupList rewrites the groups of a list of groups, or of
+// group/version/Kind strings, and reports whether value is such a list.
+func rewriteGroupList(path string, value interface{}, changed *[]string) bool {
+	items, ok := value.([]interface{})
+	if !ok {
+		return false
+	}
+	for i, item := range items {
+		s, ok := item.(string)
+		if !ok {
+			return false
+		}
+		if s == containousGroup || strings.HasPrefix(s, containousGroup+"/") {
+			items[i] = traefikGroup + strings.TrimPrefix(s, containousGroup)
+			*changed = append(*changed, fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+	return true
+}
+
+// argoCDConfig rewrites the resource customizations, exclusions and
+// inclusions of the argocd-cm ConfigMap.
+type argoCDConfig struct{}
+
+func (a *argoCDConfig) Matches(gk schema.GroupKind, object map[string]interface{}) bool {
+	if gk.String() != "ConfigMap" {
+		return false
+	}
+	metadata, _ := object["metadata"].(map[string]interface{})
+	return metadata["name"] == "argocd-cm"
+}
+
+func (a *argoCDConfig) Rewrite(object map[string]interface{}) ([]string, error) {
+	data, _ := object["data"].(map[string]interface{})
+
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var changed []string
+	for _, key := range keys {
+		value, _ := data[key].(string)
+		switch {
+		case key == "resource.customizations", key == "resource.exclusions", key == "resource.inclusions":
+			converted, ok, err := rewriteEmbeddedGroups(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %v", key, err)
+			}
+			if ok {
+				data[key] = converted
+				changed = append(changed, "data."+key)
+			}
+		case strings.HasPrefix(key, "resource.customizations.") && strings.Contains(key, "."+containousGroup+"_"):
+			// the keys of the split customizations are suffixed with
+			// group_Kind.
+			renamed := strings.Replace(key, "."+containousGroup+"_", "."+traefikGroup+"_", 1)
+			delete(data, key)
+			data[renamed] = value
+			changed = append(changed, "data."+key)
+		}
+	}
+	return changed, nil
+}
+
+// rewriteEmbeddedGroups rewrites the group/Kind keys and the group values of
+// a YAML document embedded in a ConfigMap, keeping its formatting.
+func rewriteEmbeddedGroups(src string) (string, bool, error) {
+	var edits []utils.TextEdit
+	decoder := yaml.NewDecoder(strings.NewReader(src))
+	for {
+		var doc yaml.Node
+		if err := decoder.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return "", false, err
+		}
+
+		e, err := embeddedGroupEdits([]byte(src), &doc, false)
+		if err != nil {
+			return "", false, err
+		}
+		edits = append(edits, e...)
+	}
+	if len(edits) == 0 {
+		return src, false, nil
+	}
+
+	converted, err := utils.ApplyEdits([]byte(src), edits)
+	if err != nil {
+		return "", false, err
+	}
+	return string(converted), true, nil
+}
+
+func embeddedGroupEdits(src []byte, node *yaml.Node, key bool) ([]utils.TextEdit, error) {
+	var edits []utils.TextEdit
+
+	switch node.Kind {
+	case yaml.ScalarNode:
+		value := node.Value
+		switch {
+		case key && strings.HasPrefix(value, containousGroup+"/"):
+			value = traefikGroup + strings.TrimPrefix(value, containousGroup)
+		case !key && value == containousGroup:
+			value = traefikGroup
+		default:
+			return nil, nil
+		}
+		start, end, err := utils.YAMLScalarSpan(src, node)
+		if err != nil {
+			return nil, err
+		}
+		edits = append(edits, utils.TextEdit{Start: start, End: end, Text: utils.FormatYAMLScalar(value, node.Style)})
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			e, err := embeddedGroupEdits(src, node.Content[i], true)
+			if err != nil {
+				return nil, err
+			}
+			edits = append(edits, e...)
+			if e, err = embeddedGroupEdits(src, node.Content[i+1], false); err != nil {
+				return nil, err
+			}
+			edits = append(edits, e...)
+		}
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			e, err := embeddedGroupEdits(src, child, false)
+			if err != nil {
+				return nil, err
+			}
+			edits = append(edits, e...)
+		}
+	}
+
+	return edits, nil
+}
+
+// externalDNS makes external-dns deployments using the traefik-proxy source
+// ignore the traefik.containo.us group, which is no longer served.
+type externalDNS struct{}
+
+const (
+	externalDNSSource        = "--source=traefik-proxy"
+	externalDNSDisableLegacy = "--traefik-disable-legacy"
+)
+
+func (e *externalDNS) Matches(gk schema.GroupKind, object map[string]interface{}) bool {
+	switch gk.String() {
+	case "Deployment.apps", "StatefulSet.apps", "DaemonSet.apps":
+		return len(e.containers(object)) > 0
+	}
+	return false
+}
+
+func (e *externalDNS) Rewrite(object map[string]interface{}) ([]string, error) {
+	var changed []string
+	for path, container := range e.containers(object) {
+		args, _ := container["args"].([]interface{})
+		if !contains(stringItems(args), externalDNSDisableLegacy) {
+			container["args"] = append(args, externalDNSDisableLegacy)
+			changed = append(changed, path+".args")
+		}
+	}
+	sort.Strings(changed)
+	return changed, nil
+}
+
+// containers returns the containers of a workload running external-dns with
+// the traefik-proxy source, by path.
+func (e *externalDNS) containers(object map[string]interface{}) map[string]map[string]interface{} {
+	spec, _ := object["spec"].(map[string]interface{})
+	template, _ := spec["template"].(map[string]interface{})
+	podSpec, _ := template["spec"].(map[string]interface{})
+	containers, _ := podSpec["containers"].([]interface{})
+
+	matching := map[string]map[string]interface{}{}
+	for i, c := range containers {
+		container, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		args, _ := container["args"].([]interface{})
+		if contains(stringItems(args), externalDNSSource) {
+			matching[fmt.Sprintf("spec.template.spec.containers[%d]", i)] = container
+		}
+	}
+	return matching
+}
+
+func stringItems(items []interface{}) []string {
+	var values []string
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			values = append(values, s)
+		}
+	}
+	return values
+}
@@ -0,0 +1,433 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/databotic/traefik-migration-tool/internal/utils"
+	containous "github.com/traefik/traefik/v2/pkg/provider/kubernetes/crd/traefikcontainous/v1alpha1"
+	"github.com/traefik/traefik/v3/pkg/config/dynamic"
+	traefikio "github.com/traefik/traefik/v3/pkg/provider/kubernetes/crd/traefikio/v1alpha1"
+	"github.com/traefik/traefik/v3/pkg/rules"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// namedGroupPattern matches the named groups of a regular expression.
+var namedGroupPattern = regexp.MustCompile(`^\(\?P<(\w+)>`)
+
+// Reverse converts the traefik.io objects back to traefik.containo.us ones, so
+// a failed v3 rollout can be rolled back. Objects using features v2 doesn't
+// have are rejected.
+type Reverse struct {
+	rules    *IngressRoute
+	tcpRules *TCPRule
+	http     ruleParser
+	tcp      ruleParser
+}
+
+func NewReverse() (*Reverse, error) {
+	ingressRoutes, err := NewIngressRoute()
+	if err != nil {
+		return nil, err
+	}
+	tcpRules, err := NewTCPRule()
+	if err != nil {
+		return nil, err
+	}
+	http, err := rules.NewParser(httpMatchers)
+	if err != nil {
+		return nil, err
+	}
+	tcp, err := rules.NewParser(tcpMatchers)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Reverse{rules: ingressRoutes, tcpRules: tcpRules, http: http, tcp: tcp}, nil
+}
+
+// Reverse converts the traefik.io objects to traefik.containo.us ones instead
+// of converting the v2 objects. The other objects are left unchanged.
+func (c *Converter) Reverse() error {
+	reverse, err := NewReverse()
+	if err != nil {
+		return err
+	}
+
+	c.converters = map[string]ConvertFactory{}
+	for _, kind := range scheme.Scheme.KnownTypes(traefikio.SchemeGroupVersion) {
+		c.converters[kind.Name()+"."+traefikGroup] = reverse
+	}
+	c.references = nil
+	c.reverse = true
+	return nil
+}
+
+func (r *Reverse) Transform(object runtime.Object) (runtime.Object, error) {
+	gvk := object.GetObjectKind().GroupVersionKind()
+	source := gvk.Kind + " " + objectName(object)
+
+	v2, err := scheme.Scheme.New(containous.SchemeGroupVersion.WithKind(gvk.Kind))
+	if err != nil {
+		return nil, fmt.Errorf("%s: kind %s has no v2 equivalent", source, gvk.Kind)
+	}
+
+	var v3 runtime.Object
+	switch o := object.(type) {
+	case *traefikio.IngressRoute:
+		v3, err = r.ingressRoute(o)
+	case *traefikio.IngressRouteTCP:
+		v3, err = r.ingressRouteTCP(o)
+	case *traefikio.Middleware:
+		v3, err = r.middleware(o)
+	case *traefikio.MiddlewareTCP:
+		v3 = r.middlewareTCP(o)
+	default:
+		v3 = object.DeepCopyObject()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+
+	content, err := utils.ToUnstructured(v3)
+	if err != nil {
+		return nil, err
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(content, v2); err != nil {
+		return nil, fmt.Errorf("%s: %v", source, err)
+	}
+
+	dropped, err := utils.DroppedFields(v3, v2)
+	if err != nil {
+		return nil, err
+	}
+	if len(dropped) > 0 {
+		return nil, fmt.Errorf("%s: %s not supported by v2", source, strings.Join(dropped, ", "))
+	}
+
+	accessor, err := meta.Accessor(v2)
+	if err != nil {
+		return nil, err
+	}
+	accessor.SetAnnotations(utils.FilterAnnotations(accessor.GetAnnotations()))
+	v2.GetObjectKind().SetGroupVersionKind(containous.SchemeGroupVersion.WithKind(gvk.Kind))
+	return v2, nil
+}
+
+func (r *Reverse) ingressRoute(ingressRoute *traefikio.IngressRoute) (*traefikio.IngressRoute, error) {
+	converted := ingressRoute.DeepCopy()
+	for i, route := range converted.Spec.Routes {
+		rule, err := r.reverseRule(r.http, route.Match, route.Syntax)
+		if err != nil {
+			return nil, err
+		}
+		if err := r.rules.checkRoute(rule, "v2"); err != nil {
+			return nil, fmt.Errorf("rule %s can't be expressed in v2: %v", route.Match, err)
+		}
+		converted.Spec.Routes[i].Match = rule
+		converted.Spec.Routes[i].Syntax = ""
+	}
+	return converted, nil
+}
+
+func (r *Reverse) ingressRouteTCP(ingressRoute *traefikio.IngressRouteTCP) (*traefikio.IngressRouteTCP, error) {
+	converted := ingressRoute.DeepCopy()
+	for i, route := range converted.Spec.Routes {
+		rule, err := r.reverseRule(r.tcp, route.Match, route.Syntax)
+		if err != nil {
+			return nil, err
+		}
+		if err := r.tcpRules.checkRoute(rule, "v2"); err != nil {
+			return nil, fmt.Errorf("rule %s can't be expressed in v2: %v", route.Match, err)
+		}
+		converted.Spec.Routes[i].Match = rule
+		converted.Spec.Routes[i].Syntax = ""
+	}
+	return converted, nil
+}
+
+func (r *Reverse) middleware(middleware *traefikio.Middleware) (*traefikio.Middleware, error) {
+	converted := middleware.DeepCopy()
+	if allowList := converted.Spec.IPAllowList; allowList != nil {
+		// ipWhiteList has no rejectStatusCode, it would be lost with the
+		// move.
+		if allowList.RejectStatusCode != 0 {
+			return nil, fmt.Errorf("spec.ipAllowList.rejectStatusCode not supported by v2")
+		}
+		converted.Spec.IPAllowList = nil
+		converted.Spec.IPWhiteList = &dynamic.IPWhiteList{SourceRange: allowList.SourceRange, IPStrategy: allowList.IPStrategy}
+	}
+	return converted, nil
+}
+
+func (r *Reverse) middlewareTCP(middleware *traefikio.MiddlewareTCP) *traefikio.MiddlewareTCP {
+	converted := middleware.DeepCopy()
+	if allowList := converted.Spec.IPAllowList; allowList != nil {
+		converted.Spec.IPAllowList = nil
+		converted.Spec.IPWhiteList = &dynamic.TCPIPWhiteList{SourceRange: allowList.SourceRange}
+	}
+	return converted
+}
+
+// reverseRule rewrites a v3 router rule to the v2 syntax. The rules already
+// written with the v2 syntax are kept.
+func (r *Reverse) reverseRule(p ruleParser, rule, syntax string) (string, error) {
+	switch syntax {
+	case "v2":
+		return rule, nil
+	case "", "v3":
+	default:
+		return "", fmt.Errorf("rule %s uses the unknown syntax %s", rule, syntax)
+	}
+
+	tree, err := parseRule(p, rule)
+	if err != nil {
+		return "", err
+	}
+	return reverseTree(tree, "")
+}
+
+func reverseTree(tree *rules.Tree, parent string) (string, error) {
+	switch tree.Matcher {
+	case "and", "or":
+		left, err := reverseTree(tree.RuleLeft, tree.Matcher)
+		if err != nil {
+			return "", err
+		}
+		right, err := reverseTree(tree.RuleRight, tree.Matcher)
+		if err != nil {
+			return "", err
+		}
+
+		operator := " && "
+		if tree.Matcher == "or" {
+			operator = " || "
+		}
+		if parent != "" && parent != tree.Matcher {
+			return "(" + left + operator + right + ")", nil
+		}
+		return left + operator + right, nil
+	}
+
+	matcher, err := reverseMatcher(tree)
+	if err != nil {
+		return "", err
+	}
+	if tree.Not {
+		return "!" + matcher, nil
+	}
+	return matcher, nil
+}
+
+// reverseMatcher rewrites a v3 matcher to the v2 syntax. The regular
+// expressions become v2 templates.
+func reverseMatcher(tree *rules.Tree) (string, error) {
+	values := tree.Value
+
+	switch tree.Matcher {
+	case "Host", "Path", "PathPrefix", "Method", "ClientIP", "HostSNI", "ALPN":
+		return fmt.Sprintf("%s(`%s`)", tree.Matcher, values[0]), nil
+	case "HostRegexp", "HostSNIRegexp":
+		template, err := regexpTemplate(values[0], true)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s(`%s`)", tree.Matcher, template), nil
+	case "PathRegexp":
+		prefix := !strings.HasSuffix(values[0], "$") || strings.HasSuffix(values[0], `\$`)
+		template, err := regexpTemplate(values[0], false)
+		if err != nil {
+			return "", err
+		}
+		if prefix {
+			return fmt.Sprintf("PathPrefix(`%s`)", template), nil
+		}
+		return fmt.Sprintf("Path(`%s`)", template), nil
+	case "Header":
+		return fmt.Sprintf("Headers(`%s`, `%s`)", values[0], values[1]), nil
+	case "HeaderRegexp":
+		return fmt.Sprintf("HeadersRegexp(`%s`, `%s`)", values[0], values[1]), nil
+	case "Query":
+		if len(values) == 1 {
+			return fmt.Sprintf("Query(`%s=`)", values[0]), nil
+		}
+		return fmt.Sprintf("Query(`%s=%s`)", values[0], values[1]), nil
+	case "QueryRegexp":
+		template, err := regexpTemplate(values[1], true)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Query(`%s=%s`)", values[0], template), nil
+	}
+
+	return "", fmt.Errorf("matcher %s has no v2 equivalent", tree.Matcher)
+}
+
+// regexpTemplate turns a v3 regular expression into a v2 template, which is
+// anchored at both ends, or only at the start for paths. Literal characters
+// are kept and groups become variables, a pattern with other operators
+// becomes a single variable.
+func regexpTemplate(pattern string, anchoredEnd bool) (string, error) {
+	if _, err := regexp.Compile(pattern); err != nil {
+		return "", fmt.Errorf("invalid regular expression %s: %v", pattern, err)
+	}
+
+	start := strings.HasPrefix(pattern, "^")
+	end := strings.HasSuffix(pattern, "$") && !strings.HasSuffix(pattern, `\$`)
+	core := strings.TrimPrefix(pattern, "^")
+	if end {
+		core = strings.TrimSuffix(core, "$")
+	}
+
+	if start && (end || !anchoredEnd) {
+		if template, ok := literalTemplate(core); ok {
+			return template, nil
+		}
+	}
+
+	// the literal prefix is kept out of the variable, as v2 paths must
+	// start with a slash.
+	var prefix string
+	if start {
+		prefix, core = literalPrefix(core)
+	}
+
+	variable := nonCapturing(core)
+	if !start {
+		variable = ".*" + variable
+	}
+	if !end && anchoredEnd {
+		variable += ".*"
+	}
+	if strings.ContainsAny(variable, "{}") {
+		return "", fmt.Errorf("regular expression %s can't be expressed as a v2 template", pattern)
+	}
+	return prefix + "{re:" + variable + "}", nil
+}
+
+// literalPrefix splits a pattern into its leading literal characters, which
+// are unescaped, and the rest of it.
+func literalPrefix(pattern string) (string, string) {
+	var literal strings.Builder
+	var ends []int
+	i := 0
+	for i < len(pattern) {
+		c := pattern[i]
+		if c == '\\' && i+1 < len(pattern) && isQuotedMeta(pattern[i+1]) && pattern[i+1] != '{' && pattern[i+1] != '}' {
+			literal.WriteByte(pattern[i+1])
+			i += 2
+		} else if !isQuotedMeta(c) {
+			literal.WriteByte(c)
+			i++
+		} else {
+			break
+		}
+		ends = append(ends, i)
+	}
+
+	// a quantifier applies to the last literal character.
+	if i < len(pattern) && strings.IndexByte("*+?{", pattern[i]) >= 0 && len(ends) > 0 {
+		ends = ends[:len(ends)-1]
+		i = 0
+		if len(ends) > 0 {
+			i = ends[len(ends)-1]
+		}
+		return literal.String()[:len(ends)], pattern[i:]
+	}
+	return literal.String(), pattern[i:]
+}
+
+// literalTemplate builds the template of a pattern made of literal characters
+// and groups only.
+func literalTemplate(pattern string) (string, bool) {
+	var template strings.Builder
+	groups := 0
+
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case c == '\\':
+			if i+1 == len(pattern) || !isQuotedMeta(pattern[i+1]) || pattern[i+1] == '{' || pattern[i+1] == '}' {
+				return "", false
+			}
+			template.WriteByte(pattern[i+1])
+			i++
+		case c == '(':
+			end := groupEnd(pattern, i)
+			if end < 0 {
+				return "", false
+			}
+			group := pattern[i+1 : end]
+
+			name := fmt.Sprintf("group%d", groups)
+			if m := namedGroupPattern.FindStringSubmatch(pattern[i:]); m != nil {
+				name = m[1]
+				group = strings.TrimPrefix(group, m[0][1:])
+			} else {
+				group = strings.TrimPrefix(group, "?:")
+			}
+			groups++
+
+			inner := nonCapturing(group)
+			if strings.ContainsAny(inner, "{}") {
+				return "", false
+			}
+			template.WriteString("{" + name + ":" + inner + "}")
+			i = end
+		case isQuotedMeta(c):
+			return "", false
+		default:
+			template.WriteByte(c)
+		}
+	}
+	return template.String(), true
+}
+
+// groupEnd returns the index of the parenthesis closing the group opened at
+// start, or -1.
+func groupEnd(pattern string, start int) int {
+	depth := 0
+	for i := start; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// nonCapturing turns the groups of a pattern into non-capturing ones, which
+// v2 requires in the variables of a template.
+func nonCapturing(pattern string) string {
+	var converted strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == '\\' && i+1 < len(pattern):
+			converted.WriteString(pattern[i : i+2])
+			i++
+		case c == '(' && strings.HasPrefix(pattern[i:], "(?P<"):
+			m := namedGroupPattern.FindString(pattern[i:])
+			converted.WriteString("(?:")
+			i += len(m) - 1
+		case c == '(' && !strings.HasPrefix(pattern[i:], "(?"):
+			converted.WriteString("(?:")
+		default:
+			converted.WriteByte(c)
+		}
+	}
+	return converted.String()
+}
+
+func isQuotedMeta(c byte) bool {
+	return strings.IndexByte(`\.+*?()|[]{}^$`, c) >= 0
+}
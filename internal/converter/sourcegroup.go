@@ -0,0 +1,231 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/databotic/traefik-migration-tool/internal/utils"
+	containous "github.com/traefik/traefik/v2/pkg/provider/kubernetes/crd/traefikcontainous/v1alpha1"
+	"github.com/traefik/traefik/v3/pkg/config/dynamic"
+	traefikio "github.com/traefik/traefik/v3/pkg/provider/kubernetes/crd/traefikio/v1alpha1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// SourceGroup selects which objects hold a v2 configuration. Traefik 2.10
+// and 2.11 already serve traefik.io, so objects of that group may still be
+// written with the v2 rule syntax and middleware options.
+type SourceGroup string
+
+const (
+	// SourceGroupAuto converts the traefik.containo.us objects, and the
+	// traefik.io ones detected as written with v2 semantics.
+	SourceGroupAuto SourceGroup = "auto"
+	// SourceGroupContainous only converts the traefik.containo.us objects,
+	// the traefik.io ones are kept as they are.
+	SourceGroupContainous SourceGroup = containousGroup
+	// SourceGroupTraefik converts the traefik.io routes and middlewares as
+	// v2 objects as well, except the routes only valid in v3.
+	SourceGroupTraefik SourceGroup = traefikGroup
+)
+
+// v2TemplatePattern matches the {name} and {name:regexp} templates of the v2
+// Host, HostRegexp, Path and PathPrefix matchers, which v3 matches literally.
+var v2TemplatePattern = regexp.MustCompile(`\{[a-zA-Z_]\w*(?::[^}]+)?\}`)
+
+// SetSourceGroup selects which objects hold a v2 configuration.
+func (c *Converter) SetSourceGroup(group SourceGroup) error {
+	switch group {
+	case SourceGroupAuto, SourceGroupContainous, SourceGroupTraefik:
+	default:
+		return fmt.Errorf("unknown source group %s, expected %s, %s or %s", group, SourceGroupAuto, SourceGroupContainous, SourceGroupTraefik)
+	}
+	c.sourceGroup = group
+	return nil
+}
+
+// v2Objects converts the traefik.io objects holding a v2 configuration. The
+// rules written in the v2 syntax are rewritten in place, while Middlewares
+// are replaced by their traefik.containo.us counterpart, so they are
+// converted like the objects of the v2 group.
+func (c *Converter) v2Objects(objects []runtime.Object) ([]runtime.Object, error) {
+	if c.sourceGroup == SourceGroupContainous {
+		return objects, nil
+	}
+
+	replaced := make([]runtime.Object, 0, len(objects))
+	for _, o := range objects {
+		v2 := o
+		var err error
+		switch object := o.(type) {
+		case *traefikio.IngressRoute:
+			v2, err = c.v2IngressRoute(object)
+		case *traefikio.IngressRouteTCP:
+			v2, err = c.v2IngressRouteTCP(object)
+		case *traefikio.Middleware:
+			v2, err = c.v2Middleware(object)
+		case *traefikio.MiddlewareTCP:
+			v2 = c.v2MiddlewareTCP(object)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", objectSource(o), err)
+		}
+		replaced = append(replaced, v2)
+	}
+	return replaced, nil
+}
+
+// v2IngressRoute rewrites the rules of an IngressRoute written in the v2
+// syntax.
+func (c *Converter) v2IngressRoute(ingressRoute *traefikio.IngressRoute) (runtime.Object, error) {
+	converted := ingressRoute.DeepCopy()
+	var semantics []string
+	for i := range converted.Spec.Routes {
+		route := &converted.Spec.Routes[i]
+		rule := route.Match
+		v2, err := c.v2Route(c.ingressRoutes, objectSource(ingressRoute), &route.Match, &route.Syntax)
+		if err != nil {
+			return nil, err
+		}
+		if v2 {
+			semantics = append(semantics, fmt.Sprintf("rule %s uses the v2 syntax", rule))
+		}
+	}
+	c.reportV2(ingressRoute, semantics)
+	return converted, nil
+}
+
+// v2IngressRouteTCP rewrites the rules of an IngressRouteTCP written in the
+// v2 syntax.
+func (c *Converter) v2IngressRouteTCP(ingressRoute *traefikio.IngressRouteTCP) (runtime.Object, error) {
+	converted := ingressRoute.DeepCopy()
+	var semantics []string
+	for i := range converted.Spec.Routes {
+		route := &converted.Spec.Routes[i]
+		rule := route.Match
+		v2, err := c.v2Route(c.tcpRules, objectSource(ingressRoute), &route.Match, &route.Syntax)
+		if err != nil {
+			return nil, err
+		}
+		if v2 {
+			semantics = append(semantics, fmt.Sprintf("rule %s uses the v2 syntax", rule))
+		}
+	}
+	c.reportV2(ingressRoute, semantics)
+	return converted, nil
+}
+
+// ruleSyntax checks and converts the router rules of a protocol.
+type ruleSyntax interface {
+	ConvertRule(rule string) (string, error)
+	checkRoute(rule string, syntax string) error
+}
+
+// v2Route rewrites the rule of a route to v3 when it holds a v2 one, and
+// reports whether it was detected as written in the v2 syntax. Routes
+// selecting the v3 syntax, or whose rule is only valid in v3, are kept as
+// they are. Routes selecting the v2 syntax, and the rules valid in both
+// syntaxes, are only converted for the traefik.io source group.
+func (c *Converter) v2Route(rules ruleSyntax, source string, match, syntax *string) (bool, error) {
+	switch {
+	case *syntax == "v2" && c.sourceGroup == SourceGroupTraefik:
+	case *syntax != "":
+		return false, nil
+	case rules.checkRoute(*match, "v2") != nil:
+		if c.sourceGroup == SourceGroupTraefik {
+			c.report.Add(source, "rule %s is only valid in v3 and was kept as it is", *match)
+		}
+		return false, nil
+	}
+
+	v2 := *syntax == "" && (rules.checkRoute(*match, "v3") != nil || v2TemplatePattern.MatchString(*match))
+	if !v2 && c.sourceGroup != SourceGroupTraefik {
+		return false, nil
+	}
+
+	rule, err := rules.ConvertRule(*match)
+	if err != nil {
+		return false, err
+	}
+	*match, *syntax = rule, ""
+	return v2, nil
+}
+
+// v2Middleware replaces a Middleware holding v2 options by its
+// traefik.containo.us counterpart.
+func (c *Converter) v2Middleware(middleware *traefikio.Middleware) (runtime.Object, error) {
+	semantics := v2MiddlewareOptions(middleware)
+	if len(semantics) == 0 && c.sourceGroup != SourceGroupTraefik {
+		return middleware, nil
+	}
+
+	v2, err := asContainous[containous.Middleware](middleware)
+	if err != nil {
+		return nil, err
+	}
+	c.reportV2(middleware, semantics)
+
+	dropped, err := utils.DroppedFields(middleware, v2)
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range dropped {
+		c.report.Add(objectSource(middleware), "%s has no v2 counterpart and was dropped", field)
+	}
+	return v2, nil
+}
+
+// v2MiddlewareTCP moves the deprecated ipWhiteList option of a MiddlewareTCP
+// to ipAllowList, the only v2 option v3 renamed.
+func (c *Converter) v2MiddlewareTCP(middleware *traefikio.MiddlewareTCP) runtime.Object {
+	whiteList := middleware.Spec.IPWhiteList
+	if whiteList == nil {
+		return middleware
+	}
+
+	converted := middleware.DeepCopy()
+	converted.Spec.IPWhiteList = nil
+	if converted.Spec.IPAllowList == nil {
+		converted.Spec.IPAllowList = &dynamic.TCPIPAllowList{SourceRange: whiteList.SourceRange}
+	}
+	c.reportV2(middleware, []string{"ipWhiteList is deprecated"})
+	return converted
+}
+
+// reportV2 reports the v2 semantics detected in a traefik.io object.
+func (c *Converter) reportV2(object runtime.Object, semantics []string) {
+	if len(semantics) > 0 {
+		c.report.Add(objectSource(object), "%s in %s, converted as a v2 object", strings.Join(semantics, ", "), traefikGroup)
+	}
+}
+
+// v2MiddlewareOptions returns the options of a middleware deprecated or
+// removed in v3.
+func v2MiddlewareOptions(middleware *traefikio.Middleware) []string {
+	var semantics []string
+	if middleware.Spec.IPWhiteList != nil {
+		semantics = append(semantics, "ipWhiteList is deprecated")
+	}
+	for _, option := range utils.DepricatedOptions(middleware.Spec) {
+		semantics = append(semantics, option+" was removed")
+	}
+	return semantics
+}
+
+// asContainous returns the traefik.containo.us counterpart of a traefik.io
+// object.
+func asContainous[T any, PT interface {
+	*T
+	runtime.Object
+}](object runtime.Object) (runtime.Object, error) {
+	v2, err := utils.AsType[T](object)
+	if err != nil {
+		return nil, fmt.Errorf("error converting %s to %s: %v", objectName(object), containousGroup, err)
+	}
+
+	converted := PT(v2)
+	gvk := object.GetObjectKind().GroupVersionKind()
+	gvk.Group = containousGroup
+	converted.GetObjectKind().SetGroupVersionKind(gvk)
+	return converted, nil
+}
@@ -0,0 +1,105 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/databotic/traefik-migration-tool/internal/utils"
+	tcpmuxer "github.com/traefik/traefik/v3/pkg/muxer/tcp"
+	"github.com/traefik/traefik/v3/pkg/tcp"
+)
+
+var rewriteTCPFunc = map[string]func(v []string) string{
+	"HostSNI":       rewriteHostSNI,
+	"HostSNIRegexp": rewriteHostSNIRegexp,
+	"ClientIP":      rewriteClientIP,
+	"ALPN":          rewriteALPN,
+}
+
+type TCPRule struct {
+	muxer *tcpmuxer.Muxer
+}
+
+func NewTCPRule() (*TCPRule, error) {
+	muxer, err := tcpmuxer.NewMuxer()
+	if err != nil {
+		return nil, err
+	}
+
+	return &TCPRule{
+		muxer: muxer,
+	}, nil
+}
+
+// ConvertRule rewrites a v2 TCP router rule to the v3 syntax. Both the given
+// rule and the result are validated against the muxer of their syntax.
+func (t *TCPRule) ConvertRule(rule string) (string, error) {
+	if err := t.checkRoute(rule, "v2"); err != nil {
+		return "", err
+	}
+
+	m := transformRule(rule, rewriteTCPFunc)
+	if len(m) == 0 {
+		return rule, nil
+	}
+
+	if err := t.checkRoute(m, "v3"); err != nil {
+		return "", err
+	}
+	return m, nil
+}
+
+func rewriteHostSNI(values []string) string {
+	var transformedArgs []string
+	for _, v := range values {
+		transformedArgs = append(transformedArgs, fmt.Sprintf("HostSNI(`%s`)", v))
+	}
+
+	if len(transformedArgs) > 1 {
+		return "(" + strings.Join(transformedArgs, " || ") + ")"
+	}
+
+	return strings.Join(transformedArgs, " ")
+}
+
+func rewriteHostSNIRegexp(values []string) string {
+	var transformedArgs []string
+	for _, v := range values {
+		pattern, err := utils.RouteRegexp(v, utils.RegexpTypeHost)
+		if err != nil {
+			panic(err)
+		}
+		if pattern == v {
+			pattern = "^" + strings.ReplaceAll(v, ".", `\.`) + "$"
+		}
+		transformedArgs = append(transformedArgs, fmt.Sprintf("HostSNIRegexp(`%s`)", pattern))
+	}
+
+	if len(transformedArgs) > 1 {
+		return "(" + strings.Join(transformedArgs, " || ") + ")"
+	}
+
+	return strings.Join(transformedArgs, " ")
+}
+
+func rewriteALPN(values []string) string {
+	var transformedArgs []string
+	for _, v := range values {
+		transformedArgs = append(transformedArgs, fmt.Sprintf("ALPN(`%s`)", v))
+	}
+
+	if len(transformedArgs) > 1 {
+		return "(" + strings.Join(transformedArgs, " || ") + ")"
+	}
+
+	return strings.Join(transformedArgs, " ")
+}
+
+func (t *TCPRule) checkRoute(rule string, syntax string) error {
+	handler := tcp.HandlerFunc(func(conn tcp.WriteCloser) {})
+	if err := t.muxer.AddRoute(rule, syntax, 0, handler); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,35 @@
+// Package crd bundles the CustomResourceDefinitions of Traefik v3.
+package crd
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+
+	"github.com/databotic/traefik-migration-tool/internal/parser"
+)
+
+// Version is the Traefik release the definitions come from.
+const Version = "v3.0.1"
+
+//go:embed traefik.io_v1alpha1.yaml
+var definitions []byte
+
+// Definitions returns the v3 CustomResourceDefinitions, in the order of the
+// Traefik release manifest.
+func Definitions() ([]*parser.Unknown, error) {
+	objects, err := parser.ParseManifest(bytes.NewReader(definitions))
+	if err != nil {
+		return nil, err
+	}
+
+	crds := make([]*parser.Unknown, 0, len(objects))
+	for _, object := range objects {
+		crd, ok := object.(*parser.Unknown)
+		if !ok {
+			return nil, fmt.Errorf("unexpected object %T in the bundled definitions", object)
+		}
+		crds = append(crds, crd)
+	}
+	return crds, nil
+}
@@ -0,0 +1,368 @@
+package fileprovider
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/databotic/traefik-migration-tool/internal/report"
+	"github.com/databotic/traefik-migration-tool/internal/utils"
+	"github.com/traefik/traefik/v3/pkg/config/dynamic"
+	traefikio "github.com/traefik/traefik/v3/pkg/provider/kubernetes/crd/traefikio/v1alpha1"
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/api/meta"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// traefikGroups are the API groups of the Traefik resources.
+var traefikGroups = []string{"traefik.io", "traefik.containo.us"}
+
+// Exporter builds a file provider dynamic configuration from IngressRoutes,
+// Middlewares and TraefikServices. Objects are named namespace-name, and
+// the Kubernetes services they reference become load balancers whose
+// servers are looked up in the endpoints.
+type Exporter struct {
+	endpoints map[string][]string
+	report    *report.Report
+	config    *dynamic.HTTPConfiguration
+}
+
+func NewExporter(endpoints map[string][]string, r *report.Report) *Exporter {
+	return &Exporter{endpoints: endpoints, report: r}
+}
+
+// ReadEndpoints reads the servers of the Kubernetes services, a YAML map of
+// lists of URLs keyed by namespace/name:port, or namespace/name for all the
+// ports of a service.
+func ReadEndpoints(content []byte) (map[string][]string, error) {
+	endpoints := map[string][]string{}
+	if err := yaml.Unmarshal(content, &endpoints); err != nil {
+		return nil, fmt.Errorf("error parsing endpoints: %v", err)
+	}
+	return endpoints, nil
+}
+
+// Export returns the dynamic configuration holding the Traefik resources of
+// objects. Other kinds of Traefik resources are reported, other objects are
+// ignored.
+func (e *Exporter) Export(objects []runtime.Object) (*dynamic.Configuration, error) {
+	e.config = &dynamic.HTTPConfiguration{
+		Routers:     map[string]*dynamic.Router{},
+		Middlewares: map[string]*dynamic.Middleware{},
+		Services:    map[string]*dynamic.Service{},
+	}
+
+	for _, o := range objects {
+		gvk := o.GetObjectKind().GroupVersionKind()
+		if !slices.Contains(traefikGroups, gvk.Group) {
+			continue
+		}
+
+		var err error
+		switch gvk.Kind {
+		case "IngressRoute":
+			err = e.ingressRoute(o)
+		case "Middleware":
+			err = e.middleware(o)
+		case "TraefikService":
+			err = e.traefikService(o)
+		default:
+			accessor, err := meta.Accessor(o)
+			if err != nil {
+				return nil, err
+			}
+			e.report.Add(gvk.Kind+" "+objectName(accessor), "kind %s is not exported to the file provider", gvk.Kind)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &dynamic.Configuration{HTTP: e.config}, nil
+}
+
+func (e *Exporter) ingressRoute(object runtime.Object) error {
+	ingressRoute, err := utils.AsType[traefikio.IngressRoute](object)
+	if err != nil {
+		return err
+	}
+	source := "IngressRoute " + objectName(ingressRoute)
+	namespace := ingressRoute.Namespace
+
+	tls := e.routerTLS(source, namespace, ingressRoute.Spec.TLS)
+
+	for i, route := range ingressRoute.Spec.Routes {
+		name := qualify(namespace, ingressRoute.Name)
+		if len(ingressRoute.Spec.Routes) > 1 {
+			name = fmt.Sprintf("%s-%d", name, i+1)
+		}
+
+		router := &dynamic.Router{
+			EntryPoints: ingressRoute.Spec.EntryPoints,
+			Rule:        route.Match,
+			RuleSyntax:  route.Syntax,
+			Priority:    route.Priority,
+			TLS:         tls,
+		}
+		for _, ref := range route.Middlewares {
+			router.Middlewares = append(router.Middlewares, middlewareName(namespace, ref))
+		}
+
+		switch len(route.Services) {
+		case 0:
+			e.report.Add(source, "route %s has no service, its router was dropped", route.Match)
+			continue
+		case 1:
+			router.Service = e.service(source, name, namespace, route.Services[0].LoadBalancerSpec)
+		default:
+			router.Service = name
+			e.config.Services[name] = &dynamic.Service{Weighted: e.weighted(source, name, namespace, route.Services, nil)}
+		}
+
+		e.config.Routers[name] = router
+	}
+
+	return nil
+}
+
+// routerTLS returns the TLS configuration of the routers of an IngressRoute,
+// reporting the parts read from other Kubernetes objects.
+func (e *Exporter) routerTLS(source, namespace string, tls *traefikio.TLS) *dynamic.RouterTLSConfig {
+	if tls == nil {
+		return nil
+	}
+
+	config := &dynamic.RouterTLSConfig{CertResolver: tls.CertResolver, Domains: tls.Domains}
+	if tls.SecretName != "" {
+		e.report.Add(source, "certificate of secret %s/%s must be added to tls.certificates", namespace, tls.SecretName)
+	}
+	if tls.Options != nil {
+		config.Options = qualify(defaultNamespace(tls.Options.Namespace, namespace), tls.Options.Name)
+		e.report.Add(source, "tls option %s must be defined in tls.options", config.Options)
+	}
+	if tls.Store != nil {
+		e.report.Add(source, "tls store %s/%s must be defined in tls.stores", defaultNamespace(tls.Store.Namespace, namespace), tls.Store.Name)
+	}
+
+	return config
+}
+
+func (e *Exporter) middleware(object runtime.Object) error {
+	middleware, err := utils.AsType[traefikio.Middleware](object)
+	if err != nil {
+		return err
+	}
+	source := "Middleware " + objectName(middleware)
+	namespace := middleware.Namespace
+	name := qualify(namespace, middleware.Name)
+	spec := middleware.Spec
+
+	// the fields referencing other Kubernetes objects have no counterpart
+	// in the dynamic configuration, they are converted separately.
+	chain, errorPage := spec.Chain, spec.Errors
+	spec.Chain, spec.Errors = nil, nil
+
+	if spec.BasicAuth != nil && spec.BasicAuth.Secret != "" {
+		e.report.Add(source, "users of secret %s/%s must be set in basicAuth.users", namespace, spec.BasicAuth.Secret)
+	}
+	if spec.DigestAuth != nil && spec.DigestAuth.Secret != "" {
+		e.report.Add(source, "users of secret %s/%s must be set in digestAuth.users", namespace, spec.DigestAuth.Secret)
+	}
+	if spec.ForwardAuth != nil && spec.ForwardAuth.TLS != nil {
+		if spec.ForwardAuth.TLS.CASecret != "" {
+			e.report.Add(source, "CA of secret %s/%s must be set in forwardAuth.tls.ca", namespace, spec.ForwardAuth.TLS.CASecret)
+		}
+		if spec.ForwardAuth.TLS.CertSecret != "" {
+			e.report.Add(source, "certificate of secret %s/%s must be set in forwardAuth.tls.cert and key", namespace, spec.ForwardAuth.TLS.CertSecret)
+		}
+	}
+
+	config, err := utils.AsType[dynamic.Middleware](spec)
+	if err != nil {
+		return fmt.Errorf("error exporting middleware %s: %v", objectName(middleware), err)
+	}
+
+	if chain != nil {
+		config.Chain = &dynamic.Chain{}
+		for _, ref := range chain.Middlewares {
+			config.Chain.Middlewares = append(config.Chain.Middlewares, middlewareName(namespace, ref))
+		}
+	}
+	if errorPage != nil {
+		config.Errors = &dynamic.ErrorPage{
+			Status:  errorPage.Status,
+			Service: e.service(source, name, namespace, errorPage.Service.LoadBalancerSpec),
+			Query:   errorPage.Query,
+		}
+	}
+
+	e.config.Middlewares[name] = config
+	return nil
+}
+
+func (e *Exporter) traefikService(object runtime.Object) error {
+	traefikService, err := utils.AsType[traefikio.TraefikService](object)
+	if err != nil {
+		return err
+	}
+	source := "TraefikService " + objectName(traefikService)
+	namespace := traefikService.Namespace
+	name := qualify(namespace, traefikService.Name)
+
+	switch spec := traefikService.Spec; {
+	case spec.Weighted != nil:
+		e.config.Services[name] = &dynamic.Service{Weighted: e.weighted(source, name, namespace, spec.Weighted.Services, spec.Weighted.Sticky)}
+	case spec.Mirroring != nil:
+		mirroring := &dynamic.Mirroring{
+			Service:     e.service(source, name, namespace, spec.Mirroring.LoadBalancerSpec),
+			MaxBodySize: spec.Mirroring.MaxBodySize,
+		}
+		for _, mirror := range spec.Mirroring.Mirrors {
+			mirroring.Mirrors = append(mirroring.Mirrors, dynamic.MirrorService{
+				Name:    e.service(source, name, namespace, mirror.LoadBalancerSpec),
+				Percent: mirror.Percent,
+			})
+		}
+		e.config.Services[name] = &dynamic.Service{Mirroring: mirroring}
+	default:
+		e.report.Add(source, "TraefikService has neither weighted nor mirroring, it was dropped")
+	}
+
+	return nil
+}
+
+func (e *Exporter) weighted(source, owner, namespace string, services []traefikio.Service, sticky *dynamic.Sticky) *dynamic.WeightedRoundRobin {
+	weighted := &dynamic.WeightedRoundRobin{Sticky: sticky}
+	for _, service := range services {
+		weighted.Services = append(weighted.Services, dynamic.WRRService{
+			Name:   e.service(source, owner, namespace, service.LoadBalancerSpec),
+			Weight: service.Weight,
+		})
+	}
+	return weighted
+}
+
+// service returns the name of the dynamic service a route, middleware or
+// TraefikService reference points to. References to Kubernetes services
+// add a load balancer named after the owner of the reference.
+func (e *Exporter) service(source, owner, namespace string, spec traefikio.LoadBalancerSpec) string {
+	namespace = defaultNamespace(spec.Namespace, namespace)
+	if spec.Kind == "TraefikService" {
+		if strings.Contains(spec.Name, "@") {
+			return spec.Name
+		}
+		return qualify(namespace, spec.Name)
+	}
+
+	name := owner + "-" + spec.Name
+	if port := spec.Port.String(); port != "0" && port != "" {
+		name += "-" + port
+	}
+
+	if spec.ServersTransport != "" {
+		e.report.Add(source, "serversTransport %s of service %s/%s must be defined in http.serversTransports", spec.ServersTransport, namespace, spec.Name)
+	}
+	if spec.NativeLB != nil && *spec.NativeLB {
+		e.report.Add(source, "nativeLB of service %s/%s has no file provider equivalent, the endpoints are used", namespace, spec.Name)
+	}
+
+	loadBalancer := &dynamic.ServersLoadBalancer{
+		Sticky:           spec.Sticky,
+		PassHostHeader:   spec.PassHostHeader,
+		ServersTransport: spec.ServersTransport,
+	}
+	if spec.ResponseForwarding != nil {
+		forwarding, err := utils.AsType[dynamic.ResponseForwarding](spec.ResponseForwarding)
+		if err != nil {
+			e.report.Add(source, "responseForwarding of service %s/%s is invalid, it was dropped: %v", namespace, spec.Name, err)
+		} else {
+			loadBalancer.ResponseForwarding = forwarding
+		}
+	}
+	for _, url := range e.servers(source, namespace, spec) {
+		loadBalancer.Servers = append(loadBalancer.Servers, dynamic.Server{URL: url})
+	}
+
+	e.config.Services[name] = &dynamic.Service{LoadBalancer: loadBalancer}
+	return name
+}
+
+// servers returns the URLs of the servers of a Kubernetes service, from the
+// endpoints or, when they are missing, from the service DNS name.
+func (e *Exporter) servers(source, namespace string, spec traefikio.LoadBalancerSpec) []string {
+	scheme := serviceScheme(spec)
+	service := namespace + "/" + spec.Name
+	port := spec.Port.String()
+
+	urls, ok := e.endpoints[service+":"+port]
+	if !ok {
+		urls, ok = e.endpoints[service]
+	}
+	if ok {
+		var servers []string
+		for _, url := range urls {
+			if !strings.Contains(url, "://") {
+				url = scheme + "://" + url
+			}
+			servers = append(servers, url)
+		}
+		return servers
+	}
+
+	url := fmt.Sprintf("%s://%s.%s.svc", scheme, spec.Name, namespace)
+	if spec.Port.Type == intstr.Int && spec.Port.IntVal != 0 {
+		url += ":" + port
+	} else if port != "" && port != "0" {
+		e.report.Add(source, "port %s of service %s is named, set its servers in the endpoints", port, service)
+	}
+	e.report.Add(source, "service %s:%s has no endpoints, %s is used as its server", service, port, url)
+	return []string{url}
+}
+
+// serviceScheme returns the scheme of the servers of a Kubernetes service, as
+// the kubernetescrd provider does.
+func serviceScheme(spec traefikio.LoadBalancerSpec) string {
+	if spec.Scheme != "" {
+		return spec.Scheme
+	}
+	if spec.Port.IntVal == 443 || strings.HasPrefix(spec.Port.StrVal, "https") {
+		return "https"
+	}
+	return "http"
+}
+
+// middlewareName returns the name of the middleware a reference points to.
+// References to other providers are kept, the kubernetescrd ones already
+// hold the namespace.
+func middlewareName(namespace string, ref traefikio.MiddlewareRef) string {
+	if name, ok := strings.CutSuffix(ref.Name, "@kubernetescrd"); ok {
+		return name
+	}
+	if strings.Contains(ref.Name, "@") {
+		return ref.Name
+	}
+	return qualify(defaultNamespace(ref.Namespace, namespace), ref.Name)
+}
+
+func qualify(namespace, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + "-" + name
+}
+
+func defaultNamespace(namespace, fallback string) string {
+	if namespace != "" {
+		return namespace
+	}
+	return fallback
+}
+
+func objectName(object v1.Object) string {
+	if object.GetNamespace() == "" {
+		return object.GetName()
+	}
+	return object.GetNamespace() + "/" + object.GetName()
+}
@@ -0,0 +1,238 @@
+package fileprovider
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/databotic/traefik-migration-tool/internal/converter"
+	"github.com/databotic/traefik-migration-tool/internal/report"
+	"github.com/databotic/traefik-migration-tool/internal/utils"
+	containous "github.com/traefik/traefik/v2/pkg/config/dynamic"
+	"github.com/traefik/traefik/v3/pkg/config/dynamic"
+	"gopkg.in/yaml.v3"
+)
+
+type Format string
+
+const (
+	FormatYAML Format = "yaml"
+	FormatTOML Format = "toml"
+)
+
+var rootKeys = []string{"http", "tcp", "udp", "tls"}
+
+// Converter converts file provider dynamic configurations from v2 to v3.
+type Converter struct {
+	ingressRoute *converter.IngressRoute
+	tcpRule      *converter.TCPRule
+	report       *report.Report
+}
+
+func New(r *report.Report) (*Converter, error) {
+	ingressRoute, err := converter.NewIngressRoute()
+	if err != nil {
+		return nil, err
+	}
+
+	tcpRule, err := converter.NewTCPRule()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Converter{ingressRoute: ingressRoute, tcpRule: tcpRule, report: r}, nil
+}
+
+// Detect reports whether content is a file provider dynamic configuration
+// rather than a kubernetes manifest, and in which format it is written.
+func Detect(content []byte, ext string) (Format, bool) {
+	var raw map[string]interface{}
+
+	switch strings.ToLower(strings.TrimPrefix(ext, ".")) {
+	case "toml":
+		return FormatTOML, true
+	case "yaml", "yml", "json":
+		if err := yaml.Unmarshal(content, &raw); err != nil {
+			return "", false
+		}
+		return FormatYAML, isDynamic(raw)
+	}
+
+	if err := yaml.Unmarshal(content, &raw); err == nil {
+		return FormatYAML, isDynamic(raw)
+	}
+	if err := toml.Unmarshal(content, &raw); err == nil {
+		return FormatTOML, isDynamic(raw)
+	}
+	return "", false
+}
+
+func isDynamic(raw map[string]interface{}) bool {
+	if _, ok := raw["apiVersion"]; ok {
+		return false
+	}
+	for key := range raw {
+		for _, root := range rootKeys {
+			if strings.EqualFold(key, root) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *Converter) Convert(content []byte, format Format) ([]byte, error) {
+	v2Config, err := c.decode(content, format)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := c.Transform(v2Config)
+	if err != nil {
+		return nil, err
+	}
+
+	return Encode(config, format)
+}
+
+// Transform converts a v2 dynamic configuration to v3, rewriting router
+// rules and renamed middlewares.
+func (c *Converter) Transform(v2Config *containous.Configuration) (*dynamic.Configuration, error) {
+	config, err := utils.AsType[dynamic.Configuration](v2Config)
+	if err != nil {
+		return nil, err
+	}
+
+	dropped, err := utils.DroppedFields(v2Config, config)
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range dropped {
+		c.report.Add("file provider", "%s is not supported by v3 and was removed", field)
+	}
+
+	if err := c.transformHTTP(v2Config.HTTP, config.HTTP); err != nil {
+		return nil, err
+	}
+	if err := c.transformTCP(config.TCP); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+func (c *Converter) transformHTTP(v2Config *containous.HTTPConfiguration, config *dynamic.HTTPConfiguration) error {
+	if config == nil {
+		return nil
+	}
+
+	for name, router := range config.Routers {
+		rule, err := c.ingressRoute.ConvertRule(router.Rule)
+		if err != nil {
+			return fmt.Errorf("router %s: %w", name, err)
+		}
+		router.Rule = rule
+	}
+
+	for name, middleware := range config.Middlewares {
+		for _, opt := range utils.DepricatedOptions(v2Config.Middlewares[name]) {
+			c.report.Add("middleware "+name, "option %s was removed in v3 and should be fixed manually", opt)
+		}
+
+		if middleware.IPWhiteList == nil {
+			continue
+		}
+		ipAllowList, err := utils.AsType[dynamic.IPAllowList](middleware.IPWhiteList)
+		if err != nil {
+			return fmt.Errorf("middleware %s: %w", name, err)
+		}
+		middleware.IPWhiteList = nil
+		middleware.IPAllowList = ipAllowList
+	}
+
+	return nil
+}
+
+func (c *Converter) transformTCP(config *dynamic.TCPConfiguration) error {
+	if config == nil {
+		return nil
+	}
+
+	for name, router := range config.Routers {
+		rule, err := c.tcpRule.ConvertRule(router.Rule)
+		if err != nil {
+			return fmt.Errorf("tcp router %s: %w", name, err)
+		}
+		router.Rule = rule
+	}
+
+	for name, middleware := range config.Middlewares {
+		if middleware.IPWhiteList == nil {
+			continue
+		}
+		ipAllowList, err := utils.AsType[dynamic.TCPIPAllowList](middleware.IPWhiteList)
+		if err != nil {
+			return fmt.Errorf("tcp middleware %s: %w", name, err)
+		}
+		middleware.IPWhiteList = nil
+		middleware.IPAllowList = ipAllowList
+	}
+
+	return nil
+}
+
+func (c *Converter) decode(content []byte, format Format) (*containous.Configuration, error) {
+	config := &containous.Configuration{}
+
+	switch format {
+	case FormatTOML:
+		meta, err := toml.Decode(string(content), config)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing dynamic configuration: %v", err)
+		}
+		for _, key := range meta.Undecoded() {
+			c.report.Add("file provider", "unknown option %s was ignored", key.String())
+		}
+	default:
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(config); err != nil {
+			return nil, fmt.Errorf("error parsing dynamic configuration: %v", err)
+		}
+	}
+
+	return config, nil
+}
+
+// Encode writes a v3 dynamic configuration in the given format.
+func Encode(config *dynamic.Configuration, format Format) ([]byte, error) {
+	raw, err := utils.ToMap(config)
+	if err != nil {
+		return nil, err
+	}
+	pruneClientAuth(raw)
+
+	if format == FormatTOML {
+		var out bytes.Buffer
+		if err := toml.NewEncoder(&out).Encode(raw); err != nil {
+			return nil, fmt.Errorf("failed to marshal toml configuration: %v", err)
+		}
+		return out.Bytes(), nil
+	}
+
+	return utils.EncodeYaml(raw)
+}
+
+// pruneClientAuth removes the empty clientAuth sections which are always
+// marshalled for tls options, as ClientAuth is not a pointer.
+func pruneClientAuth(raw map[string]interface{}) {
+	tls, _ := raw["tls"].(map[string]interface{})
+	options, _ := tls["options"].(map[string]interface{})
+	for _, o := range options {
+		option, _ := o.(map[string]interface{})
+		if clientAuth, ok := option["clientAuth"].(map[string]interface{}); ok && len(clientAuth) == 0 {
+			delete(option, "clientAuth")
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package fileprovider
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/databotic/traefik-migration-tool/internal/converter"
+	"github.com/databotic/traefik-migration-tool/internal/parser"
+	"github.com/databotic/traefik-migration-tool/internal/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var updateExpected = flag.Bool("update_expected", false, "Update expected files in testdata")
+
+func TestConvert(t *testing.T) {
+	testCases := []struct {
+		file   string
+		format Format
+	}{
+		{
+			file:   "dynamic.yaml",
+			format: FormatYAML,
+		},
+		{
+			file:   "dynamic.toml",
+			format: FormatTOML,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.file, func(t *testing.T) {
+			src, err := os.ReadFile(filepath.Join("fixtures", "input", test.file))
+			require.NoError(t, err)
+
+			format, ok := Detect(src, filepath.Ext(test.file))
+			require.True(t, ok)
+			assert.Equal(t, test.format, format)
+
+			c, err := New(report.New())
+			require.NoError(t, err)
+
+			converted, err := c.Convert(src, format)
+			require.NoError(t, err)
+
+			fixtureFile := filepath.Join("fixtures", "output", test.file)
+			if *updateExpected {
+				require.NoError(t, os.WriteFile(fixtureFile, converted, 0o666))
+			}
+
+			expected, err := os.ReadFile(fixtureFile)
+			require.NoError(t, err)
+			assert.Equal(t, string(expected), string(converted))
+		})
+	}
+}
+
+func TestDetect(t *testing.T) {
+	_, ok := Detect([]byte("apiVersion: traefik.containo.us/v1alpha1\nkind: Middleware\n"), "")
+	assert.False(t, ok)
+
+	format, ok := Detect([]byte("[http.routers.api]\n  rule = \"Host(`a`)\"\n"), "")
+	assert.True(t, ok)
+	assert.Equal(t, FormatTOML, format)
+}
+
+func TestExport(t *testing.T) {
+	testCases := []struct {
+		file   string
+		format Format
+	}{
+		{
+			file:   "export.yaml",
+			format: FormatYAML,
+		},
+		{
+			file:   "export.toml",
+			format: FormatTOML,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.file, func(t *testing.T) {
+			src, err := os.ReadFile(filepath.Join("fixtures", "input", "export.yaml"))
+			require.NoError(t, err)
+			objects, err := parser.ParseManifest(bytes.NewReader(src))
+			require.NoError(t, err)
+
+			content, err := os.ReadFile(filepath.Join("fixtures", "input", "endpoints.yaml"))
+			require.NoError(t, err)
+			endpoints, err := ReadEndpoints(content)
+			require.NoError(t, err)
+
+			r := report.New()
+			c, err := converter.New(r)
+			require.NoError(t, err)
+			require.NoError(t, c.SetTarget(converter.TargetFileProvider))
+			converted, err := c.Do(objects)
+			require.NoError(t, err)
+
+			config, err := NewExporter(endpoints, r).Export(converted)
+			require.NoError(t, err)
+			exported, err := Encode(config, test.format)
+			require.NoError(t, err)
+
+			fixtureFile := filepath.Join("fixtures", "output", test.file)
+			if *updateExpected {
+				require.NoError(t, os.WriteFile(fixtureFile, exported, 0o666))
+			}
+
+			expected, err := os.ReadFile(fixtureFile)
+			require.NoError(t, err)
+			assert.Equal(t, string(expected), string(exported))
+
+			var messages []string
+			for _, e := range r.Entries() {
+				messages = append(messages, e.Source+": "+e.Message)
+			}
+			assert.Equal(t, []string{
+				"IngressRoute apps/shop: certificate of secret apps/shop-tls must be added to tls.certificates",
+				"IngressRoute apps/shop: tls option apps-modern must be defined in tls.options",
+				"IngressRoute apps/shop: service apps/web-canary:80 has no endpoints, http://web-canary.apps.svc:80 is used as its server",
+				"Middleware security/basic: users of secret security/users must be set in basicAuth.users",
+				"Middleware apps/errors: service apps/error-pages:80 has no endpoints, http://error-pages.apps.svc:80 is used as its server",
+				"TraefikService apps/status-mirror: service apps/status:443 has no endpoints, https://status.apps.svc:443 is used as its server",
+				"TraefikService apps/status-mirror: service apps/status-shadow:443 has no endpoints, https://status-shadow.apps.svc:443 is used as its server",
+				"TLSOption apps/modern: kind TLSOption is not exported to the file provider",
+			}, messages)
+		})
+	}
+}
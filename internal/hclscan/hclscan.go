@@ -0,0 +1,150 @@
+// Package hclscan scans the lexical elements of the HCL native syntax which
+// hide the structure of a document: strings, templates, heredocs and
+// comments. Positions are byte offsets in the source document.
+package hclscan
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Heredoc is a heredoc template, spanning [Start, End). Its content spans
+// [BodyStart, BodyEnd), End is the end of the closing marker.
+type Heredoc struct {
+	Start     int
+	End       int
+	BodyStart int
+	BodyEnd   int
+	// Indented heredocs are opened with <<- and have their content
+	// unindented.
+	Indented bool
+	// Templated heredocs contain interpolations or directives.
+	Templated bool
+}
+
+// Line returns the line number of offset.
+func Line(src []byte, offset int) int {
+	return bytes.Count(src[:offset], []byte("\n")) + 1
+}
+
+func errorf(src []byte, offset int, format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", Line(src, offset), fmt.Sprintf(format, args...))
+}
+
+// String returns the end of the double quoted string starting at start, and
+// whether it contains interpolations or directives. Those are only looked for
+// if templates is set, as JSON strings have none.
+func String(src []byte, start int, templates bool) (int, bool, error) {
+	templated := false
+	for i := start + 1; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			i++
+		case '$', '%':
+			if !templates || !isTemplate(src, i) {
+				continue
+			}
+			end, err := Template(src, i+1)
+			if err != nil {
+				return 0, false, err
+			}
+			templated = true
+			i = end
+		case '"':
+			return i + 1, templated, nil
+		case '\n':
+			return 0, false, errorf(src, start, "unterminated string")
+		}
+	}
+	return 0, false, errorf(src, start, "unterminated string")
+}
+
+// isTemplate reports whether the $ or % at offset opens a template sequence
+// rather than being part of an escaped $${ or %%{ sequence.
+func isTemplate(src []byte, offset int) bool {
+	if offset+1 >= len(src) || src[offset+1] != '{' {
+		return false
+	}
+	return offset == 0 || src[offset-1] != src[offset]
+}
+
+// Template returns the offset of the brace closing the template sequence
+// opened at start.
+func Template(src []byte, start int) (int, error) {
+	depth := 0
+	for i := start; i < len(src); i++ {
+		switch src[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i, nil
+			}
+		case '"':
+			end, _, err := String(src, i, true)
+			if err != nil {
+				return 0, err
+			}
+			i = end - 1
+		}
+	}
+	return 0, errorf(src, start, "unterminated template")
+}
+
+// ParseHeredoc parses the heredoc starting at start.
+func ParseHeredoc(src []byte, start int) (Heredoc, error) {
+	h := Heredoc{Start: start}
+
+	i := start + 2
+	if i < len(src) && src[i] == '-' {
+		h.Indented = true
+		i++
+	}
+	eol := bytes.IndexByte(src[i:], '\n')
+	if eol < 0 {
+		return Heredoc{}, errorf(src, start, "unterminated heredoc")
+	}
+	marker := strings.TrimSpace(string(src[i : i+eol]))
+	if marker == "" || strings.ContainsAny(marker, " \t\"") {
+		return Heredoc{}, errorf(src, start, "invalid heredoc marker %q", marker)
+	}
+
+	h.BodyStart = i + eol + 1
+	for offset := h.BodyStart; offset < len(src); {
+		end := bytes.IndexByte(src[offset:], '\n')
+		if end < 0 {
+			end = len(src) - offset
+		}
+		if strings.TrimSpace(string(src[offset:offset+end])) == marker {
+			h.BodyEnd = offset
+			h.End = offset + end
+			for j := h.BodyStart; j < h.BodyEnd && !h.Templated; j++ {
+				h.Templated = (src[j] == '$' || src[j] == '%') && isTemplate(src, j)
+			}
+			return h, nil
+		}
+		offset += end + 1
+	}
+	return Heredoc{}, errorf(src, start, "unterminated heredoc %s", marker)
+}
+
+// Comment returns the end of the comment starting at start, if there is
+// one. The newline ending a line comment is not part of it.
+func Comment(src []byte, start int) (int, bool, error) {
+	switch {
+	case src[start] == '#' || bytes.HasPrefix(src[start:], []byte("//")):
+		if end := bytes.IndexByte(src[start:], '\n'); end >= 0 {
+			return start + end, true, nil
+		}
+		return len(src), true, nil
+	case bytes.HasPrefix(src[start:], []byte("/*")):
+		end := bytes.Index(src[start+2:], []byte("*/"))
+		if end < 0 {
+			return 0, false, errorf(src, start, "unterminated comment")
+		}
+		return start + end + 4, true, nil
+	}
+	return start, false, nil
+}
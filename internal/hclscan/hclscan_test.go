@@ -0,0 +1,74 @@
+package hclscan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestString(t *testing.T) {
+	testCases := []struct {
+		desc      string
+		src       string
+		templates bool
+		end       int
+		templated bool
+	}{
+		{desc: "plain", src: `"traefik.enable=true" x`, templates: true, end: 21},
+		{desc: "escaped quote", src: `"a\"b"`, templates: true, end: 6},
+		{desc: "interpolation", src: `"Host(${var.host})"`, templates: true, end: 19, templated: true},
+		{desc: "directive", src: `"%{ if x }a%{ endif }"`, templates: true, end: 22, templated: true},
+		{desc: "nested string", src: `"${lookup(m, "}")}"`, templates: true, end: 19, templated: true},
+		{desc: "escaped interpolation", src: `"$${host}"`, templates: true, end: 10},
+		{desc: "json", src: `"${host}"`, end: 9},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			end, templated, err := String([]byte(test.src), 0, test.templates)
+			require.NoError(t, err)
+			assert.Equal(t, test.end, end)
+			assert.Equal(t, test.templated, templated)
+		})
+	}
+
+	_, _, err := String([]byte("\"a\nb\""), 0, true)
+	assert.EqualError(t, err, "line 1: unterminated string")
+}
+
+func TestParseHeredoc(t *testing.T) {
+	src := "x = <<-EOT\n  a: $${b}\n  c: ${d}\n  EOT\n"
+	h, err := ParseHeredoc([]byte(src), 4)
+	require.NoError(t, err)
+	assert.True(t, h.Indented)
+	assert.True(t, h.Templated)
+	assert.Equal(t, "  a: $${b}\n  c: ${d}\n", src[h.BodyStart:h.BodyEnd])
+	assert.Equal(t, "x = <<-EOT\n  a: $${b}\n  c: ${d}\n  EOT", src[:h.End])
+
+	h, err = ParseHeredoc([]byte("<<EOT\na: $${b}\nEOT"), 0)
+	require.NoError(t, err)
+	assert.False(t, h.Templated)
+
+	_, err = ParseHeredoc([]byte("<<EOT\na: b\n"), 0)
+	assert.EqualError(t, err, "line 1: unterminated heredoc EOT")
+}
+
+func TestComment(t *testing.T) {
+	end, ok, err := Comment([]byte("# a\nb"), 0)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 3, end)
+
+	end, ok, err = Comment([]byte("/* a */b"), 0)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 7, end)
+
+	_, ok, err = Comment([]byte("b"), 0)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	_, _, err = Comment([]byte("/* a"), 0)
+	assert.EqualError(t, err, "line 1: unterminated comment")
+}
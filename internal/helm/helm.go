@@ -0,0 +1,223 @@
+package helm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/databotic/traefik-migration-tool/internal/converter"
+	"github.com/databotic/traefik-migration-tool/internal/labels"
+	"github.com/databotic/traefik-migration-tool/internal/report"
+	"github.com/databotic/traefik-migration-tool/internal/utils"
+	containous "github.com/traefik/traefik/v2/pkg/provider/kubernetes/crd/traefikcontainous/v1alpha1"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	containousGroup = "traefik.containo.us/"
+	traefikGroup    = "traefik.io/"
+)
+
+var (
+	placeholderPattern = regexp.MustCompile(`helmtpl[0-9]+x`)
+	regexpMatcher      = regexp.MustCompile(`(\w+Regexp)\((` + "`[^`]*`(?:\\s*,\\s*`[^`]*`)*" + `)\)`)
+)
+
+// Converter rewrites the Traefik resources of Helm chart templates. Template
+// actions are masked so the templates can be decoded as YAML, the resources
+// are edited in place and the actions are restored as they were written.
+type Converter struct {
+	rules    labels.RuleConverter
+	tcpRules labels.RuleConverter
+	report   *report.Report
+}
+
+func New(r *report.Report) (*Converter, error) {
+	rules, err := converter.NewIngressRoute()
+	if err != nil {
+		return nil, err
+	}
+	tcpRules, err := converter.NewTCPRule()
+	if err != nil {
+		return nil, err
+	}
+	return &Converter{rules: rules, tcpRules: tcpRules, report: r}, nil
+}
+
+// Detect reports whether content is a template of Traefik resources.
+func Detect(content []byte) bool {
+	return bytes.Contains(content, []byte("{{")) && bytes.Contains(content, []byte(containousGroup))
+}
+
+func (c *Converter) Convert(src []byte) ([]byte, error) {
+	m := &masker{}
+	masked, err := m.mask(src)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(masked))
+
+	var edits []utils.TextEdit
+	for {
+		var doc yaml.Node
+		if err := decoder.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("error parsing template: %v", err)
+		}
+		if len(doc.Content) == 0 {
+			continue
+		}
+
+		e, err := c.convertDocument(masked, m, doc.Content[0])
+		if err != nil {
+			return nil, err
+		}
+		edits = append(edits, e...)
+	}
+
+	converted, err := utils.ApplyEdits(masked, edits)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(m.restore(string(converted))), nil
+}
+
+func (c *Converter) convertDocument(src []byte, m *masker, root *yaml.Node) ([]utils.TextEdit, error) {
+	apiVersion := utils.YAMLMappingValue(root, "apiVersion")
+	kind := utils.YAMLMappingValue(root, "kind")
+	if apiVersion == nil || kind == nil || apiVersion.Kind != yaml.ScalarNode {
+		return nil, nil
+	}
+
+	source := kind.Value
+	if name := utils.YAMLMappingValue(utils.YAMLMappingValue(root, "metadata"), "name"); name != nil {
+		source += " " + m.restore(name.Value)
+	}
+
+	if !strings.HasPrefix(apiVersion.Value, containousGroup) {
+		if strings.Contains(apiVersion.Value, containousGroup) {
+			c.report.Add(source, "apiVersion is templated and was not converted")
+		}
+		return nil, nil
+	}
+
+	edit, err := utils.YAMLScalarEdit(src, apiVersion, traefikGroup+strings.TrimPrefix(apiVersion.Value, containousGroup))
+	if err != nil {
+		return nil, err
+	}
+	edits := []utils.TextEdit{edit}
+
+	spec := utils.YAMLMappingValue(root, "spec")
+
+	var e []utils.TextEdit
+	switch kind.Value {
+	case "IngressRoute":
+		e, err = c.convertRoutes(src, m, source, spec, c.rules)
+	case "IngressRouteTCP":
+		e, err = c.convertRoutes(src, m, source, spec, c.tcpRules)
+	case "Middleware":
+		c.reportDeprecated(source, spec)
+		e, err = renameKey(src, spec, "ipWhiteList", "ipAllowList")
+	case "MiddlewareTCP":
+		e, err = renameKey(src, spec, "ipWhiteList", "ipAllowList")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return append(edits, e...), nil
+}
+
+func (c *Converter) convertRoutes(src []byte, m *masker, source string, spec *yaml.Node, rules labels.RuleConverter) ([]utils.TextEdit, error) {
+	routes := utils.ResolveYAMLAlias(utils.YAMLMappingValue(spec, "routes"))
+	if routes == nil || routes.Kind != yaml.SequenceNode {
+		return nil, nil
+	}
+
+	var edits []utils.TextEdit
+	for _, route := range routes.Content {
+		match := utils.YAMLMappingValue(route, "match")
+		if match == nil || match.Kind != yaml.ScalarNode {
+			continue
+		}
+
+		rule, ok := c.convertRule(m, source, match.Value, rules)
+		if !ok || rule == match.Value {
+			continue
+		}
+
+		edit, err := utils.YAMLScalarEdit(src, match, rule)
+		if err != nil {
+			return nil, err
+		}
+		edits = append(edits, edit)
+	}
+
+	return edits, nil
+}
+
+// convertRule converts a rule, reporting the templated values which
+// prevent a safe conversion.
+func (c *Converter) convertRule(m *masker, source, rule string, rules labels.RuleConverter) (string, bool) {
+	converted, err := rules.ConvertRule(rule)
+	if err != nil {
+		if placeholderPattern.MatchString(rule) {
+			c.report.Add(source, "rule %s is templated and was not converted", m.restore(rule))
+		} else {
+			c.report.Add(source, "invalid rule %s: %v", m.restore(rule), err)
+		}
+		return "", false
+	}
+
+	for _, placeholder := range placeholderPattern.FindAllString(rule, -1) {
+		if strings.Count(converted, placeholder) != strings.Count(rule, placeholder) {
+			c.report.Add(source, "template value %s of rule %s can't be converted safely",
+				m.restore(placeholder), m.restore(rule))
+			return "", false
+		}
+	}
+
+	for _, matcher := range regexpMatcher.FindAllStringSubmatch(converted, -1) {
+		for _, placeholder := range placeholderPattern.FindAllString(matcher[2], -1) {
+			c.report.Add(source, "template value %s is used in the regular expression of %s, "+
+				"rule %s was not converted", m.restore(placeholder), matcher[1], m.restore(rule))
+			return "", false
+		}
+	}
+
+	return converted, true
+}
+
+func (c *Converter) reportDeprecated(source string, spec *yaml.Node) {
+	var middleware containous.MiddlewareSpec
+	if spec == nil || spec.Decode(&middleware) != nil {
+		return
+	}
+	for _, opt := range utils.DepricatedOptions(&middleware) {
+		c.report.Add(source, "option %s was removed in v3 and should be fixed manually", opt)
+	}
+}
+
+func renameKey(src []byte, node *yaml.Node, from, to string) ([]utils.TextEdit, error) {
+	node = utils.ResolveYAMLAlias(node)
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value != from {
+			continue
+		}
+		edit, err := utils.YAMLScalarEdit(src, node.Content[i], to)
+		if err != nil {
+			return nil, err
+		}
+		return []utils.TextEdit{edit}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,62 @@
+package helm
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/databotic/traefik-migration-tool/internal/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var updateExpected = flag.Bool("update_expected", false, "Update expected files in testdata")
+
+func TestConvert(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		filename string
+		report   []string
+	}{
+		{
+			desc:     "templated ingressroute and middlewares",
+			filename: "ingressroute.yaml",
+			report: []string{
+				"IngressRoute {{ include \"app.fullname\" . }}: template value {{ .Values.domain }} is used in the regular expression of HostRegexp, " +
+					"rule HostRegexp(`{sub:[a-z]+}.{{ .Values.domain }}`) was not converted",
+				"IngressRoute {{ include \"app.fullname\" . }}: rule {{ .Values.ingressRoute.rule | quote }} is templated and was not converted",
+				"Middleware {{ include \"app.fullname\" . }}-headers: option Headers.SSLRedirect was removed in v3 and should be fixed manually",
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			src, err := os.ReadFile(filepath.Join("fixtures", "input", test.filename))
+			require.NoError(t, err)
+
+			r := report.New()
+			c, err := New(r)
+			require.NoError(t, err)
+
+			converted, err := c.Convert(src)
+			require.NoError(t, err)
+
+			fixtureFile := filepath.Join("fixtures", "output", test.filename)
+			if *updateExpected {
+				require.NoError(t, os.WriteFile(fixtureFile, converted, 0o666))
+			}
+
+			expected, err := os.ReadFile(fixtureFile)
+			require.NoError(t, err)
+			assert.Equal(t, string(expected), string(converted))
+
+			var entries []string
+			for _, entry := range r.Entries() {
+				entries = append(entries, entry.Source+": "+entry.Message)
+			}
+			assert.Equal(t, test.report, entries)
+		})
+	}
+}
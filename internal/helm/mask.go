@@ -0,0 +1,138 @@
+package helm
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// action is a template action of the source, spanning [start, end).
+type action struct {
+	start int
+	end   int
+}
+
+// masker replaces the template actions with placeholders which keep the
+// document valid YAML. Actions alone on their line, such as control
+// structures and includes, become comments, the other ones become plain
+// scalars.
+type masker struct {
+	raw          []string
+	placeholders []string
+}
+
+func (m *masker) mask(src []byte) ([]byte, error) {
+	actions, err := scanActions(src)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	last := 0
+	for i := 0; i < len(actions); {
+		// actions only separated by blanks are masked together when they
+		// are alone on their line.
+		j := i + 1
+		for j < len(actions) && isBlank(src[actions[j-1].end:actions[j].start]) {
+			j++
+		}
+		start, end := actions[i].start, actions[j-1].end
+
+		lineStart := bytes.LastIndexByte(src[:start], '\n') + 1
+		lineEnd := bytes.IndexByte(src[end:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(src)
+		} else {
+			lineEnd += end
+		}
+
+		if isBlank(src[lineStart:start]) && isBlank(src[end:lineEnd]) {
+			out.Write(src[last:start])
+			out.WriteString(m.add(string(src[start:end]), "#helmtpl%dx"))
+			last = end
+			i = j
+			continue
+		}
+
+		for _, a := range actions[i:j] {
+			out.Write(src[last:a.start])
+			out.WriteString(m.add(string(src[a.start:a.end]), "helmtpl%dx"))
+			last = a.end
+		}
+		i = j
+	}
+	out.Write(src[last:])
+
+	return out.Bytes(), nil
+}
+
+func (m *masker) add(raw, format string) string {
+	placeholder := fmt.Sprintf(format, len(m.raw))
+	m.raw = append(m.raw, raw)
+	m.placeholders = append(m.placeholders, placeholder)
+	return placeholder
+}
+
+// restore replaces the placeholders of s by the actions they mask.
+func (m *masker) restore(s string) string {
+	for i := len(m.raw) - 1; i >= 0; i-- {
+		s = strings.ReplaceAll(s, m.placeholders[i], m.raw[i])
+	}
+	return s
+}
+
+func isBlank(b []byte) bool {
+	return len(bytes.Trim(b, " \t\r")) == 0
+}
+
+// scanActions returns the template actions of src, skipping the strings
+// and comments they contain.
+func scanActions(src []byte) ([]action, error) {
+	var actions []action
+
+	for i := 0; i < len(src); i++ {
+		if !bytes.HasPrefix(src[i:], []byte("{{")) {
+			continue
+		}
+
+		start := i
+		end := -1
+		for j := i + 2; j < len(src) && end < 0; j++ {
+			switch {
+			case bytes.HasPrefix(src[j:], []byte("/*")):
+				k := bytes.Index(src[j+2:], []byte("*/"))
+				if k < 0 {
+					return nil, fmt.Errorf("unterminated comment in template action at line %d", line(src, start))
+				}
+				j += k + 3
+			case src[j] == '"' || src[j] == '\'':
+				quote := src[j]
+				for j++; j < len(src) && src[j] != quote; j++ {
+					if src[j] == '\\' {
+						j++
+					}
+				}
+			case src[j] == '`':
+				k := bytes.IndexByte(src[j+1:], '`')
+				if k < 0 {
+					return nil, fmt.Errorf("unterminated raw string in template action at line %d", line(src, start))
+				}
+				j += k + 1
+			case bytes.HasPrefix(src[j:], []byte("}}")):
+				end = j + 2
+			}
+		}
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated template action at line %d", line(src, start))
+		}
+
+		actions = append(actions, action{start: start, end: end})
+		i = end - 1
+	}
+
+	return actions, nil
+}
+
+func line(src []byte, offset int) int {
+	return bytes.Count(src[:offset], []byte("\n")) + 1
+}
@@ -0,0 +1,496 @@
+package kustomize
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/databotic/traefik-migration-tool/internal/converter"
+	"github.com/databotic/traefik-migration-tool/internal/labels"
+	"github.com/databotic/traefik-migration-tool/internal/report"
+	"github.com/databotic/traefik-migration-tool/internal/utils"
+	containous "github.com/traefik/traefik/v2/pkg/provider/kubernetes/crd/traefikcontainous/v1alpha1"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	containousGroup = "traefik.containo.us"
+	traefikGroup    = "traefik.io"
+
+	ipWhiteListPath = "/spec/ipWhiteList"
+	ipAllowListPath = "/spec/ipAllowList"
+)
+
+// kustomizationFiles are the file names recognized as a kustomization.
+var kustomizationFiles = []string{"kustomization.yaml", "kustomization.yml", "Kustomization"}
+
+var (
+	rulePath      = regexp.MustCompile(`^/spec/routes/[^/]+/match$`)
+	remotePattern = regexp.MustCompile(`^(https?://|git@|ssh://|github\.com/|gitlab\.com/|bitbucket\.org/)|\?ref=`)
+)
+
+// File is a file of the kustomization tree, with its converted content.
+type File struct {
+	Path    string
+	Content []byte
+	Changed bool
+}
+
+// Converter converts the Traefik resources of a kustomization tree, from an
+// overlay down to its bases, together with the patches applied to them.
+// Files are edited in place, keeping comments and formatting.
+type Converter struct {
+	rules    labels.RuleConverter
+	tcpRules labels.RuleConverter
+	report   *report.Report
+
+	visited   map[string]bool
+	files     map[string]File
+	resources map[string]bool
+}
+
+func New(r *report.Report) (*Converter, error) {
+	rules, err := converter.NewIngressRoute()
+	if err != nil {
+		return nil, err
+	}
+	tcpRules, err := converter.NewTCPRule()
+	if err != nil {
+		return nil, err
+	}
+	return &Converter{rules: rules, tcpRules: tcpRules, report: r}, nil
+}
+
+// Convert walks the kustomization in dir and returns the files of the tree,
+// sorted by path.
+func (c *Converter) Convert(dir string) ([]File, error) {
+	c.visited = map[string]bool{}
+	c.files = map[string]File{}
+	c.resources = map[string]bool{}
+
+	if err := c.convertKustomization(dir); err != nil {
+		return nil, err
+	}
+
+	files := make([]File, 0, len(c.files))
+	for _, file := range c.files {
+		files = append(files, file)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+
+	return files, nil
+}
+
+func (c *Converter) convertKustomization(dir string) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	if c.visited[dir] {
+		return nil
+	}
+	c.visited[dir] = true
+
+	path := findKustomization(dir)
+	if path == "" {
+		return fmt.Errorf("no kustomization file found in %s", dir)
+	}
+
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(src, &doc); err != nil {
+		return fmt.Errorf("error parsing %s: %v", path, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	root := doc.Content[0]
+
+	for _, field := range []string{"resources", "bases", "components"} {
+		for _, item := range sequenceItems(utils.YAMLMappingValue(root, field)) {
+			if err := c.convertResource(path, dir, item.Value); err != nil {
+				return err
+			}
+		}
+	}
+
+	var edits []utils.TextEdit
+	for _, item := range sequenceItems(utils.YAMLMappingValue(root, "patchesStrategicMerge")) {
+		inline := strings.Contains(item.Value, "\n")
+		e, err := c.convertPatch(src, path, dir, item, nil, inline)
+		if err != nil {
+			return err
+		}
+		edits = append(edits, e...)
+	}
+
+	for _, field := range []string{"patchesJson6902", "patches"} {
+		for _, item := range sequenceItems(utils.YAMLMappingValue(root, field)) {
+			target := utils.YAMLMappingValue(item, "target")
+			edits = append(edits, c.convertTarget(src, path, target)...)
+
+			patch, inline := utils.YAMLMappingValue(item, "patch"), true
+			if patch == nil {
+				patch, inline = utils.YAMLMappingValue(item, "path"), false
+			}
+			if patch == nil {
+				continue
+			}
+			e, err := c.convertPatch(src, path, dir, patch, target, inline)
+			if err != nil {
+				return err
+			}
+			edits = append(edits, e...)
+		}
+	}
+
+	return c.save(path, src, edits)
+}
+
+func findKustomization(dir string) string {
+	for _, name := range kustomizationFiles {
+		path := filepath.Join(dir, name)
+		if stat, err := os.Stat(path); err == nil && !stat.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
+
+// convertResource converts a resource of a kustomization, either a file of
+// manifests or another kustomization.
+func (c *Converter) convertResource(kustomization, dir, resource string) error {
+	if remotePattern.MatchString(resource) {
+		c.report.Add(kustomization, "remote resource %s was not converted and must be migrated separately", resource)
+		return nil
+	}
+
+	path := filepath.Join(dir, resource)
+	stat, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if stat.IsDir() {
+		return c.convertKustomization(path)
+	}
+
+	if c.visited[path] {
+		return nil
+	}
+	c.visited[path] = true
+
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	edits, err := c.convertDocuments(src, path, true)
+	if err != nil {
+		return err
+	}
+	return c.save(path, src, edits)
+}
+
+// convertPatch converts a patch, either inline in the kustomization or in
+// a file relative to it.
+func (c *Converter) convertPatch(src []byte, kustomization, dir string, patch, target *yaml.Node, inline bool) ([]utils.TextEdit, error) {
+	if patch.Kind != yaml.ScalarNode {
+		return nil, nil
+	}
+
+	if !inline {
+		path := filepath.Join(dir, patch.Value)
+		if c.visited[path] {
+			return nil, nil
+		}
+		c.visited[path] = true
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		edits, err := c.convertPatchContent(content, path, target)
+		if err != nil {
+			return nil, err
+		}
+		return nil, c.save(path, content, edits)
+	}
+
+	content := []byte(patch.Value)
+	edits, err := c.convertPatchContent(content, kustomization, target)
+	if err != nil || len(edits) == 0 {
+		return nil, err
+	}
+	converted, err := utils.ApplyEdits(content, edits)
+	if err != nil {
+		return nil, err
+	}
+
+	edit, err := utils.YAMLScalarEdit(src, patch, string(converted))
+	if err != nil {
+		return nil, err
+	}
+	return []utils.TextEdit{edit}, nil
+}
+
+// convertPatchContent converts a JSON6902 patch, a list of operations, or a
+// strategic merge patch.
+func (c *Converter) convertPatchContent(content []byte, source string, target *yaml.Node) ([]utils.TextEdit, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, fmt.Errorf("error parsing patch in %s: %v", source, err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.SequenceNode {
+		return c.convertDocuments(content, source, false)
+	}
+
+	if group := utils.YAMLMappingValue(target, "group"); group == nil || group.Value != containousGroup {
+		return nil, nil
+	}
+	kind, name := scalarValue(utils.YAMLMappingValue(target, "kind")), scalarValue(utils.YAMLMappingValue(target, "name"))
+	if kind != "" {
+		source = kind + " " + name
+	}
+
+	var edits []utils.TextEdit
+	for _, op := range sequenceItems(doc.Content[0]) {
+		path := utils.YAMLMappingValue(op, "path")
+		if path == nil || path.Kind != yaml.ScalarNode {
+			continue
+		}
+
+		for _, node := range []*yaml.Node{path, utils.YAMLMappingValue(op, "from")} {
+			if node == nil || node.Kind != yaml.ScalarNode {
+				continue
+			}
+			if renamed := renamePath(node.Value, kind); renamed != node.Value {
+				edit, err := utils.YAMLScalarEdit(content, node, renamed)
+				if err != nil {
+					return nil, err
+				}
+				edits = append(edits, edit)
+			}
+		}
+
+		if value := utils.YAMLMappingValue(op, "value"); value != nil {
+			e, err := c.walk(content, value, path.Value, kind, source)
+			if err != nil {
+				return nil, err
+			}
+			edits = append(edits, e...)
+		}
+	}
+
+	return edits, nil
+}
+
+// convertTarget rewrites the group of a patch target selector and checks
+// the patched resource is converted as well.
+func (c *Converter) convertTarget(src []byte, kustomization string, target *yaml.Node) []utils.TextEdit {
+	group := utils.YAMLMappingValue(target, "group")
+	if group == nil || group.Kind != yaml.ScalarNode || group.Value != containousGroup {
+		return nil
+	}
+
+	kind, name := scalarValue(utils.YAMLMappingValue(target, "kind")), scalarValue(utils.YAMLMappingValue(target, "name"))
+	if kind != "" && name != "" && !c.resources[kind+"/"+name] {
+		c.report.Add(kustomization, "patch target %s %s is not defined in a local base, "+
+			"its base must be migrated to %s as well", kind, name, traefikGroup)
+	}
+
+	edit, err := utils.YAMLScalarEdit(src, group, traefikGroup)
+	if err != nil {
+		return nil
+	}
+	return []utils.TextEdit{edit}
+}
+
+// convertDocuments converts the Traefik objects of a YAML stream, either
+// resources or strategic merge patches.
+func (c *Converter) convertDocuments(src []byte, source string, resources bool) ([]utils.TextEdit, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(src))
+
+	var edits []utils.TextEdit
+	for {
+		var doc yaml.Node
+		if err := decoder.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("error parsing %s: %v", source, err)
+		}
+		if len(doc.Content) == 0 {
+			continue
+		}
+		root := doc.Content[0]
+
+		apiVersion := scalarValue(utils.YAMLMappingValue(root, "apiVersion"))
+		if !strings.HasPrefix(apiVersion, containousGroup+"/") {
+			continue
+		}
+
+		kind := scalarValue(utils.YAMLMappingValue(root, "kind"))
+		name := scalarValue(utils.YAMLMappingValue(utils.YAMLMappingValue(root, "metadata"), "name"))
+		if resources {
+			c.resources[kind+"/"+name] = true
+		} else if !c.resources[kind+"/"+name] {
+			c.report.Add(source, "patched %s %s is not defined in a local base, "+
+				"its base must be migrated to %s as well", kind, name, traefikGroup)
+		}
+
+		if kind == "Middleware" {
+			c.reportDeprecated(kind+" "+name, utils.YAMLMappingValue(root, "spec"))
+		}
+
+		e, err := c.walk(src, root, "", kind, kind+" "+name)
+		if err != nil {
+			return nil, err
+		}
+		edits = append(edits, e...)
+	}
+
+	return edits, nil
+}
+
+// walk converts the fields of the node found at the JSON pointer path of an
+// object of the given kind.
+func (c *Converter) walk(src []byte, node *yaml.Node, path, kind, source string) ([]utils.TextEdit, error) {
+	node = utils.ResolveYAMLAlias(node)
+	if node == nil {
+		return nil, nil
+	}
+
+	switch node.Kind {
+	case yaml.ScalarNode:
+		value := node.Value
+		switch {
+		case path == "/apiVersion" && strings.HasPrefix(value, containousGroup+"/"):
+			value = traefikGroup + strings.TrimPrefix(value, containousGroup)
+		case rulePath.MatchString(path):
+			rules := c.rules
+			if kind == "IngressRouteTCP" {
+				rules = c.tcpRules
+			}
+			rule, err := rules.ConvertRule(value)
+			if err != nil {
+				c.report.Add(source, "invalid rule %s: %v", value, err)
+				return nil, nil
+			}
+			value = rule
+		}
+		if value == node.Value {
+			return nil, nil
+		}
+		edit, err := utils.YAMLScalarEdit(src, node, value)
+		if err != nil {
+			return nil, err
+		}
+		return []utils.TextEdit{edit}, nil
+
+	case yaml.MappingNode:
+		var edits []utils.TextEdit
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			childPath := path + "/" + escapePointer(key.Value)
+			if name := lastSegment(renamePath(childPath, kind)); name != escapePointer(key.Value) {
+				edit, err := utils.YAMLScalarEdit(src, key, name)
+				if err != nil {
+					return nil, err
+				}
+				edits = append(edits, edit)
+			}
+
+			e, err := c.walk(src, node.Content[i+1], childPath, kind, source)
+			if err != nil {
+				return nil, err
+			}
+			edits = append(edits, e...)
+		}
+		return edits, nil
+
+	case yaml.SequenceNode:
+		var edits []utils.TextEdit
+		for i, item := range node.Content {
+			e, err := c.walk(src, item, path+"/"+strconv.Itoa(i), kind, source)
+			if err != nil {
+				return nil, err
+			}
+			edits = append(edits, e...)
+		}
+		return edits, nil
+	}
+
+	return nil, nil
+}
+
+func (c *Converter) reportDeprecated(source string, spec *yaml.Node) {
+	var middleware containous.MiddlewareSpec
+	if spec == nil || spec.Decode(&middleware) != nil {
+		return
+	}
+	for _, opt := range utils.DepricatedOptions(&middleware) {
+		c.report.Add(source, "option %s was removed in v3 and should be fixed manually", opt)
+	}
+}
+
+func (c *Converter) save(path string, src []byte, edits []utils.TextEdit) error {
+	if len(edits) == 0 {
+		c.files[path] = File{Path: path, Content: src}
+		return nil
+	}
+	converted, err := utils.ApplyEdits(src, edits)
+	if err != nil {
+		return fmt.Errorf("error converting %s: %v", path, err)
+	}
+	c.files[path] = File{Path: path, Content: converted, Changed: true}
+	return nil
+}
+
+// renamePath rewrites a JSON pointer to a field renamed in v3. Patches with
+// no kind in their target may apply to middlewares.
+func renamePath(path, kind string) string {
+	switch kind {
+	case "", "Middleware", "MiddlewareTCP":
+	default:
+		return path
+	}
+	if path == ipWhiteListPath || strings.HasPrefix(path, ipWhiteListPath+"/") {
+		return ipAllowListPath + strings.TrimPrefix(path, ipWhiteListPath)
+	}
+	return path
+}
+
+func lastSegment(path string) string {
+	return path[strings.LastIndexByte(path, '/')+1:]
+}
+
+func escapePointer(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
+
+func scalarValue(node *yaml.Node) string {
+	node = utils.ResolveYAMLAlias(node)
+	if node == nil || node.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return node.Value
+}
+
+func sequenceItems(node *yaml.Node) []*yaml.Node {
+	node = utils.ResolveYAMLAlias(node)
+	if node == nil || node.Kind != yaml.SequenceNode {
+		return nil
+	}
+	return node.Content
+}
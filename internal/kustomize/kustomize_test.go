@@ -0,0 +1,64 @@
+package kustomize
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/databotic/traefik-migration-tool/internal/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var updateExpected = flag.Bool("update_expected", false, "Update expected files in testdata")
+
+func TestConvert(t *testing.T) {
+	input, err := filepath.Abs(filepath.Join("fixtures", "input"))
+	require.NoError(t, err)
+
+	r := report.New()
+	c, err := New(r)
+	require.NoError(t, err)
+
+	files, err := c.Convert(filepath.Join(input, "overlays", "prod"))
+	require.NoError(t, err)
+
+	var changed []string
+	for _, file := range files {
+		if !file.Changed {
+			continue
+		}
+		rel, err := filepath.Rel(input, file.Path)
+		require.NoError(t, err)
+		changed = append(changed, filepath.ToSlash(rel))
+
+		fixtureFile := filepath.Join("fixtures", "output", rel)
+		if *updateExpected {
+			require.NoError(t, os.MkdirAll(filepath.Dir(fixtureFile), 0o755))
+			require.NoError(t, os.WriteFile(fixtureFile, file.Content, 0o666))
+		}
+
+		expected, err := os.ReadFile(fixtureFile)
+		require.NoError(t, err)
+		assert.Equal(t, string(expected), string(file.Content), rel)
+	}
+
+	assert.Equal(t, []string{
+		"base/ingressroute.yaml",
+		"base/middlewares.yaml",
+		"overlays/prod/ingressroute-patch.yaml",
+		"overlays/prod/kustomization.yaml",
+		"overlays/prod/office-patch.yaml",
+	}, changed)
+
+	kustomization := filepath.Join(input, "overlays", "prod", "kustomization.yaml")
+	var entries []string
+	for _, entry := range r.Entries() {
+		entries = append(entries, entry.Source+": "+entry.Message)
+	}
+	assert.Equal(t, []string{
+		kustomization + ": remote resource https://github.com/example/traefik-extras//base?ref=v1.0.0 was not converted and must be migrated separately",
+		kustomization + ": patch target Middleware extras-auth is not defined in a local base, its base must be migrated to traefik.io as well",
+	}, entries)
+}
@@ -0,0 +1,132 @@
+package kv
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/databotic/traefik-migration-tool/internal/labels"
+	"github.com/databotic/traefik-migration-tool/internal/report"
+)
+
+const Separator = "/"
+
+// Entry is a single key of a KV store.
+type Entry struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// ChangeSet lists the keys to put and to delete for migrating a KV store.
+type ChangeSet struct {
+	Put    []Entry  `json:"put"`
+	Delete []string `json:"delete"`
+}
+
+// consulEntry is an entry of a `consul kv export` dump.
+type consulEntry struct {
+	Key   string `json:"key"`
+	Flags int    `json:"flags"`
+	Value string `json:"value"`
+}
+
+// Parse reads a KV export. Supported formats are a JSON object of keys to
+// values, a `consul kv export` JSON array and `key=value` lines.
+func Parse(content []byte) ([]Entry, error) {
+	trimmed := bytes.TrimSpace(content)
+
+	switch {
+	case len(trimmed) == 0:
+		return nil, nil
+	case trimmed[0] == '{':
+		var raw map[string]string
+		if err := json.Unmarshal(trimmed, &raw); err != nil {
+			return nil, fmt.Errorf("error parsing kv export: %v", err)
+		}
+		entries := make([]Entry, 0, len(raw))
+		for key, value := range raw {
+			entries = append(entries, Entry{Key: key, Value: value})
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+		return entries, nil
+	case trimmed[0] == '[':
+		var raw []consulEntry
+		if err := json.Unmarshal(trimmed, &raw); err != nil {
+			return nil, fmt.Errorf("error parsing kv export: %v", err)
+		}
+		entries := make([]Entry, 0, len(raw))
+		for _, e := range raw {
+			value, err := base64.StdEncoding.DecodeString(e.Value)
+			if err != nil {
+				return nil, fmt.Errorf("error decoding value of %s: %v", e.Key, err)
+			}
+			entries = append(entries, Entry{Key: e.Key, Value: string(value)})
+		}
+		return entries, nil
+	}
+
+	var entries []Entry
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		key, value, found := strings.Cut(text, "=")
+		if !found {
+			return nil, fmt.Errorf("line %d: expected key=value", line)
+		}
+		entries = append(entries, Entry{Key: strings.TrimSpace(key), Value: value})
+	}
+	return entries, scanner.Err()
+}
+
+// Converter computes the change set migrating the Traefik keys of a KV store
+// from v2 to v3.
+type Converter struct {
+	labels *labels.Rewriter
+	report *report.Report
+}
+
+// New returns a Converter using a copy of rewriter with the KV separator,
+// so the rewriter can still be shared with the label converters.
+func New(rewriter *labels.Rewriter, r *report.Report) *Converter {
+	kvRewriter := *rewriter
+	kvRewriter.Separator = Separator
+	return &Converter{labels: &kvRewriter, report: r}
+}
+
+func (c *Converter) Convert(entries []Entry) (*ChangeSet, error) {
+	existing := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		existing[e.Key] = true
+	}
+
+	changes := &ChangeSet{Put: []Entry{}, Delete: []string{}}
+	for _, e := range entries {
+		if msg := c.labels.Deprecated(e.Key); msg != "" {
+			c.report.Add(e.Key, "%s and should be fixed manually", msg)
+		}
+
+		key, value, err := c.labels.Rewrite(e.Key, e.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		if key != e.Key {
+			if existing[key] {
+				c.report.Add(e.Key, "replaces the existing key %s", key)
+			}
+			changes.Delete = append(changes.Delete, e.Key)
+		}
+		if key != e.Key || value != e.Value {
+			changes.Put = append(changes.Put, Entry{Key: key, Value: value})
+		}
+	}
+
+	return changes, nil
+}
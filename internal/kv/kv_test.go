@@ -0,0 +1,72 @@
+package kv
+
+import (
+	"testing"
+
+	"github.com/databotic/traefik-migration-tool/internal/converter"
+	"github.com/databotic/traefik-migration-tool/internal/labels"
+	"github.com/databotic/traefik-migration-tool/internal/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	expected := []Entry{
+		{Key: "traefik/http/routers/api/rule", Value: "Host(`api.example.com`)"},
+		{Key: "traefik/http/routers/api/service", Value: "api"},
+	}
+
+	testCases := map[string]string{
+		"json": `{"traefik/http/routers/api/rule": "Host(` + "`api.example.com`" + `)", "traefik/http/routers/api/service": "api"}`,
+		"consul": `[
+  {"key": "traefik/http/routers/api/rule", "flags": 0, "value": "SG9zdChgYXBpLmV4YW1wbGUuY29tYCk="},
+  {"key": "traefik/http/routers/api/service", "flags": 0, "value": "YXBp"}
+]`,
+		"lines": "# routers\ntraefik/http/routers/api/rule=Host(`api.example.com`)\n\ntraefik/http/routers/api/service=api\n",
+	}
+
+	for name, content := range testCases {
+		t.Run(name, func(t *testing.T) {
+			entries, err := Parse([]byte(content))
+			require.NoError(t, err)
+			assert.Equal(t, expected, entries)
+		})
+	}
+}
+
+func TestConvert(t *testing.T) {
+	ingressRoute, err := converter.NewIngressRoute()
+	require.NoError(t, err)
+	tcpRule, err := converter.NewTCPRule()
+	require.NoError(t, err)
+
+	rewriter := labels.New(ingressRoute)
+	rewriter.TCPRules = tcpRule
+
+	entries := []Entry{
+		{Key: "traefik/http/routers/api/rule", Value: "Host(`api.example.com`) && Path(`/{id}`)"},
+		{Key: "traefik/http/routers/api/service", Value: "api"},
+		{Key: "traefik/http/middlewares/office/ipWhiteList/sourceRange/0", Value: "10.0.0.0/8"},
+		{Key: "traefik/tcp/routers/db/rule", Value: "HostSNI(`a.example.com`, `b.example.com`)"},
+		{Key: "traefik/http/middlewares/secure/headers/sslRedirect", Value: "true"},
+	}
+
+	r := report.New()
+	changes, err := New(rewriter, r).Convert(entries)
+	require.NoError(t, err)
+
+	assert.Equal(t, &ChangeSet{
+		Put: []Entry{
+			{Key: "traefik/http/routers/api/rule", Value: "Host(`api.example.com`) && PathRegexp(`^/(?P<id>[^/]+)$`)"},
+			{Key: "traefik/http/middlewares/office/ipAllowList/sourceRange/0", Value: "10.0.0.0/8"},
+			{Key: "traefik/tcp/routers/db/rule", Value: "(HostSNI(`a.example.com`) || HostSNI(`b.example.com`))"},
+		},
+		Delete: []string{
+			"traefik/http/middlewares/office/ipWhiteList/sourceRange/0",
+		},
+	}, changes)
+	assert.Equal(t, 1, r.Len())
+
+	// the rewriter is shared with the label converters.
+	assert.Equal(t, labels.DefaultSeparator, rewriter.Separator)
+}
@@ -0,0 +1,106 @@
+package labels
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	DefaultPrefix    = "traefik"
+	DefaultSeparator = "."
+)
+
+// RuleConverter converts a v2 router rule to the v3 syntax.
+type RuleConverter interface {
+	ConvertRule(rule string) (string, error)
+}
+
+// Rewriter converts Traefik v2 label style keys and values, as used by the
+// Docker, Swarm, Consul Catalog, Nomad and ECS providers, to v3. With the "/"
+// separator it handles the keys of the KV providers as well.
+type Rewriter struct {
+	Prefix    string
+	Separator string
+	// TCPRules converts TCP router rules, they are left untouched if nil.
+	TCPRules RuleConverter
+
+	rules RuleConverter
+}
+
+func New(rules RuleConverter) *Rewriter {
+	return &Rewriter{Prefix: DefaultPrefix, Separator: DefaultSeparator, rules: rules}
+}
+
+// IsTraefikKey reports whether key belongs to the Traefik label namespace.
+func (r *Rewriter) IsTraefikKey(key string) bool {
+	return strings.HasPrefix(strings.ToLower(key), strings.ToLower(r.Prefix+r.Separator))
+}
+
+// Rewrite returns the v3 key and value for the given v2 label. Labels which
+// are not affected by the migration are returned unchanged.
+func (r *Rewriter) Rewrite(key, value string) (string, string, error) {
+	if !r.IsTraefikKey(key) {
+		return key, value, nil
+	}
+
+	root := len(r.Prefix + r.Separator)
+	parts := strings.Split(key[root:], r.Separator)
+	if len(parts) < 4 {
+		return key, value, nil
+	}
+
+	protocol, section := strings.ToLower(parts[0]), strings.ToLower(parts[1])
+	isRule := section == "routers" && len(parts) == 4 && strings.EqualFold(parts[3], "rule")
+	switch {
+	case protocol == "http" && isRule:
+		rule, err := r.rules.ConvertRule(value)
+		if err != nil {
+			return "", "", fmt.Errorf("label %s: %w", key, err)
+		}
+		value = rule
+	case protocol == "tcp" && isRule && r.TCPRules != nil:
+		rule, err := r.TCPRules.ConvertRule(value)
+		if err != nil {
+			return "", "", fmt.Errorf("label %s: %w", key, err)
+		}
+		value = rule
+	case (protocol == "http" || protocol == "tcp") && section == "middlewares":
+		parts[3] = RenameMiddleware(parts[3])
+	}
+
+	return key[:root] + strings.Join(parts, r.Separator), value, nil
+}
+
+// RenameMiddleware returns the v3 name of a middleware type, keeping the
+// letter case convention of the given name.
+func RenameMiddleware(name string) string {
+	if !strings.EqualFold(name, "ipwhitelist") {
+		return name
+	}
+	if name == strings.ToLower(name) {
+		return "ipallowlist"
+	}
+	return "ipAllowList"
+}
+
+// Deprecated returns a description of why the label is no longer supported
+// by v3, or an empty string.
+func (r *Rewriter) Deprecated(key string) string {
+	if !r.IsTraefikKey(key) {
+		return ""
+	}
+
+	parts := strings.Split(strings.ToLower(key[len(r.Prefix+r.Separator):]), r.Separator)
+	if len(parts) != 5 || parts[0] != "http" || parts[1] != "middlewares" {
+		return ""
+	}
+
+	switch parts[3] + "." + parts[4] {
+	case "headers.sslredirect", "headers.ssltemporaryredirect", "headers.sslhost",
+		"headers.sslforcehost", "headers.featurepolicy":
+		return fmt.Sprintf("option %s of the headers middleware was removed in v3", parts[4])
+	case "stripprefix.forceslash":
+		return "option forceslash of the stripprefix middleware was removed in v3"
+	}
+	return ""
+}
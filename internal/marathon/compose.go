@@ -0,0 +1,94 @@
+package marathon
+
+import (
+	"strings"
+
+	"github.com/databotic/traefik-migration-tool/internal/utils"
+	"github.com/traefik/traefik/v3/pkg/provider"
+)
+
+type composeService struct {
+	Image  string            `yaml:"image,omitempty"`
+	Labels map[string]string `yaml:"labels,omitempty"`
+}
+
+// Compose converts the applications of content to the services of a
+// docker-compose file, labelled for the v3 Docker provider. Services are
+// named after the applications, so the default rules match the same hosts.
+func (c *Converter) Compose(content []byte) ([]byte, error) {
+	apps, err := c.applications(content)
+	if err != nil {
+		return nil, err
+	}
+
+	services := map[string]composeService{}
+	for _, app := range apps {
+		source := "application " + app.ID
+		name := provider.Normalize(app.ID)
+
+		service := composeService{Labels: app.Labels}
+		if app.Container != nil && app.Container.Docker != nil {
+			service.Image = app.Container.Docker.Image
+		}
+		if service.Image == "" {
+			c.report.Add(source, "application has no Docker image, the image of service %s must be set", name)
+		}
+		c.serverPorts(app, name, service.Labels)
+
+		if _, ok := services[name]; ok {
+			c.report.Add(source, "service %s is generated for several applications, only the last one is kept", name)
+		}
+		services[name] = service
+	}
+
+	return utils.EncodeYaml(map[string]interface{}{"services": services})
+}
+
+// serverPorts sets the port of the services of an application which don't
+// select one. Marathon uses the first port of the application while Docker
+// uses the lowest exposed one.
+func (c *Converter) serverPorts(app *application, name string, labels map[string]string) {
+	first, err := app.resolvePort("")
+	if err != nil {
+		return
+	}
+
+	services := map[string]map[string]bool{}
+	routers, defined := map[string]bool{}, map[string]bool{}
+	for key := range labels {
+		parts := strings.Split(key[len(c.labels.Prefix+c.labels.Separator):], c.labels.Separator)
+		if len(parts) < 3 {
+			continue
+		}
+		protocol := strings.ToLower(parts[0])
+		switch strings.ToLower(parts[1]) {
+		case "routers":
+			routers[protocol] = true
+		case "services":
+			defined[protocol] = true
+			if len(parts) < 4 || !strings.EqualFold(parts[3], "loadbalancer") {
+				// weighted and mirroring services have no servers.
+				continue
+			}
+			if services[protocol] == nil {
+				services[protocol] = map[string]bool{}
+			}
+			services[protocol][parts[2]] = services[protocol][parts[2]] || isServerPort(key)
+		}
+	}
+	if len(routers) == 0 && len(defined) == 0 {
+		routers["http"] = true
+	}
+
+	for _, protocol := range []string{"http", "tcp", "udp"} {
+		if !defined[protocol] && routers[protocol] {
+			services[protocol] = map[string]bool{name: false}
+		}
+		for _, service := range sortedKeys(services[protocol]) {
+			if !services[protocol][service] {
+				key := strings.Join([]string{c.labels.Prefix, protocol, "services", service, "loadbalancer", "server", "port"}, c.labels.Separator)
+				labels[key] = first
+			}
+		}
+	}
+}
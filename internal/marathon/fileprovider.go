@@ -0,0 +1,270 @@
+package marathon
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+
+	"github.com/databotic/traefik-migration-tool/internal/labels"
+	"github.com/databotic/traefik-migration-tool/internal/report"
+	"github.com/traefik/traefik/v3/pkg/config/dynamic"
+	"github.com/traefik/traefik/v3/pkg/config/label"
+	"github.com/traefik/traefik/v3/pkg/provider"
+)
+
+// FileProvider converts the applications of content to a file provider
+// dynamic configuration. Servers can't be known from the definitions, they
+// point to the Mesos-DNS name of the applications.
+func (c *Converter) FileProvider(content []byte) (*dynamic.Configuration, error) {
+	apps, err := c.applications(content)
+	if err != nil {
+		return nil, err
+	}
+
+	defaultRuleTpl, err := provider.MakeDefaultRuleTemplate(defaultRule, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	m := newMerger(c.report)
+	for _, app := range apps {
+		source := "application " + app.ID
+
+		// label.DecodeConfiguration reads the traefik root only.
+		traefikLabels := map[string]string{}
+		for key, value := range app.Labels {
+			traefikLabels[labels.DefaultPrefix+key[len(c.labels.Prefix):]] = value
+		}
+		conf, err := label.DecodeConfiguration(traefikLabels)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", source, err)
+		}
+		if len(conf.TLS.Stores) > 0 {
+			c.report.Add(source, "the default TLS store labels are not exported, the store must be defined in the file provider configuration")
+		}
+
+		host := mesosDNSName(app.ID)
+		c.report.Add(source, "servers point to %s, the Mesos-DNS name of the application, and must be checked", host)
+
+		ctx := context.Background()
+		tcpOrUDP := false
+		if len(conf.TCP.Routers) > 0 || len(conf.TCP.Services) > 0 {
+			tcpOrUDP = true
+			if err := c.tcpServices(app, host, conf.TCP); err != nil {
+				c.report.Add(source, "%v, the application was skipped", err)
+				continue
+			}
+			reportDropped(c.report, source, "TCP router", conf.TCP.Routers, func(r *dynamic.TCPRouter) string {
+				if r.Rule == "" {
+					return "has no rule"
+				}
+				return unassigned(r.Service, len(conf.TCP.Services))
+			})
+			provider.BuildTCPRouterConfiguration(ctx, conf.TCP)
+		}
+		if len(conf.UDP.Routers) > 0 || len(conf.UDP.Services) > 0 {
+			tcpOrUDP = true
+			if err := c.udpServices(app, host, conf.UDP); err != nil {
+				c.report.Add(source, "%v, the application was skipped", err)
+				continue
+			}
+			reportDropped(c.report, source, "UDP router", conf.UDP.Routers, func(r *dynamic.UDPRouter) string {
+				return unassigned(r.Service, len(conf.UDP.Services))
+			})
+			provider.BuildUDPRouterConfiguration(ctx, conf.UDP)
+		}
+
+		if !tcpOrUDP || len(conf.HTTP.Routers) > 0 || len(conf.HTTP.Middlewares) > 0 || len(conf.HTTP.Services) > 0 {
+			if err := c.httpServices(app, host, conf.HTTP); err != nil {
+				c.report.Add(source, "%v, the application was skipped", err)
+				continue
+			}
+			if len(conf.HTTP.Routers) == 0 && len(conf.HTTP.Services) > 1 {
+				c.report.Add(source, "no router was created, as the application has several services")
+			}
+			reportDropped(c.report, source, "router", conf.HTTP.Routers, func(r *dynamic.Router) string {
+				return unassigned(r.Service, len(conf.HTTP.Services))
+			})
+			model := struct {
+				Name   string
+				Labels map[string]string
+			}{Name: app.ID, Labels: traefikLabels}
+			provider.BuildRouterConfiguration(ctx, conf.HTTP, app.serviceName(), defaultRuleTpl, model)
+		}
+
+		m.add(app.ID, conf)
+	}
+	return m.configuration(), nil
+}
+
+// httpServices gives the HTTP services of an application, or its default
+// service, a server on the port of their labels.
+func (c *Converter) httpServices(app *application, host string, conf *dynamic.HTTPConfiguration) error {
+	if len(conf.Services) == 0 {
+		lb := &dynamic.ServersLoadBalancer{}
+		lb.SetDefaults()
+		conf.Services = map[string]*dynamic.Service{app.serviceName(): {LoadBalancer: lb}}
+	}
+
+	for name, service := range conf.Services {
+		if service.LoadBalancer == nil {
+			continue
+		}
+		server := dynamic.Server{}
+		server.SetDefaults()
+		if len(service.LoadBalancer.Servers) > 0 {
+			server = service.LoadBalancer.Servers[0]
+		}
+		p, err := app.resolvePort(server.Port)
+		if err != nil {
+			return fmt.Errorf("service %s: %w", name, err)
+		}
+		service.LoadBalancer.Servers = []dynamic.Server{{URL: fmt.Sprintf("%s://%s", server.Scheme, net.JoinHostPort(host, p))}}
+	}
+	return nil
+}
+
+func (c *Converter) tcpServices(app *application, host string, conf *dynamic.TCPConfiguration) error {
+	if len(conf.Services) == 0 {
+		conf.Services = map[string]*dynamic.TCPService{app.serviceName(): {LoadBalancer: &dynamic.TCPServersLoadBalancer{}}}
+	}
+
+	for name, service := range conf.Services {
+		if service.LoadBalancer == nil {
+			continue
+		}
+		server := dynamic.TCPServer{}
+		if len(service.LoadBalancer.Servers) > 0 {
+			server = service.LoadBalancer.Servers[0]
+		}
+		p, err := app.resolvePort(server.Port)
+		if err != nil {
+			return fmt.Errorf("TCP service %s: %w", name, err)
+		}
+		service.LoadBalancer.Servers = []dynamic.TCPServer{{Address: net.JoinHostPort(host, p), TLS: server.TLS}}
+	}
+	return nil
+}
+
+func (c *Converter) udpServices(app *application, host string, conf *dynamic.UDPConfiguration) error {
+	if len(conf.Services) == 0 {
+		conf.Services = map[string]*dynamic.UDPService{app.serviceName(): {LoadBalancer: &dynamic.UDPServersLoadBalancer{}}}
+	}
+
+	for name, service := range conf.Services {
+		if service.LoadBalancer == nil {
+			continue
+		}
+		server := dynamic.UDPServer{}
+		if len(service.LoadBalancer.Servers) > 0 {
+			server = service.LoadBalancer.Servers[0]
+		}
+		p, err := app.resolvePort(server.Port)
+		if err != nil {
+			return fmt.Errorf("UDP service %s: %w", name, err)
+		}
+		service.LoadBalancer.Servers = []dynamic.UDPServer{{Address: net.JoinHostPort(host, p)}}
+	}
+	return nil
+}
+
+// reportDropped reports the routers the provider drops, with the reason
+// returned by dropped.
+func reportDropped[R any](r *report.Report, source, kind string, routers map[string]R, dropped func(R) string) {
+	for _, name := range sortedKeys(routers) {
+		if reason := dropped(routers[name]); reason != "" {
+			r.Add(source, "%s %s %s and was dropped", kind, name, reason)
+		}
+	}
+}
+
+// unassigned returns why a router naming no service can't be given one.
+func unassigned(service string, services int) string {
+	if service == "" && services > 1 {
+		return "names no service while the application has several"
+	}
+	return ""
+}
+
+// mesosDNSName returns the Mesos-DNS name of a Marathon application: the
+// segments of its id in reverse order.
+func mesosDNSName(id string) string {
+	segments := strings.FieldsFunc(id, func(r rune) bool { return r == '/' })
+	for i, j := 0, len(segments)-1; i < j; i, j = i+1, j-1 {
+		segments[i], segments[j] = segments[j], segments[i]
+	}
+	return strings.Join(segments, "-") + ".marathon.mesos"
+}
+
+// merger merges the configurations of the applications. Elements defined
+// differently by several applications are dropped, as the v2 provider did.
+type merger struct {
+	report    *report.Report
+	config    *dynamic.Configuration
+	conflicts map[string]bool
+}
+
+func newMerger(r *report.Report) *merger {
+	return &merger{
+		report: r,
+		config: &dynamic.Configuration{
+			HTTP: &dynamic.HTTPConfiguration{},
+			TCP:  &dynamic.TCPConfiguration{},
+			UDP:  &dynamic.UDPConfiguration{},
+		},
+		conflicts: map[string]bool{},
+	}
+}
+
+func (m *merger) add(id string, conf *dynamic.Configuration) {
+	mergeElements(m, id, "router", &m.config.HTTP.Routers, conf.HTTP.Routers)
+	mergeElements(m, id, "middleware", &m.config.HTTP.Middlewares, conf.HTTP.Middlewares)
+	mergeElements(m, id, "service", &m.config.HTTP.Services, conf.HTTP.Services)
+	mergeElements(m, id, "serversTransport", &m.config.HTTP.ServersTransports, conf.HTTP.ServersTransports)
+	mergeElements(m, id, "TCP router", &m.config.TCP.Routers, conf.TCP.Routers)
+	mergeElements(m, id, "TCP middleware", &m.config.TCP.Middlewares, conf.TCP.Middlewares)
+	mergeElements(m, id, "TCP service", &m.config.TCP.Services, conf.TCP.Services)
+	mergeElements(m, id, "TCP serversTransport", &m.config.TCP.ServersTransports, conf.TCP.ServersTransports)
+	mergeElements(m, id, "UDP router", &m.config.UDP.Routers, conf.UDP.Routers)
+	mergeElements(m, id, "UDP service", &m.config.UDP.Services, conf.UDP.Services)
+}
+
+// configuration returns the merged configuration, without its empty
+// sections.
+func (m *merger) configuration() *dynamic.Configuration {
+	config := &dynamic.Configuration{}
+	if !reflect.ValueOf(*m.config.HTTP).IsZero() {
+		config.HTTP = m.config.HTTP
+	}
+	if !reflect.ValueOf(*m.config.TCP).IsZero() {
+		config.TCP = m.config.TCP
+	}
+	if !reflect.ValueOf(*m.config.UDP).IsZero() {
+		config.UDP = m.config.UDP
+	}
+	return config
+}
+
+func mergeElements[T any](m *merger, id, kind string, dst *map[string]T, src map[string]T) {
+	for _, name := range sortedKeys(src) {
+		key := kind + "/" + name
+		if m.conflicts[key] {
+			continue
+		}
+		existing, ok := (*dst)[name]
+		if !ok {
+			if *dst == nil {
+				*dst = map[string]T{}
+			}
+			(*dst)[name] = src[name]
+			continue
+		}
+		if !reflect.DeepEqual(existing, src[name]) {
+			m.report.Add("application "+id, "%s %s is defined differently by several applications and was dropped", kind, name)
+			m.conflicts[key] = true
+			delete(*dst, name)
+		}
+	}
+}
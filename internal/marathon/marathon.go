@@ -0,0 +1,280 @@
+// Package marathon converts the Traefik labels of Marathon application
+// definitions, read by the v2 Marathon provider which was removed in v3, to
+// a file provider dynamic configuration or to Docker labels.
+package marathon
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/databotic/traefik-migration-tool/internal/labels"
+	"github.com/databotic/traefik-migration-tool/internal/report"
+)
+
+// Target is what the application definitions are converted to.
+type Target string
+
+const (
+	// TargetFileProvider converts the applications to a file provider
+	// dynamic configuration.
+	TargetFileProvider Target = "file-provider"
+	// TargetDocker converts the applications to docker-compose services
+	// carrying v3 labels.
+	TargetDocker Target = "docker"
+)
+
+const (
+	// defaultRule is the default rule of the routers of the v2 Marathon
+	// provider.
+	defaultRule = "Host(`{{ normalize .Name }}`)"
+
+	// marathonPrefix is the prefix of the labels specific to the Marathon
+	// provider.
+	marathonPrefix = "traefik.marathon."
+)
+
+type application struct {
+	ID              string            `json:"id"`
+	Labels          map[string]string `json:"labels"`
+	Container       *container        `json:"container"`
+	PortDefinitions []port            `json:"portDefinitions"`
+	Ports           []int             `json:"ports"`
+}
+
+type container struct {
+	Docker       *docker `json:"docker"`
+	PortMappings []port  `json:"portMappings"`
+}
+
+type docker struct {
+	Image        string `json:"image"`
+	PortMappings []port `json:"portMappings"`
+}
+
+// port is either a port mapping, for applications running in containers,
+// or a port definition.
+type port struct {
+	Name          string `json:"name"`
+	Port          int    `json:"port"`
+	ContainerPort int    `json:"containerPort"`
+	HostPort      int    `json:"hostPort"`
+}
+
+// Converter converts the Traefik labels of Marathon applications. Labels
+// are rewritten to v3 the same way as the other label based inputs, then
+// interpreted the way the v2 Marathon provider did.
+type Converter struct {
+	labels *labels.Rewriter
+	report *report.Report
+
+	// ExposedByDefault mirrors the option of the v2 Marathon provider:
+	// applications without a traefik.enable label are only converted when
+	// it is set.
+	ExposedByDefault bool
+}
+
+func New(rewriter *labels.Rewriter, r *report.Report) *Converter {
+	return &Converter{labels: rewriter, report: r, ExposedByDefault: true}
+}
+
+// applications returns the applications of content exposed to Traefik,
+// with their labels rewritten to v3.
+func (c *Converter) applications(content []byte) ([]*application, error) {
+	apps, err := decode(content)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing Marathon application definitions: %v", err)
+	}
+
+	var exposed []*application
+	for _, app := range apps {
+		source := "application " + app.ID
+		if !c.enabled(app) {
+			c.report.Add(source, "application is not exposed to Traefik and was skipped")
+			continue
+		}
+
+		converted := map[string]string{}
+		for _, key := range sortedKeys(app.Labels) {
+			value := app.Labels[key]
+			if !c.labels.IsTraefikKey(key) {
+				continue
+			}
+			if strings.HasPrefix(strings.ToLower(key), marathonPrefix) {
+				c.report.Add(source, "label %s is specific to the Marathon provider and was dropped", key)
+				continue
+			}
+			if msg := c.labels.Deprecated(key); msg != "" {
+				c.report.Add(source, "label %s: %s and should be fixed manually", key, msg)
+			}
+
+			newKey, newValue, err := c.labels.Rewrite(key, value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", source, err)
+			}
+			if isServerPort(newKey) {
+				if newValue, err = app.resolvePort(newValue); err != nil {
+					return nil, fmt.Errorf("%s: label %s: %w", source, key, err)
+				}
+			}
+			converted[newKey] = newValue
+		}
+		app.Labels = converted
+		exposed = append(exposed, app)
+	}
+	return exposed, nil
+}
+
+// enabled reports whether the v2 Marathon provider exposed app.
+func (c *Converter) enabled(app *application) bool {
+	for key, value := range app.Labels {
+		if strings.EqualFold(key, "traefik.enable") {
+			enable, err := strconv.ParseBool(value)
+			return err == nil && enable
+		}
+	}
+	return c.ExposedByDefault
+}
+
+// ports returns the ports of app in the order Marathon lists them, the ones
+// index:N labels refer to.
+func (a *application) ports() []port {
+	var ports []port
+	if a.Container != nil {
+		mappings := a.Container.PortMappings
+		if len(mappings) == 0 && a.Container.Docker != nil {
+			mappings = a.Container.Docker.PortMappings
+		}
+		for _, m := range mappings {
+			p := m.ContainerPort
+			if p == 0 {
+				p = m.HostPort
+			}
+			ports = append(ports, port{Name: m.Name, Port: p})
+		}
+	}
+	if len(ports) == 0 {
+		ports = append(ports, a.PortDefinitions...)
+	}
+	if len(ports) == 0 {
+		for _, p := range a.Ports {
+			ports = append(ports, port{Port: p})
+		}
+	}
+	return ports
+}
+
+// resolvePort returns the port a server port label selects: a port number,
+// or the index:N and name:NAME forms of the v2 Marathon provider, which v3
+// providers don't have. An empty label selects the first port.
+func (a *application) resolvePort(value string) (string, error) {
+	if value != "" && !strings.HasPrefix(value, "index:") && !strings.HasPrefix(value, "name:") {
+		return value, nil
+	}
+
+	ports := a.ports()
+	if name, ok := strings.CutPrefix(value, "name:"); ok {
+		for _, p := range ports {
+			if p.Name == name {
+				return strconv.Itoa(p.Port), nil
+			}
+		}
+		return "", fmt.Errorf("no port with name %s", name)
+	}
+
+	index := 0
+	if i, ok := strings.CutPrefix(value, "index:"); ok {
+		var err error
+		if index, err = strconv.Atoi(i); err != nil {
+			return "", err
+		}
+	}
+	if index < 0 || index >= len(ports) || ports[index].Port == 0 {
+		return "", fmt.Errorf("no port at index %d", index)
+	}
+	return strconv.Itoa(ports[index].Port), nil
+}
+
+// serviceName is the name the v2 Marathon provider gave the default router
+// and service of an application.
+func (a *application) serviceName() string {
+	return strings.ReplaceAll(strings.TrimPrefix(a.ID, "/"), "/", "_")
+}
+
+// isServerPort reports whether key is the server port label of a service.
+func isServerPort(key string) bool {
+	parts := strings.Split(strings.ToLower(key), ".")
+	return len(parts) == 7 && parts[2] == "services" &&
+		strings.Join(parts[4:], ".") == "loadbalancer.server.port"
+}
+
+// decode returns the applications of a definition, walking groups and
+// lists.
+func decode(content []byte) ([]*application, error) {
+	content = bytes.TrimSpace(content)
+	if len(content) == 0 || (content[0] != '{' && content[0] != '[') {
+		return nil, errors.New("expected a JSON application, group or list")
+	}
+
+	var apps []*application
+	if err := collect(content, &apps); err != nil {
+		return nil, err
+	}
+	return apps, nil
+}
+
+func collect(content json.RawMessage, apps *[]*application) error {
+	if bytes.HasPrefix(bytes.TrimSpace(content), []byte("[")) {
+		var items []json.RawMessage
+		if err := json.Unmarshal(content, &items); err != nil {
+			return err
+		}
+		for _, item := range items {
+			if err := collect(item, apps); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(content, &fields); err != nil {
+		return err
+	}
+
+	_, hasApps := fields["apps"]
+	_, hasGroups := fields["groups"]
+	if hasApps || hasGroups {
+		for _, key := range []string{"apps", "groups"} {
+			if list, ok := fields[key]; ok {
+				if err := collect(list, apps); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if _, ok := fields["id"]; !ok {
+		return errors.New("application without id")
+	}
+
+	app := &application{}
+	if err := json.Unmarshal(content, app); err != nil {
+		return err
+	}
+	*apps = append(*apps, app)
+	return nil
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,112 @@
+package marathon
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/databotic/traefik-migration-tool/internal/converter"
+	"github.com/databotic/traefik-migration-tool/internal/fileprovider"
+	"github.com/databotic/traefik-migration-tool/internal/labels"
+	"github.com/databotic/traefik-migration-tool/internal/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var updateExpected = flag.Bool("update_expected", false, "Update expected files in testdata")
+
+func newConverter(t *testing.T, r *report.Report) *Converter {
+	t.Helper()
+
+	ingressRoute, err := converter.NewIngressRoute()
+	require.NoError(t, err)
+	tcpRules, err := converter.NewTCPRule()
+	require.NoError(t, err)
+
+	rewriter := labels.New(ingressRoute)
+	rewriter.TCPRules = tcpRules
+	return New(rewriter, r)
+}
+
+func assertFixture(t *testing.T, file string, converted []byte) {
+	t.Helper()
+
+	fixtureFile := filepath.Join("fixtures", "output", file)
+	if *updateExpected {
+		require.NoError(t, os.WriteFile(fixtureFile, converted, 0o666))
+	}
+
+	expected, err := os.ReadFile(fixtureFile)
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), string(converted))
+}
+
+func TestFileProvider(t *testing.T) {
+	src, err := os.ReadFile(filepath.Join("fixtures", "input", "apps.json"))
+	require.NoError(t, err)
+
+	r := report.New()
+	config, err := newConverter(t, r).FileProvider(src)
+	require.NoError(t, err)
+
+	converted, err := fileprovider.Encode(config, fileprovider.FormatYAML)
+	require.NoError(t, err)
+	assertFixture(t, "apps.yaml", converted)
+
+	expected := []report.Entry{
+		{Source: "application /web/whoami", Message: "label traefik.marathon.ipaddressidx is specific to the Marathon provider and was dropped"},
+		{Source: "application /data/batch", Message: "application is not exposed to Traefik and was skipped"},
+		{Source: "application /web/whoami", Message: "servers point to whoami-web.marathon.mesos, the Mesos-DNS name of the application, and must be checked"},
+		{Source: "application /web/static", Message: "servers point to static-web.marathon.mesos, the Mesos-DNS name of the application, and must be checked"},
+		{Source: "application /data/postgres", Message: "servers point to postgres-data.marathon.mesos, the Mesos-DNS name of the application, and must be checked"},
+	}
+	assert.Equal(t, expected, r.Entries())
+}
+
+func TestCompose(t *testing.T) {
+	src, err := os.ReadFile(filepath.Join("fixtures", "input", "apps.json"))
+	require.NoError(t, err)
+
+	r := report.New()
+	c := newConverter(t, r)
+	c.ExposedByDefault = false
+	converted, err := c.Compose(src)
+	require.NoError(t, err)
+	assertFixture(t, "apps_docker.yaml", converted)
+
+	expected := []report.Entry{
+		{Source: "application /web/whoami", Message: "label traefik.marathon.ipaddressidx is specific to the Marathon provider and was dropped"},
+		{Source: "application /web/static", Message: "application is not exposed to Traefik and was skipped"},
+		{Source: "application /data/postgres", Message: "application is not exposed to Traefik and was skipped"},
+		{Source: "application /data/batch", Message: "application is not exposed to Traefik and was skipped"},
+	}
+	assert.Equal(t, expected, r.Entries())
+}
+
+func TestResolvePort(t *testing.T) {
+	app := &application{
+		ID:              "/web",
+		PortDefinitions: []port{{Port: 10000, Name: "http"}, {Port: 10001, Name: "admin"}},
+	}
+
+	testCases := []struct {
+		value    string
+		expected string
+	}{
+		{value: "", expected: "10000"},
+		{value: "8080", expected: "8080"},
+		{value: "index:1", expected: "10001"},
+		{value: "name:admin", expected: "10001"},
+	}
+	for _, test := range testCases {
+		port, err := app.resolvePort(test.value)
+		require.NoError(t, err)
+		assert.Equal(t, test.expected, port)
+	}
+
+	_, err := app.resolvePort("index:2")
+	assert.Error(t, err)
+	_, err = app.resolvePort("name:metrics")
+	assert.Error(t, err)
+}
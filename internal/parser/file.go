@@ -1,12 +1,17 @@
 package parser
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
-	"os"
+	"strings"
 
+	"github.com/databotic/traefik-migration-tool/internal/utils"
 	containous "github.com/traefik/traefik/v2/pkg/provider/kubernetes/crd/traefikcontainous/v1alpha1"
 	traefikio "github.com/traefik/traefik/v3/pkg/provider/kubernetes/crd/traefikio/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/yaml"
 	"k8s.io/client-go/kubernetes/scheme"
@@ -18,30 +23,166 @@ func init() {
 
 }
 
-func ParseManifest(file *os.File) ([]runtime.Object, error) {
-	defer func() { _ = file.Close() }()
+// Unknown is an object whose kind is not registered in the scheme. It is
+// decoded as unstructured and keeps the source of its document, so it can be
+// written back unchanged.
+type Unknown struct {
+	unstructured.Unstructured
+
+	// Source is the document the object was decoded from. Items of lists
+	// have no document of their own, their source is encoded from the list.
+	Source []byte
+}
+
+func (u *Unknown) DeepCopyObject() runtime.Object {
+	return &Unknown{Unstructured: *u.Unstructured.DeepCopy(), Source: bytes.Clone(u.Source)}
+}
+
+// document is a document of a manifest, in its original form and as JSON.
+type document struct {
+	source []byte
+	json   []byte
+}
+
+func ParseManifest(r io.Reader) ([]runtime.Object, error) {
+	documents, err := readDocuments(r)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing manifest: %v", err)
+	}
 
-	decoder := yaml.NewYAMLOrJSONDecoder(file, 4096)
 	deser := scheme.Codecs.UniversalDeserializer()
 
 	var objects []runtime.Object
+	for _, doc := range documents {
+		items, expanded, err := expandList(doc.json)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing list in manifest: %v", err)
+		}
+
+		for _, item := range items {
+			obj, _, err := deser.Decode(item, nil, nil)
+			if runtime.IsNotRegisteredError(err) {
+				obj, err = decodeUnknown(item, doc.source, expanded)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("error parsing object in manifest: %v", err)
+			}
 
+			objects = append(objects, obj)
+		}
+	}
+
+	return objects, nil
+}
+
+// readDocuments splits a YAML stream, or a stream of JSON objects, into
+// documents. Empty documents are skipped.
+func readDocuments(r io.Reader) ([]document, error) {
+	reader, _, isJSON := yaml.GuessJSONStream(r, 4096)
+
+	var documents []document
+	if isJSON {
+		decoder := yaml.NewYAMLOrJSONDecoder(reader, 4096)
+		for {
+			ext := runtime.RawExtension{}
+			if err := decoder.Decode(&ext); err != nil {
+				if err == io.EOF {
+					return documents, nil
+				}
+				return nil, err
+			}
+			documents = append(documents, document{source: ext.Raw, json: ext.Raw})
+		}
+	}
+
+	yamlReader := yaml.NewYAMLReader(bufio.NewReader(reader))
 	for {
-		ext := runtime.RawExtension{}
-		if err := decoder.Decode(&ext); err != nil {
+		source, err := yamlReader.Read()
+		if err != nil {
 			if err == io.EOF {
-				break
+				return documents, nil
+			}
+			return nil, err
+		}
+
+		data, err := yaml.ToJSON(source)
+		if err != nil {
+			return nil, err
+		}
+		if data = bytes.TrimSpace(data); len(data) == 0 || string(data) == "null" {
+			continue
+		}
+		documents = append(documents, document{source: trimSeparator(source), json: data})
+	}
+}
+
+// trimSeparator removes the separator the first document of a stream may
+// start with, as it is written between the documents when encoding them.
+func trimSeparator(source []byte) []byte {
+	if !bytes.HasPrefix(source, []byte("---")) {
+		return source
+	}
+	if i := bytes.IndexByte(source, '\n'); i >= 0 {
+		return source[i+1:]
+	}
+	return nil
+}
+
+func decodeUnknown(data, source []byte, expanded bool) (runtime.Object, error) {
+	u := &Unknown{}
+	if err := u.UnmarshalJSON(data); err != nil {
+		return nil, err
+	}
+
+	if !expanded {
+		u.Source = source
+		return u, nil
+	}
+
+	var err error
+	u.Source, err = utils.EncodeYaml(u.Object)
+	return u, err
+}
+
+// list holds the fields of a List, or of a typed *List, needed to expand
+// its items.
+type list struct {
+	APIVersion string                   `json:"apiVersion"`
+	Kind       string                   `json:"kind"`
+	Items      []map[string]interface{} `json:"items"`
+}
+
+// expandList returns the items of a List, as returned by kubectl get, or
+// the raw object itself when it is not a list. Items of typed lists get the
+// kind and apiVersion of their list when they are missing.
+func expandList(raw []byte) ([][]byte, bool, error) {
+	var l list
+	if err := json.Unmarshal(raw, &l); err != nil || !strings.HasSuffix(l.Kind, "List") || l.Items == nil {
+		return [][]byte{raw}, false, nil
+	}
+
+	var items [][]byte
+	for _, item := range l.Items {
+		if l.Kind != "List" {
+			if _, ok := item["kind"]; !ok {
+				item["kind"] = strings.TrimSuffix(l.Kind, "List")
+			}
+			if _, ok := item["apiVersion"]; !ok {
+				item["apiVersion"] = l.APIVersion
 			}
-			return nil, fmt.Errorf("error parsing manifest: %v", err)
 		}
 
-		obj, _, err := deser.Decode([]byte(ext.Raw), nil, nil)
+		data, err := json.Marshal(item)
 		if err != nil {
-			return nil, fmt.Errorf("error parsing object in manifest: %v", err)
+			return nil, false, err
 		}
 
-		objects = append(objects, obj)
+		expanded, _, err := expandList(data)
+		if err != nil {
+			return nil, false, err
+		}
+		items = append(items, expanded...)
 	}
 
-	return objects, nil
+	return items, true, nil
 }
@@ -0,0 +1,372 @@
+package rawdata
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/databotic/traefik-migration-tool/internal/report"
+	containous "github.com/traefik/traefik/v2/pkg/config/dynamic"
+	"github.com/traefik/traefik/v2/pkg/config/runtime"
+)
+
+const endpoint = "/api/rawdata"
+
+// skippedProviders are the providers whose elements are not converted to the
+// file provider, with the reason why.
+var skippedProviders = map[string]string{
+	"internal":          "provided by Traefik itself",
+	"kubernetescrd":     "defined by kubernetes resources, convert the manifests instead",
+	"kubernetes":        "defined by kubernetes resources, convert the manifests instead",
+	"kubernetesgateway": "defined by kubernetes resources, convert the manifests instead",
+}
+
+// RawData is the representation of the dynamic configuration served by the
+// /api/rawdata endpoint of Traefik v2.
+type RawData struct {
+	Routers        map[string]*runtime.RouterInfo        `json:"routers,omitempty"`
+	Middlewares    map[string]*runtime.MiddlewareInfo    `json:"middlewares,omitempty"`
+	Services       map[string]*runtime.ServiceInfo       `json:"services,omitempty"`
+	TCPRouters     map[string]*runtime.TCPRouterInfo     `json:"tcpRouters,omitempty"`
+	TCPMiddlewares map[string]*runtime.TCPMiddlewareInfo `json:"tcpMiddlewares,omitempty"`
+	TCPServices    map[string]*runtime.TCPServiceInfo    `json:"tcpServices,omitempty"`
+	UDPRouters     map[string]*runtime.UDPRouterInfo     `json:"udpRouters,omitempty"`
+	UDPServices    map[string]*runtime.UDPServiceInfo    `json:"udpServices,omitempty"`
+}
+
+// Item is an element of the inventory of a running Traefik instance.
+type Item struct {
+	Kind     string
+	Name     string
+	Provider string
+	Status   string
+	// Converted is the name of the element in the generated configuration,
+	// empty if it was not converted.
+	Converted string
+	Reason    string
+}
+
+// Fetch reads the raw dynamic configuration of the Traefik instance at url.
+func Fetch(client *http.Client, url string) (*RawData, error) {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	url = strings.TrimSuffix(url, "/")
+	if !strings.HasSuffix(url, endpoint) {
+		url += endpoint
+	}
+
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching %s: %v", url, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error fetching %s: unexpected status %s", url, resp.Status)
+	}
+
+	data := &RawData{}
+	if err := json.NewDecoder(resp.Body).Decode(data); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", url, err)
+	}
+	return data, nil
+}
+
+// section holds the elements of one kind, keyed by their qualified name.
+type section struct {
+	kind     string
+	statuses map[string]string
+	names    map[string]string
+	items    []*Item
+}
+
+func newSection(kind string) *section {
+	return &section{kind: kind, statuses: map[string]string{}, names: map[string]string{}}
+}
+
+func (s *section) add(qualified, status string, errs []string) {
+	s.statuses[qualified] = status
+	name, provider := splitQualified(qualified)
+	item := &Item{Kind: s.kind, Name: name, Provider: provider, Status: status}
+
+	switch {
+	case skippedProviders[provider] != "":
+		item.Reason = skippedProviders[provider]
+	case status == runtime.StatusDisabled:
+		item.Reason = "disabled: " + strings.Join(errs, ", ")
+	}
+	s.items = append(s.items, item)
+}
+
+// assign computes the names of the converted elements. Names are stripped
+// of their provider, unless several providers define the same name.
+func (s *section) assign() {
+	sort.Slice(s.items, func(i, j int) bool {
+		return s.items[i].Name+"@"+s.items[i].Provider < s.items[j].Name+"@"+s.items[j].Provider
+	})
+
+	count := map[string]int{}
+	for _, item := range s.items {
+		if item.Reason == "" {
+			count[item.Name]++
+		}
+	}
+	for _, item := range s.items {
+		if item.Reason != "" {
+			continue
+		}
+		item.Converted = item.Name
+		if count[item.Name] > 1 {
+			item.Converted = item.Name + "-" + item.Provider
+		}
+		s.names[item.Name+"@"+item.Provider] = item.Converted
+	}
+}
+
+// ref rewrites a reference made by an element of provider.
+func (s *section) ref(provider, ref string, r *report.Report, source string) string {
+	qualified := ref
+	if !strings.Contains(ref, "@") {
+		qualified = ref + "@" + provider
+	}
+	if name, ok := s.names[qualified]; ok {
+		return name
+	}
+	if _, ok := s.statuses[qualified]; !ok {
+		r.Add(source, "%s %s is not part of the configuration", s.kind, qualified)
+	}
+	return qualified
+}
+
+// Importer builds a v2 file provider configuration out of the elements of a
+// running Traefik instance which can be converted.
+type Importer struct {
+	report *report.Report
+}
+
+func NewImporter(r *report.Report) *Importer {
+	return &Importer{report: r}
+}
+
+func (i *Importer) Import(data *RawData) (*containous.Configuration, []Item) {
+	routers, middlewares, services := newSection("router"), newSection("middleware"), newSection("service")
+	tcpRouters, tcpMiddlewares, tcpServices := newSection("tcp router"), newSection("tcp middleware"), newSection("tcp service")
+	udpRouters, udpServices := newSection("udp router"), newSection("udp service")
+
+	for name, info := range data.Routers {
+		routers.add(name, info.Status, info.Err)
+	}
+	for name, info := range data.Middlewares {
+		middlewares.add(name, info.Status, info.Err)
+	}
+	for name, info := range data.Services {
+		services.add(name, info.Status, info.Err)
+	}
+	for name, info := range data.TCPRouters {
+		tcpRouters.add(name, info.Status, info.Err)
+	}
+	for name, info := range data.TCPMiddlewares {
+		tcpMiddlewares.add(name, info.Status, info.Err)
+	}
+	for name, info := range data.TCPServices {
+		tcpServices.add(name, info.Status, info.Err)
+	}
+	for name, info := range data.UDPRouters {
+		udpRouters.add(name, info.Status, info.Err)
+	}
+	for name, info := range data.UDPServices {
+		udpServices.add(name, info.Status, info.Err)
+	}
+
+	sections := []*section{routers, middlewares, services, tcpRouters, tcpMiddlewares, tcpServices, udpRouters, udpServices}
+	for _, s := range sections {
+		s.assign()
+	}
+
+	config := &containous.Configuration{
+		HTTP: &containous.HTTPConfiguration{
+			Routers:     map[string]*containous.Router{},
+			Middlewares: map[string]*containous.Middleware{},
+			Services:    map[string]*containous.Service{},
+		},
+		TCP: &containous.TCPConfiguration{
+			Routers:     map[string]*containous.TCPRouter{},
+			Middlewares: map[string]*containous.TCPMiddleware{},
+			Services:    map[string]*containous.TCPService{},
+		},
+		UDP: &containous.UDPConfiguration{
+			Routers:  map[string]*containous.UDPRouter{},
+			Services: map[string]*containous.UDPService{},
+		},
+	}
+
+	for qualified, info := range data.Routers {
+		name, ok := routers.names[qualified]
+		if !ok || info.Router == nil {
+			continue
+		}
+		router := info.Router.DeepCopy()
+		_, provider := splitQualified(qualified)
+		router.Service = services.ref(provider, router.Service, i.report, "router "+qualified)
+		for j, m := range router.Middlewares {
+			router.Middlewares[j] = middlewares.ref(provider, m, i.report, "router "+qualified)
+		}
+		config.HTTP.Routers[name] = router
+	}
+
+	for qualified, info := range data.Middlewares {
+		name, ok := middlewares.names[qualified]
+		if !ok || info.Middleware == nil {
+			continue
+		}
+		middleware := info.Middleware.DeepCopy()
+		_, provider := splitQualified(qualified)
+		if middleware.Chain != nil {
+			for j, m := range middleware.Chain.Middlewares {
+				middleware.Chain.Middlewares[j] = middlewares.ref(provider, m, i.report, "middleware "+qualified)
+			}
+		}
+		config.HTTP.Middlewares[name] = middleware
+	}
+
+	for qualified, info := range data.Services {
+		name, ok := services.names[qualified]
+		if !ok || info.Service == nil {
+			continue
+		}
+		service := info.Service.DeepCopy()
+		_, provider := splitQualified(qualified)
+		source := "service " + qualified
+		if service.Weighted != nil {
+			for j, s := range service.Weighted.Services {
+				service.Weighted.Services[j].Name = services.ref(provider, s.Name, i.report, source)
+			}
+		}
+		if service.Mirroring != nil {
+			service.Mirroring.Service = services.ref(provider, service.Mirroring.Service, i.report, source)
+			for j, s := range service.Mirroring.Mirrors {
+				service.Mirroring.Mirrors[j].Name = services.ref(provider, s.Name, i.report, source)
+			}
+		}
+		if service.Failover != nil {
+			service.Failover.Service = services.ref(provider, service.Failover.Service, i.report, source)
+			service.Failover.Fallback = services.ref(provider, service.Failover.Fallback, i.report, source)
+		}
+		config.HTTP.Services[name] = service
+	}
+
+	for qualified, info := range data.TCPRouters {
+		name, ok := tcpRouters.names[qualified]
+		if !ok || info.TCPRouter == nil {
+			continue
+		}
+		router := info.TCPRouter.DeepCopy()
+		_, provider := splitQualified(qualified)
+		router.Service = tcpServices.ref(provider, router.Service, i.report, "tcp router "+qualified)
+		for j, m := range router.Middlewares {
+			router.Middlewares[j] = tcpMiddlewares.ref(provider, m, i.report, "tcp router "+qualified)
+		}
+		config.TCP.Routers[name] = router
+	}
+
+	for qualified, info := range data.TCPMiddlewares {
+		if name, ok := tcpMiddlewares.names[qualified]; ok && info.TCPMiddleware != nil {
+			config.TCP.Middlewares[name] = info.TCPMiddleware.DeepCopy()
+		}
+	}
+
+	for qualified, info := range data.TCPServices {
+		name, ok := tcpServices.names[qualified]
+		if !ok || info.TCPService == nil {
+			continue
+		}
+		service := info.TCPService.DeepCopy()
+		_, provider := splitQualified(qualified)
+		if service.Weighted != nil {
+			for j, s := range service.Weighted.Services {
+				service.Weighted.Services[j].Name = tcpServices.ref(provider, s.Name, i.report, "tcp service "+qualified)
+			}
+		}
+		config.TCP.Services[name] = service
+	}
+
+	for qualified, info := range data.UDPRouters {
+		name, ok := udpRouters.names[qualified]
+		if !ok || info.UDPRouter == nil {
+			continue
+		}
+		router := info.UDPRouter.DeepCopy()
+		_, provider := splitQualified(qualified)
+		router.Service = udpServices.ref(provider, router.Service, i.report, "udp router "+qualified)
+		config.UDP.Routers[name] = router
+	}
+
+	for qualified, info := range data.UDPServices {
+		name, ok := udpServices.names[qualified]
+		if !ok || info.UDPService == nil {
+			continue
+		}
+		service := info.UDPService.DeepCopy()
+		_, provider := splitQualified(qualified)
+		if service.Weighted != nil {
+			for j, s := range service.Weighted.Services {
+				service.Weighted.Services[j].Name = udpServices.ref(provider, s.Name, i.report, "udp service "+qualified)
+			}
+		}
+		config.UDP.Services[name] = service
+	}
+
+	var inventory []Item
+	for _, s := range sections {
+		for _, item := range s.items {
+			inventory = append(inventory, *item)
+			if item.Reason != "" {
+				i.report.Add(fmt.Sprintf("%s %s@%s", item.Kind, item.Name, item.Provider), "not converted, %s", item.Reason)
+			}
+		}
+	}
+	return prune(config), inventory
+}
+
+// prune drops the empty sections of the configuration.
+func prune(config *containous.Configuration) *containous.Configuration {
+	if len(config.HTTP.Routers)+len(config.HTTP.Middlewares)+len(config.HTTP.Services) == 0 {
+		config.HTTP = nil
+	}
+	if len(config.TCP.Routers)+len(config.TCP.Middlewares)+len(config.TCP.Services) == 0 {
+		config.TCP = nil
+	}
+	if len(config.UDP.Routers)+len(config.UDP.Services) == 0 {
+		config.UDP = nil
+	}
+	return config
+}
+
+// WriteInventory writes the inventory as a table.
+func WriteInventory(w io.Writer, inventory []Item) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "KIND\tNAME\tPROVIDER\tSTATUS\tCONVERTED")
+	for _, item := range inventory {
+		converted := item.Converted
+		if converted == "" {
+			converted = "-"
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", item.Kind, item.Name, item.Provider, item.Status, converted)
+	}
+	return tw.Flush()
+}
+
+func splitQualified(name string) (string, string) {
+	i := strings.LastIndex(name, "@")
+	if i < 0 {
+		return name, ""
+	}
+	return name[:i], name[i+1:]
+}
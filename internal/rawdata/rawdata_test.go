@@ -0,0 +1,72 @@
+package rawdata
+
+import (
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/databotic/traefik-migration-tool/internal/fileprovider"
+	"github.com/databotic/traefik-migration-tool/internal/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var updateExpected = flag.Bool("update_expected", false, "Update expected files in testdata")
+
+func TestImport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/rawdata" {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeFile(w, r, filepath.Join("fixtures", "rawdata.json"))
+	}))
+	defer server.Close()
+
+	data, err := Fetch(server.Client(), server.URL)
+	require.NoError(t, err)
+
+	r := report.New()
+	config, inventory := NewImporter(r).Import(data)
+
+	converted := map[string]string{}
+	for _, item := range inventory {
+		converted[item.Kind+" "+item.Name+"@"+item.Provider] = item.Converted
+	}
+	assert.Equal(t, map[string]string{
+		"router api@docker":                         "api-docker",
+		"router api@file":                           "api-file",
+		"router broken@docker":                      "",
+		"router dashboard@internal":                 "",
+		"router whoami-default-route@kubernetescrd": "",
+		"middleware auth@file":                      "auth",
+		"middleware dashboard_redirect@internal":    "",
+		"middleware office@docker":                  "office",
+		"service api@docker":                        "api-docker",
+		"service api@file":                          "api-file",
+		"service dashboard@internal":                "",
+		"tcp router db@file":                        "db",
+		"tcp service db@file":                       "db",
+	}, converted)
+
+	c, err := fileprovider.New(r)
+	require.NoError(t, err)
+
+	v3Config, err := c.Transform(config)
+	require.NoError(t, err)
+
+	out, err := fileprovider.Encode(v3Config, fileprovider.FormatYAML)
+	require.NoError(t, err)
+
+	fixtureFile := filepath.Join("fixtures", "rawdata.yaml")
+	if *updateExpected {
+		require.NoError(t, os.WriteFile(fixtureFile, out, 0o666))
+	}
+
+	expected, err := os.ReadFile(fixtureFile)
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), string(out))
+}
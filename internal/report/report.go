@@ -0,0 +1,52 @@
+package report
+
+import (
+	"fmt"
+	"io"
+)
+
+// Entry is a single note about a converted object that needs attention.
+type Entry struct {
+	Source  string
+	Message string
+}
+
+// Report collects the notes emitted while converting resources.
+// A nil *Report discards everything added to it.
+type Report struct {
+	entries []Entry
+}
+
+func New() *Report {
+	return &Report{}
+}
+
+func (r *Report) Add(source, format string, args ...interface{}) {
+	if r == nil {
+		return
+	}
+	r.entries = append(r.entries, Entry{Source: source, Message: fmt.Sprintf(format, args...)})
+}
+
+func (r *Report) Entries() []Entry {
+	if r == nil {
+		return nil
+	}
+	return r.entries
+}
+
+func (r *Report) Len() int {
+	return len(r.Entries())
+}
+
+func (r *Report) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	for _, e := range r.Entries() {
+		n, err := fmt.Fprintf(w, "%s: %s\n", e.Source, e.Message)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
@@ -0,0 +1,597 @@
+// Package staticconfig converts Traefik 1.7 static configurations, the
+// traefik.toml files, to v3 static configurations, and the tracing, metrics
+// and removed providers of v2 static configurations.
+package staticconfig
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/databotic/traefik-migration-tool/internal/report"
+)
+
+const (
+	source = "static configuration"
+
+	// certResolver is the name of the certificates resolver generated from
+	// the acme section.
+	certResolver = "default"
+)
+
+// v1Keys are top level keys only found in Traefik 1.7 static configurations.
+var v1Keys = []string{"defaultEntryPoints", "traefikLog", "logLevel", "acme", "kubernetes", "docker", "web", "marathon", "rancher"}
+
+// removedProviders are the providers of 1.7 and v2 which v3 removed, with
+// what replaces them.
+var removedProviders = map[string]string{
+	"marathon": "the Marathon provider was removed in v3, the Traefik labels of the applications can be converted " +
+		"with convert-marathon to a file provider configuration or Docker labels",
+	"rancher": "the Rancher v1 provider was removed in v3, Rancher v2 workloads must be served by the kubernetesCRD or " +
+		"kubernetesIngress provider and containers by the docker provider",
+}
+
+// Converter converts Traefik 1.7 and v2 static configurations to v3.
+type Converter struct {
+	report *report.Report
+}
+
+func New(r *report.Report) *Converter {
+	return &Converter{report: r}
+}
+
+// DetectV1 reports whether content is a Traefik 1.7 traefik.toml file.
+func DetectV1(content []byte) bool {
+	var raw map[string]interface{}
+	if err := toml.Unmarshal(content, &raw); err != nil {
+		return false
+	}
+
+	for key, value := range raw {
+		if contains(v1Keys, key) {
+			return true
+		}
+		// v1 entry points are configured with their redirection and TLS
+		// settings, v2 ones have none of them.
+		if key != "entryPoints" {
+			continue
+		}
+		entryPoints, _ := value.(map[string]interface{})
+		for _, e := range entryPoints {
+			entryPoint, _ := e.(map[string]interface{})
+			for _, k := range []string{"redirect", "tls", "compress", "auth", "whitelist"} {
+				if _, ok := entryPoint[k]; ok {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Convert converts a Traefik 1.7 traefik.toml to a v3 static configuration,
+// written in TOML as well.
+func (c *Converter) Convert(content []byte) ([]byte, error) {
+	config := &v1Configuration{}
+	meta, err := toml.Decode(string(content), config)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing static configuration: %v", err)
+	}
+
+	var undecoded []string
+	for _, key := range meta.Undecoded() {
+		name := key.String()
+		// the tracing section is decoded as a map, its options are
+		// reported by the tracing conversion.
+		if strings.HasPrefix(name, "tracing.") {
+			continue
+		}
+		if len(undecoded) > 0 && strings.HasPrefix(name, undecoded[len(undecoded)-1]+".") {
+			continue
+		}
+		undecoded = append(undecoded, name)
+	}
+	for _, key := range undecoded {
+		if guidance, ok := removedProviders[strings.ToLower(key)]; ok {
+			c.report.Add(key+" provider", guidance)
+			continue
+		}
+		c.report.Add(source, "option %s has no v3 equivalent and was dropped", key)
+	}
+
+	converted, err := c.transform(config)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	if err := toml.NewEncoder(buf).Encode(converted); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// transform builds the v3 static configuration of a v1 one. It is built as a
+// map rather than a static.Configuration, which would lose the options
+// explicitly set to false, such as exposedByDefault.
+func (c *Converter) transform(config *v1Configuration) (map[string]interface{}, error) {
+	out := options{}
+
+	global := options{}
+	global.set("checkNewVersion", config.CheckNewVersion)
+	global.set("sendAnonymousUsage", config.SendAnonymousUsage)
+	out.setOptions("global", global)
+
+	out.setOptions("serversTransport", c.serversTransport(config))
+
+	entryPoints, err := c.entryPoints(config)
+	if err != nil {
+		return nil, err
+	}
+	out.setOptions("entryPoints", entryPoints)
+
+	providers := c.providers(config)
+	if config.ProvidersThrottleDuration != nil {
+		providers.set("providersThrottleDuration", duration(config.ProvidersThrottleDuration))
+	}
+	out.setOptions("providers", providers)
+
+	if api := config.API; api != nil {
+		section := options{"dashboard": api.Dashboard == nil || *api.Dashboard}
+		if api.EntryPoint == "" || api.EntryPoint == "traefik" {
+			section["insecure"] = true
+		} else {
+			c.report.Add(source, "the api is served on entry point %s, a router on api@internal must be defined for it", api.EntryPoint)
+		}
+		section.set("debug", api.Debug)
+		out.enable("api", section)
+	}
+
+	if ping := config.Ping; ping != nil {
+		section := options{}
+		section.set("entryPoint", ping.EntryPoint)
+		out.enable("ping", section)
+	}
+
+	out.setOptions("metrics", c.metrics(config.Metrics))
+
+	if tracing := config.Tracing; tracing != nil {
+		if stringValue(tracing, "backend") == "" {
+			tracing["backend"] = "jaeger"
+		}
+		out.enable("tracing", c.tracing(tracing))
+	}
+
+	log := options{}
+	log.set("level", strings.ToUpper(config.LogLevel))
+	if config.Debug {
+		log["level"] = "DEBUG"
+	}
+	if traefikLog := config.TraefikLog; traefikLog != nil {
+		log.set("filePath", traefikLog.FilePath)
+		log.set("format", traefikLog.Format)
+	}
+	out.setOptions("log", log)
+
+	if accessLog := config.AccessLog; accessLog != nil {
+		section := options{}
+		section.set("filePath", accessLog.FilePath)
+		section.set("format", accessLog.Format)
+		section.set("bufferingSize", accessLog.BufferingSize)
+		if filters := accessLog.Filters; filters != nil {
+			f := section.child("filters")
+			f.set("statusCodes", filters.StatusCodes)
+			f.set("retryAttempts", filters.RetryAttempts)
+			if filters.MinDuration != nil {
+				f.set("minDuration", duration(filters.MinDuration))
+			}
+		}
+		section.setOptions("fields", accessLog.Fields)
+		out.enable("accessLog", section)
+	}
+
+	if acme := config.ACME; acme != nil {
+		resolver := options{}
+		resolver.enable("acme", c.acme(acme))
+		out["certificatesResolvers"] = options{certResolver: resolver}
+	}
+
+	return out.prune(), nil
+}
+
+func (c *Converter) serversTransport(config *v1Configuration) options {
+	transport := options{}
+	transport.set("insecureSkipVerify", config.InsecureSkipVerify)
+	transport.set("rootCAs", config.RootCAs)
+	if config.MaxIdleConnsPerHost != nil {
+		transport["maxIdleConnsPerHost"] = *config.MaxIdleConnsPerHost
+	}
+	if timeouts := config.ForwardingTimeouts; timeouts != nil {
+		t := transport.child("forwardingTimeouts")
+		if timeouts.DialTimeout != nil {
+			t.set("dialTimeout", duration(timeouts.DialTimeout))
+		}
+		if timeouts.ResponseHeaderTimeout != nil {
+			t.set("responseHeaderTimeout", duration(timeouts.ResponseHeaderTimeout))
+		}
+	}
+	return transport
+}
+
+func (c *Converter) entryPoints(config *v1Configuration) (options, error) {
+	entryPoints := options{}
+
+	for _, name := range sortedKeys(config.EntryPoints) {
+		entryPoint := config.EntryPoints[name]
+		prefix := "entry point " + name
+		section := options{"address": entryPoint.Address}
+
+		if contains(config.DefaultEntryPoints, name) {
+			section["asDefault"] = true
+		}
+
+		if redirect := entryPoint.Redirect; redirect != nil {
+			switch {
+			case redirect.EntryPoint != "":
+				to, ok := config.EntryPoints[redirect.EntryPoint]
+				if !ok {
+					return nil, fmt.Errorf("%s redirects to unknown entry point %s", prefix, redirect.EntryPoint)
+				}
+				scheme := "http"
+				if to.TLS != nil {
+					scheme = "https"
+				}
+				section.child("http").child("redirections")["entryPoint"] = map[string]interface{}{
+					"to":        redirect.EntryPoint,
+					"scheme":    scheme,
+					"permanent": redirect.Permanent,
+				}
+			default:
+				c.report.Add(prefix, "redirection by regex must be done with a redirectRegex middleware listed in http.middlewares")
+			}
+		}
+
+		if tls := entryPoint.TLS; tls != nil {
+			section.child("http").enable("tls", c.entryPointTLS(prefix, name, tls, config.ACME))
+		}
+
+		if p := entryPoint.ProxyProtocol; p != nil {
+			section.enable("proxyProtocol", trustedIPs(p))
+		}
+		if f := entryPoint.ForwardedHeaders; f != nil {
+			section.enable("forwardedHeaders", trustedIPs(f))
+		}
+
+		transport := section.child("transport")
+		if timeouts := config.RespondingTimeouts; timeouts != nil {
+			t := transport.child("respondingTimeouts")
+			for key, value := range map[string]interface{}{"readTimeout": timeouts.ReadTimeout, "writeTimeout": timeouts.WriteTimeout, "idleTimeout": timeouts.IdleTimeout} {
+				if value != nil {
+					t[key] = duration(value)
+				}
+			}
+		}
+		if lifeCycle := config.LifeCycle; lifeCycle != nil {
+			l := transport.child("lifeCycle")
+			if lifeCycle.RequestAcceptGraceTimeout != nil {
+				l["requestAcceptGraceTimeout"] = duration(lifeCycle.RequestAcceptGraceTimeout)
+			}
+			if lifeCycle.GraceTimeOut != nil {
+				l["graceTimeOut"] = duration(lifeCycle.GraceTimeOut)
+			}
+		}
+
+		if entryPoint.Compress {
+			c.report.Add(prefix, "compression must be done with a compress middleware listed in http.middlewares")
+		}
+		if entryPoint.Auth != nil {
+			c.report.Add(prefix, "authentication must be done with a basicAuth, digestAuth or forwardAuth middleware listed in http.middlewares")
+		}
+		if entryPoint.WhiteList != nil {
+			c.report.Add(prefix, "the whitelist must be replaced by an ipAllowList middleware listed in http.middlewares")
+		}
+
+		entryPoints[name] = section
+	}
+
+	for _, name := range config.DefaultEntryPoints {
+		if _, ok := config.EntryPoints[name]; !ok {
+			return nil, fmt.Errorf("unknown default entry point %s", name)
+		}
+	}
+	return entryPoints, nil
+}
+
+// entryPointTLS returns the default TLS configuration of the routers of an
+// entry point. Certificates and TLS options are part of the dynamic
+// configuration in v3.
+func (c *Converter) entryPointTLS(prefix, name string, tls *v1TLS, acme *v1ACME) options {
+	routerTLS := options{}
+
+	if acme != nil && (acme.EntryPoint == "" || acme.EntryPoint == name) {
+		routerTLS["certResolver"] = certResolver
+		var domains []interface{}
+		for _, domain := range acme.Domains {
+			d := options{"main": domain.Main}
+			d.set("sans", domain.SANs)
+			domains = append(domains, d)
+		}
+		if len(domains) > 0 {
+			routerTLS["domains"] = domains
+		}
+	}
+
+	if len(tls.Certificates) > 0 {
+		c.report.Add(prefix, "certificates must be moved to the tls.certificates section of the dynamic configuration")
+	}
+	if tls.MinVersion != "" || len(tls.CipherSuites) > 0 || tls.SNIStrict || tls.ClientCA != nil {
+		c.report.Add(prefix, "TLS settings must be moved to the tls.options.default section of the dynamic configuration")
+	}
+	return routerTLS
+}
+
+func (c *Converter) providers(config *v1Configuration) options {
+	providers := options{}
+
+	if k := config.Kubernetes; k != nil {
+		kubernetes := options{}
+		kubernetes.set("endpoint", k.Endpoint)
+		kubernetes.set("token", k.Token)
+		kubernetes.set("certAuthFilePath", k.CertAuthFilePath)
+		kubernetes.set("namespaces", k.Namespaces)
+		kubernetes.set("labelSelector", k.LabelSelector)
+		kubernetes.set("ingressClass", k.IngressClass)
+		if k.ThrottleDuration != nil {
+			kubernetes.set("throttleDuration", duration(k.ThrottleDuration))
+		}
+
+		// the Ingresses converted with their v1 annotations are served by the
+		// kubernetesCRD provider, the other ones by kubernetesIngress.
+		crd := options{}
+		for key, value := range kubernetes {
+			crd[key] = value
+		}
+		providers.enable("kubernetesCRD", crd)
+
+		if endpoint := k.IngressEndpoint; endpoint != nil {
+			e := kubernetes.child("ingressEndpoint")
+			e.set("ip", endpoint.IP)
+			e.set("hostname", endpoint.Hostname)
+			e.set("publishedService", endpoint.PublishedService)
+		}
+		providers.enable("kubernetesIngress", kubernetes)
+
+		if k.DisablePassHostHeaders {
+			c.report.Add("kubernetes provider", "disablePassHostHeaders must be replaced by the preserve-host annotation, converted to the passHostHeader option of the services")
+		}
+		if k.EnablePassTLSCert {
+			c.report.Add("kubernetes provider", "enablePassTLSCert must be replaced by a passTLSClientCert middleware")
+		}
+	}
+
+	if f := config.File; f != nil {
+		file := options{}
+		file.set("filename", f.Filename)
+		file.set("directory", f.Directory)
+		if f.Watch != nil {
+			file["watch"] = *f.Watch
+		}
+		if f.Filename == "" && f.Directory == "" {
+			c.report.Add("file provider", "routes defined in the static configuration must be moved to a dynamic configuration file")
+		}
+		providers.enable("file", file)
+	}
+
+	if d := config.Docker; d != nil {
+		docker := options{}
+		docker.set("endpoint", d.Endpoint)
+		if d.ExposedByDefault != nil {
+			docker["exposedByDefault"] = *d.ExposedByDefault
+		}
+		docker.set("network", d.Network)
+		if d.Domain != "" {
+			docker["defaultRule"] = "Host(`{{ normalize .Name }}." + d.Domain + "`)"
+		}
+		if tls := d.TLS; tls != nil {
+			t := docker.child("tls")
+			t.set("ca", tls.CA)
+			t.set("cert", tls.Cert)
+			t.set("key", tls.Key)
+			t.set("insecureSkipVerify", tls.InsecureSkipVerify)
+		}
+
+		if d.SwarmMode {
+			if d.SwarmModeRefreshSeconds != nil {
+				docker["refreshSeconds"] = duration(d.SwarmModeRefreshSeconds)
+			}
+			providers.enable("swarm", docker)
+		} else {
+			if d.Watch != nil {
+				docker["watch"] = *d.Watch
+			}
+			docker.set("useBindPortIP", d.UseBindPortIP)
+			providers.enable("docker", docker)
+		}
+		c.report.Add("docker provider", "the traefik.frontend and traefik.backend labels of the containers must be converted to router and service labels")
+	}
+
+	return providers
+}
+
+func (c *Converter) metrics(metrics *v1Metrics) options {
+	if metrics == nil {
+		return nil
+	}
+
+	out := options{}
+	if p := metrics.Prometheus; p != nil {
+		// v1 labelled the metrics with the entry points and backends.
+		prometheus := options{"addEntryPointsLabels": true, "addServicesLabels": true}
+		prometheus.set("entryPoint", p.EntryPoint)
+		prometheus.set("buckets", p.Buckets)
+		out.enable("prometheus", prometheus)
+	}
+	for key, exporter := range map[string]*v1PushMetrics{"datadog": metrics.Datadog, "statsD": metrics.StatsD} {
+		if exporter == nil {
+			continue
+		}
+		section := options{}
+		section.set("address", exporter.Address)
+		if exporter.PushInterval != nil {
+			section.set("pushInterval", duration(exporter.PushInterval))
+		}
+		out.enable(key, section)
+	}
+	if metrics.InfluxDB != nil {
+		c.report.Add("metrics", "InfluxDB v1 is not supported by v3, metrics must be pushed to InfluxDB v2 with the influxDB2 exporter")
+	}
+	return out
+}
+
+func (c *Converter) acme(acme *v1ACME) options {
+	out := options{}
+	out.set("email", acme.Email)
+	out.set("storage", acme.Storage)
+	out.set("caServer", acme.CAServer)
+	out.set("keyType", acme.KeyType)
+
+	if acme.Storage != "" && !strings.HasSuffix(acme.Storage, ".json") {
+		c.report.Add("acme", "certificates stored in a key-value store must be exported to a JSON file, storage %s was kept as a file path", acme.Storage)
+	}
+
+	switch {
+	case acme.DNSChallenge != nil:
+		dns := out.child("dnsChallenge")
+		dns.set("provider", acme.DNSChallenge.Provider)
+		if acme.DNSChallenge.DelayBeforeCheck != nil {
+			dns.set("delayBeforeCheck", duration(acme.DNSChallenge.DelayBeforeCheck))
+		}
+		dns.set("resolvers", acme.DNSChallenge.Resolvers)
+		dns.set("disablePropagationCheck", acme.DNSChallenge.DisablePropagationCheck)
+	case acme.TLSChallenge != nil:
+		out.enable("tlsChallenge", options{})
+	case acme.HTTPChallenge != nil:
+		out.enable("httpChallenge", options{"entryPoint": acme.HTTPChallenge.EntryPoint})
+	}
+
+	if acme.OnDemand {
+		c.report.Add("acme", "onDemand certificates are not supported by v3, the domains must be listed on the routers")
+	}
+	if acme.ACMELogging {
+		c.report.Add("acme", "acmeLogging was removed, ACME logs are written at the DEBUG level")
+	}
+	return out
+}
+
+func trustedIPs(t *v1TrustedIPs) options {
+	section := options{}
+	section.set("trustedIPs", t.TrustedIPs)
+	section.set("insecure", t.Insecure)
+	return section
+}
+
+// duration returns a v1 duration, given in seconds when it is a number, as
+// a duration string.
+func duration(value interface{}) interface{} {
+	if seconds, ok := value.(int64); ok {
+		return fmt.Sprintf("%ds", seconds)
+	}
+	return value
+}
+
+// options are the options of a section of the v3 static configuration.
+type options map[string]interface{}
+
+// set sets key to value, unless value is the zero value of its type.
+func (o options) set(key string, value interface{}) {
+	switch v := value.(type) {
+	case nil:
+		return
+	case string:
+		if v == "" {
+			return
+		}
+	case bool:
+		if !v {
+			return
+		}
+	case int64:
+		if v == 0 {
+			return
+		}
+	case []string:
+		if len(v) == 0 {
+			return
+		}
+	case []float64:
+		if len(v) == 0 {
+			return
+		}
+	case *bool:
+		if v == nil {
+			return
+		}
+		value = *v
+	}
+	o[key] = value
+}
+
+func (o options) setOptions(key string, value map[string]interface{}) {
+	if len(value) > 0 {
+		o[key] = value
+	}
+}
+
+// enable sets the section key, which is kept even when it is empty as it
+// enables a feature, such as a provider.
+func (o options) enable(key string, section options) {
+	o[key] = map[string]interface{}(section)
+}
+
+// child returns the options of the section key, creating it if needed.
+func (o options) child(key string) options {
+	if child, ok := o[key].(options); ok {
+		return child
+	}
+	child := options{}
+	o[key] = child
+	return child
+}
+
+// prune removes the sections created by child which were left empty, the
+// ones set by enable are kept.
+func (o options) prune() map[string]interface{} {
+	for key, value := range o {
+		switch section := value.(type) {
+		case options:
+			if pruned := section.prune(); len(pruned) > 0 {
+				o[key] = pruned
+			} else {
+				delete(o, key)
+			}
+		case map[string]interface{}:
+			options(section).prune()
+		}
+	}
+	return o
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,115 @@
+package staticconfig
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/databotic/traefik-migration-tool/internal/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var updateExpected = flag.Bool("update_expected", false, "Update expected files in testdata")
+
+func TestConvert(t *testing.T) {
+	src, err := os.ReadFile(filepath.Join("fixtures", "input", "traefik.toml"))
+	require.NoError(t, err)
+	require.True(t, DetectV1(src))
+
+	r := report.New()
+	converted, err := New(r).Convert(src)
+	require.NoError(t, err)
+
+	fixtureFile := filepath.Join("fixtures", "output", "traefik.toml")
+	if *updateExpected {
+		require.NoError(t, os.WriteFile(fixtureFile, converted, 0o666))
+	}
+
+	expected, err := os.ReadFile(fixtureFile)
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), string(converted))
+
+	expectedEntries := []report.Entry{
+		{Source: "rancher provider", Message: "the Rancher v1 provider was removed in v3, Rancher v2 workloads must be served by " +
+			"the kubernetesCRD or kubernetesIngress provider and containers by the docker provider"},
+		{Source: source, Message: "option retry has no v3 equivalent and was dropped"},
+		{Source: "entry point http", Message: "compression must be done with a compress middleware listed in http.middlewares"},
+		{Source: "entry point https", Message: "TLS settings must be moved to the tls.options.default section of the dynamic configuration"},
+		{Source: "docker provider", Message: "the traefik.frontend and traefik.backend labels of the containers must be converted to router and service labels"},
+		{Source: "tracing", Message: "zipkin doesn't receive OTLP, an OpenTelemetry Collector exporting to http://zipkin.monitoring:9411/api/v2/spans must listen on http://zipkin.monitoring:4318/v1/traces"},
+		{Source: "tracing", Message: "zipkin B3 propagation is lost, v3 propagates the W3C traceparent and baggage headers"},
+	}
+	assert.Equal(t, expectedEntries, r.Entries())
+}
+
+func TestDetectV1(t *testing.T) {
+	assert.False(t, DetectV1([]byte("[entryPoints.web]\n  address = \":80\"\n")))
+	assert.True(t, DetectV1([]byte("[entryPoints.web]\n  address = \":80\"\n  [entryPoints.web.redirect]\n  entryPoint = \"websecure\"\n")))
+	assert.True(t, DetectV1([]byte("[marathon]\n  endpoint = \"http://marathon.mesos:8080\"\n")))
+	assert.False(t, DetectV1([]byte("apiVersion: v1\nkind: Service\n")))
+}
+
+func TestConvertV2(t *testing.T) {
+	testCases := []struct {
+		file     string
+		expected []report.Entry
+	}{
+		{
+			file: "traefik_v2.yaml",
+			expected: []report.Entry{
+				{Source: "metrics", Message: "InfluxDB v1 is not supported by v3, metrics must be pushed to InfluxDB v2 with the influxDB2 exporter"},
+				{Source: "tracing", Message: "spanNameLimit was removed, span names are no longer truncated"},
+				{Source: "tracing", Message: "jaeger remote sampling from http://jaeger-agent.monitoring:5778/sampling is lost, the sample rate is set in tracing.sampleRate"},
+				{Source: "tracing", Message: "jaeger collector must receive OTLP over HTTP on http://jaeger-collector.monitoring:4318/v1/traces, which requires Jaeger 1.35 or later"},
+				{Source: "tracing", Message: "jaeger propagation format b3 is lost, v3 propagates the W3C traceparent and baggage headers"},
+				{Source: "tracing", Message: "jaeger trace context header x-trace-id is lost"},
+			},
+		},
+		{
+			file: "traefik_v2.toml",
+			expected: []report.Entry{
+				{Source: "marathon provider", Message: "the Marathon provider was removed in v3, the Traefik labels of the applications " +
+					"can be converted with convert-marathon to a file provider configuration or Docker labels"},
+				{Source: "tracing", Message: "datadog agent must have OTLP ingestion over gRPC enabled on datadog-agent:4317"},
+				{Source: "tracing", Message: "datadog option prioritySampling has no v3 equivalent and was dropped"},
+				{Source: "tracing", Message: "datadog propagation headers x-datadog-trace-id, x-datadog-parent-id are lost, v3 propagates the W3C traceparent and baggage headers"},
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.file, func(t *testing.T) {
+			src, err := os.ReadFile(filepath.Join("fixtures", "input", test.file))
+			require.NoError(t, err)
+			require.True(t, DetectV2(src, filepath.Ext(test.file)))
+
+			r := report.New()
+			converted, err := New(r).ConvertV2(src, filepath.Ext(test.file))
+			require.NoError(t, err)
+
+			fixtureFile := filepath.Join("fixtures", "output", test.file)
+			if *updateExpected {
+				require.NoError(t, os.WriteFile(fixtureFile, converted, 0o666))
+			}
+
+			expected, err := os.ReadFile(fixtureFile)
+			require.NoError(t, err)
+			assert.Equal(t, string(expected), string(converted))
+			assert.Equal(t, test.expected, r.Entries())
+		})
+	}
+}
+
+func TestDetectV2(t *testing.T) {
+	assert.True(t, DetectV2([]byte("entryPoints:\n  web:\n    address: :80\n"), ".yaml"))
+	assert.False(t, DetectV2([]byte("http:\n  routers: {}\n"), ".yaml"))
+	assert.False(t, DetectV2([]byte("apiVersion: v1\nkind: Service\n"), ""))
+	assert.False(t, DetectV2([]byte("logLevel = \"INFO\"\n[entryPoints.web]\n  address = \":80\"\n"), ".toml"))
+
+	src := []byte("entryPoints:\n  web:\n    address: :80\n")
+	converted, err := New(report.New()).ConvertV2(src, ".yaml")
+	require.NoError(t, err)
+	assert.Equal(t, string(src), string(converted))
+}
@@ -0,0 +1,327 @@
+package staticconfig
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// tracingBackends are the tracing backends of v1 and v2, replaced by
+// OpenTelemetry in v3.
+var tracingBackends = []string{"jaeger", "zipkin", "datadog", "instana", "haystack", "elastic", "openTelemetry"}
+
+// tracing converts a v1 or v2 tracing section to the v3 one, which exports
+// the spans with OTLP. The backends are expected to receive OTLP next to
+// their former address, the lost behaviours are reported.
+func (c *Converter) tracing(tracing map[string]interface{}) options {
+	out := options{}
+	out.set("serviceName", stringValue(tracing, "serviceName"))
+	if _, ok := lookup(tracing, "spanNameLimit"); ok {
+		c.report.Add("tracing", "spanNameLimit was removed, span names are no longer truncated")
+	}
+
+	// v1 selects the backend with the backend option, whose section may be
+	// left out for its defaults, v2 by its section.
+	backend := stringValue(tracing, "backend")
+	for _, name := range tracingBackends {
+		section, ok := sectionValue(tracing, name)
+		if backend != "" && !strings.EqualFold(backend, name) {
+			continue
+		}
+		if !ok && backend == "" {
+			continue
+		}
+		if !ok {
+			section = map[string]interface{}{}
+		}
+
+		switch name {
+		case "jaeger":
+			c.jaeger(out, section)
+		case "zipkin":
+			c.zipkin(out, section)
+		case "datadog":
+			c.datadog(out, section)
+		case "instana":
+			c.instana(out, section)
+		case "haystack":
+			c.haystack(out, section)
+		case "elastic":
+			c.elastic(out, section)
+		case "openTelemetry":
+			otlp(out.child("otlp"), section, "/v1/traces")
+		}
+		return out
+	}
+	return out
+}
+
+func (c *Converter) jaeger(out options, jaeger map[string]interface{}) {
+	samplingType, param := stringValue(jaeger, "samplingType"), 1.0
+	if value, ok := floatValue(jaeger, "samplingParam"); ok {
+		param = value
+	}
+	switch strings.ToLower(samplingType) {
+	case "", "const":
+		if param < 1 {
+			out["sampleRate"] = 0.0
+		}
+	case "probabilistic":
+		out["sampleRate"] = param
+	default:
+		c.report.Add("tracing", "jaeger %s sampling of %v has no v3 equivalent, all the requests are sampled", samplingType, param)
+	}
+	if server := stringValue(jaeger, "samplingServerURL"); server != "" {
+		c.report.Add("tracing", "jaeger remote sampling from %s is lost, the sample rate is set in tracing.sampleRate", server)
+	}
+
+	if collector, ok := sectionValue(jaeger, "collector"); ok && stringValue(collector, "endpoint") != "" {
+		endpoint := otlpURL(stringValue(collector, "endpoint"), "4318", "/v1/traces")
+		http := out.child("otlp").child("http")
+		http.set("endpoint", endpoint)
+		if user := stringValue(collector, "user"); user != "" {
+			credentials := base64.StdEncoding.EncodeToString([]byte(user + ":" + stringValue(collector, "password")))
+			http.setOptions("headers", map[string]interface{}{"Authorization": "Basic " + credentials})
+		}
+		c.report.Add("tracing", "jaeger collector must receive OTLP over HTTP on %s, which requires Jaeger 1.35 or later", endpoint)
+	} else {
+		address := stringValue(jaeger, "localAgentHostPort")
+		if address == "" {
+			address = "127.0.0.1:6831"
+		}
+		endpoint := otlpAddress(address, "4317")
+		out.child("otlp").child("grpc").set("endpoint", endpoint)
+		out.child("otlp").child("grpc").set("insecure", true)
+		c.report.Add("tracing", "jaeger agent %s is replaced by OTLP over gRPC on %s, which requires Jaeger 1.35 or later", address, endpoint)
+	}
+
+	propagation := stringValue(jaeger, "propagation")
+	if propagation == "" {
+		propagation = "jaeger"
+	}
+	c.report.Add("tracing", "jaeger propagation format %s is lost, v3 propagates the W3C traceparent and baggage headers", propagation)
+	if header := stringValue(jaeger, "traceContextHeaderName"); header != "" {
+		c.report.Add("tracing", "jaeger trace context header %s is lost", header)
+	}
+	for _, key := range []string{"gen128Bit", "disableAttemptReconnecting"} {
+		if _, ok := lookup(jaeger, key); ok {
+			c.report.Add("tracing", "jaeger option %s has no v3 equivalent and was dropped", key)
+		}
+	}
+}
+
+func (c *Converter) zipkin(out options, zipkin map[string]interface{}) {
+	if rate, ok := floatValue(zipkin, "sampleRate"); ok {
+		out["sampleRate"] = rate
+	}
+
+	address := stringValue(zipkin, "httpEndpoint")
+	if address == "" {
+		address = "http://localhost:9411/api/v2/spans"
+	}
+	endpoint := otlpURL(address, "4318", "/v1/traces")
+	out.child("otlp").child("http").set("endpoint", endpoint)
+	c.report.Add("tracing", "zipkin doesn't receive OTLP, an OpenTelemetry Collector exporting to %s must listen on %s", address, endpoint)
+
+	for _, key := range []string{"sameSpan", "id128Bit", "debug"} {
+		if _, ok := lookup(zipkin, key); ok {
+			c.report.Add("tracing", "zipkin option %s has no v3 equivalent and was dropped", key)
+		}
+	}
+	c.report.Add("tracing", "zipkin B3 propagation is lost, v3 propagates the W3C traceparent and baggage headers")
+}
+
+func (c *Converter) datadog(out options, datadog map[string]interface{}) {
+	if socket := stringValue(datadog, "localAgentSocket"); socket != "" {
+		c.report.Add("tracing", "datadog agent socket %s is lost, the agent must receive OTLP over gRPC", socket)
+	}
+	address := stringValue(datadog, "localAgentHostPort")
+	if address == "" {
+		address = "localhost:8126"
+	}
+	endpoint := otlpAddress(address, "4317")
+	out.child("otlp").child("grpc").set("endpoint", endpoint)
+	out.child("otlp").child("grpc").set("insecure", true)
+	c.report.Add("tracing", "datadog agent must have OTLP ingestion over gRPC enabled on %s", endpoint)
+
+	attributes := globalTags(datadog)
+	if tags, ok := sectionValue(datadog, "globalTags"); ok {
+		for key, value := range tags {
+			attributes[key] = fmt.Sprint(value)
+		}
+	}
+	out.setOptions("globalAttributes", attributes)
+
+	for _, key := range []string{"debug", "prioritySampling"} {
+		if _, ok := lookup(datadog, key); ok {
+			c.report.Add("tracing", "datadog option %s has no v3 equivalent and was dropped", key)
+		}
+	}
+	c.reportHeaders("datadog", datadog, "traceIDHeaderName", "parentIDHeaderName", "samplingPriorityHeaderName", "bagagePrefixHeaderName")
+}
+
+func (c *Converter) instana(out options, instana map[string]interface{}) {
+	host := stringValue(instana, "localAgentHost")
+	if host == "" {
+		host = "localhost"
+	}
+	endpoint := net.JoinHostPort(host, "4317")
+	out.child("otlp").child("grpc").set("endpoint", endpoint)
+	out.child("otlp").child("grpc").set("insecure", true)
+	c.report.Add("tracing", "instana agent must have OTLP ingestion over gRPC enabled on %s", endpoint)
+
+	for _, key := range []string{"logLevel", "enableAutoProfile"} {
+		if _, ok := lookup(instana, key); ok {
+			c.report.Add("tracing", "instana option %s has no v3 equivalent and was dropped", key)
+		}
+	}
+}
+
+func (c *Converter) haystack(out options, haystack map[string]interface{}) {
+	host := stringValue(haystack, "localAgentHost")
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	endpoint := net.JoinHostPort(host, "4317")
+	out.child("otlp").child("grpc").set("endpoint", endpoint)
+	out.child("otlp").child("grpc").set("insecure", true)
+	c.report.Add("tracing", "haystack doesn't receive OTLP, an OpenTelemetry Collector exporting to the haystack agent must listen on %s", endpoint)
+
+	out.setOptions("globalAttributes", globalTags(haystack))
+	c.reportHeaders("haystack", haystack, "traceIDHeaderName", "parentIDHeaderName", "spanIDHeaderName", "baggagePrefixHeaderName")
+}
+
+func (c *Converter) elastic(out options, elastic map[string]interface{}) {
+	address := stringValue(elastic, "serverURL")
+	if address == "" {
+		address = "http://localhost:8200"
+	}
+	http := out.child("otlp").child("http")
+	http.set("endpoint", strings.TrimSuffix(address, "/")+"/v1/traces")
+	if token := stringValue(elastic, "secretToken"); token != "" {
+		http.setOptions("headers", map[string]interface{}{"Authorization": "Bearer " + token})
+	}
+
+	if environment := stringValue(elastic, "serviceEnvironment"); environment != "" {
+		out.setOptions("globalAttributes", map[string]interface{}{"deployment.environment": environment})
+	}
+	c.report.Add("tracing", "elastic APM propagation is lost, v3 propagates the W3C traceparent and baggage headers")
+}
+
+// reportHeaders reports the propagation headers of a backend, which v3
+// doesn't read or write.
+func (c *Converter) reportHeaders(backend string, section map[string]interface{}, keys ...string) {
+	var headers []string
+	for _, key := range keys {
+		if header := stringValue(section, key); header != "" {
+			headers = append(headers, header)
+		}
+	}
+	if len(headers) > 0 {
+		c.report.Add("tracing", "%s propagation headers %s are lost, v3 propagates the W3C traceparent and baggage headers", backend, strings.Join(headers, ", "))
+	} else {
+		c.report.Add("tracing", "%s propagation is lost, v3 propagates the W3C traceparent and baggage headers", backend)
+	}
+}
+
+// otlp converts a v2 openTelemetry section, of tracing or metrics, to an
+// otlp one. v2 sends over HTTP unless grpc is set.
+func otlp(out options, openTelemetry map[string]interface{}, path string) {
+	address := stringValue(openTelemetry, "address")
+	if address == "" {
+		address = "localhost:4318"
+	}
+	insecure, _ := lookup(openTelemetry, "insecure")
+
+	var section options
+	if _, ok := lookup(openTelemetry, "grpc"); ok {
+		section = out.child("grpc")
+		section.set("endpoint", address)
+		section.set("insecure", insecure)
+	} else {
+		if p := stringValue(openTelemetry, "path"); p != "" {
+			path = p
+		}
+		scheme := "https"
+		if insecure == true {
+			scheme = "http"
+		}
+		section = out.child("http")
+		section.set("endpoint", scheme+"://"+address+path)
+	}
+
+	if headers, ok := sectionValue(openTelemetry, "headers"); ok {
+		section.setOptions("headers", headers)
+	}
+	if tls, ok := sectionValue(openTelemetry, "tls"); ok {
+		section.setOptions("tls", tls)
+	}
+}
+
+// otlpURL returns the URL of the OTLP HTTP receiver listening on port on the
+// host of address.
+func otlpURL(address, port, path string) string {
+	u, err := url.Parse(address)
+	if err != nil || u.Host == "" {
+		return "http://" + otlpAddress(address, port) + path
+	}
+	return u.Scheme + "://" + net.JoinHostPort(u.Hostname(), port) + path
+}
+
+// otlpAddress returns the host:port of the OTLP receiver listening on port
+// on the host of address.
+func otlpAddress(address, port string) string {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// globalTags returns the attributes of the key:value globalTag option.
+func globalTags(section map[string]interface{}) map[string]interface{} {
+	attributes := map[string]interface{}{}
+	if tag := stringValue(section, "globalTag"); tag != "" {
+		key, value, _ := strings.Cut(tag, ":")
+		attributes[key] = value
+	}
+	return attributes
+}
+
+// lookup returns the value of key in section. Keys are case insensitive, as
+// in Traefik configuration files.
+func lookup(section map[string]interface{}, key string) (interface{}, bool) {
+	for k, value := range section {
+		if strings.EqualFold(k, key) {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+func stringValue(section map[string]interface{}, key string) string {
+	value, _ := lookup(section, key)
+	s, _ := value.(string)
+	return s
+}
+
+func floatValue(section map[string]interface{}, key string) (float64, bool) {
+	value, _ := lookup(section, key)
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+func sectionValue(section map[string]interface{}, key string) (map[string]interface{}, bool) {
+	value, _ := lookup(section, key)
+	s, ok := value.(map[string]interface{})
+	return s, ok
+}
@@ -0,0 +1,198 @@
+package staticconfig
+
+// v1Configuration holds the options of a Traefik 1.7 traefik.toml which
+// have a v3 equivalent, or are reported when they have none. Durations are
+// either strings or a number of seconds.
+type v1Configuration struct {
+	LogLevel                  string                   `toml:"logLevel"`
+	Debug                     bool                     `toml:"debug"`
+	CheckNewVersion           *bool                    `toml:"checkNewVersion"`
+	SendAnonymousUsage        *bool                    `toml:"sendAnonymousUsage"`
+	InsecureSkipVerify        bool                     `toml:"insecureSkipVerify"`
+	RootCAs                   []string                 `toml:"rootCAs"`
+	MaxIdleConnsPerHost       *int                     `toml:"maxIdleConnsPerHost"`
+	DefaultEntryPoints        []string                 `toml:"defaultEntryPoints"`
+	ProvidersThrottleDuration interface{}              `toml:"providersThrottleDuration"`
+	RespondingTimeouts        *v1RespondingTimeouts    `toml:"respondingTimeouts"`
+	ForwardingTimeouts        *v1ForwardingTimeouts    `toml:"forwardingTimeouts"`
+	LifeCycle                 *v1LifeCycle             `toml:"lifeCycle"`
+	EntryPoints               map[string]*v1EntryPoint `toml:"entryPoints"`
+	API                       *v1API                   `toml:"api"`
+	Ping                      *v1Ping                  `toml:"ping"`
+	Metrics                   *v1Metrics               `toml:"metrics"`
+	Tracing                   map[string]interface{}   `toml:"tracing"`
+	TraefikLog                *v1Log                   `toml:"traefikLog"`
+	AccessLog                 *v1AccessLog             `toml:"accessLog"`
+	Kubernetes                *v1Kubernetes            `toml:"kubernetes"`
+	File                      *v1File                  `toml:"file"`
+	Docker                    *v1Docker                `toml:"docker"`
+	ACME                      *v1ACME                  `toml:"acme"`
+}
+
+type v1RespondingTimeouts struct {
+	ReadTimeout  interface{} `toml:"readTimeout"`
+	WriteTimeout interface{} `toml:"writeTimeout"`
+	IdleTimeout  interface{} `toml:"idleTimeout"`
+}
+
+type v1ForwardingTimeouts struct {
+	DialTimeout           interface{} `toml:"dialTimeout"`
+	ResponseHeaderTimeout interface{} `toml:"responseHeaderTimeout"`
+}
+
+type v1LifeCycle struct {
+	RequestAcceptGraceTimeout interface{} `toml:"requestAcceptGraceTimeout"`
+	GraceTimeOut              interface{} `toml:"graceTimeOut"`
+}
+
+type v1EntryPoint struct {
+	Address          string        `toml:"address"`
+	Redirect         *v1Redirect   `toml:"redirect"`
+	TLS              *v1TLS        `toml:"tls"`
+	ProxyProtocol    *v1TrustedIPs `toml:"proxyProtocol"`
+	ForwardedHeaders *v1TrustedIPs `toml:"forwardedHeaders"`
+	Compress         bool          `toml:"compress"`
+	Auth             interface{}   `toml:"auth"`
+	WhiteList        interface{}   `toml:"whitelist"`
+}
+
+type v1Redirect struct {
+	EntryPoint  string `toml:"entryPoint"`
+	Regex       string `toml:"regex"`
+	Replacement string `toml:"replacement"`
+	Permanent   bool   `toml:"permanent"`
+}
+
+type v1TLS struct {
+	MinVersion   string        `toml:"minVersion"`
+	CipherSuites []string      `toml:"cipherSuites"`
+	SNIStrict    bool          `toml:"sniStrict"`
+	Certificates []interface{} `toml:"certificates"`
+	ClientCA     interface{}   `toml:"clientCA"`
+}
+
+type v1TrustedIPs struct {
+	TrustedIPs []string `toml:"trustedIPs"`
+	Insecure   bool     `toml:"insecure"`
+}
+
+type v1API struct {
+	EntryPoint string `toml:"entryPoint"`
+	Dashboard  *bool  `toml:"dashboard"`
+	Debug      bool   `toml:"debug"`
+}
+
+type v1Ping struct {
+	EntryPoint string `toml:"entryPoint"`
+}
+
+type v1Metrics struct {
+	Prometheus *v1Prometheus  `toml:"prometheus"`
+	Datadog    *v1PushMetrics `toml:"datadog"`
+	StatsD     *v1PushMetrics `toml:"statsd"`
+	InfluxDB   interface{}    `toml:"influxdb"`
+}
+
+type v1Prometheus struct {
+	EntryPoint string    `toml:"entryPoint"`
+	Buckets    []float64 `toml:"buckets"`
+}
+
+type v1PushMetrics struct {
+	Address      string      `toml:"address"`
+	PushInterval interface{} `toml:"pushInterval"`
+}
+
+type v1Log struct {
+	FilePath string `toml:"filePath"`
+	Format   string `toml:"format"`
+}
+
+type v1AccessLog struct {
+	FilePath      string                 `toml:"filePath"`
+	Format        string                 `toml:"format"`
+	BufferingSize int64                  `toml:"bufferingSize"`
+	Filters       *v1AccessLogFilters    `toml:"filters"`
+	Fields        map[string]interface{} `toml:"fields"`
+}
+
+type v1AccessLogFilters struct {
+	StatusCodes   []string    `toml:"statusCodes"`
+	RetryAttempts bool        `toml:"retryAttempts"`
+	MinDuration   interface{} `toml:"minDuration"`
+}
+
+type v1Kubernetes struct {
+	Endpoint               string             `toml:"endpoint"`
+	Token                  string             `toml:"token"`
+	CertAuthFilePath       string             `toml:"certAuthFilePath"`
+	Namespaces             []string           `toml:"namespaces"`
+	LabelSelector          string             `toml:"labelSelector"`
+	IngressClass           string             `toml:"ingressClass"`
+	IngressEndpoint        *v1IngressEndpoint `toml:"ingressEndpoint"`
+	ThrottleDuration       interface{}        `toml:"throttleDuration"`
+	DisablePassHostHeaders bool               `toml:"disablePassHostHeaders"`
+	EnablePassTLSCert      bool               `toml:"enablePassTLSCert"`
+}
+
+type v1IngressEndpoint struct {
+	IP               string `toml:"ip"`
+	Hostname         string `toml:"hostname"`
+	PublishedService string `toml:"publishedService"`
+}
+
+type v1File struct {
+	Filename  string `toml:"filename"`
+	Directory string `toml:"directory"`
+	Watch     *bool  `toml:"watch"`
+}
+
+type v1Docker struct {
+	Endpoint                string       `toml:"endpoint"`
+	Domain                  string       `toml:"domain"`
+	Watch                   *bool        `toml:"watch"`
+	ExposedByDefault        *bool        `toml:"exposedByDefault"`
+	UseBindPortIP           bool         `toml:"useBindPortIP"`
+	SwarmMode               bool         `toml:"swarmMode"`
+	SwarmModeRefreshSeconds interface{}  `toml:"swarmModeRefreshSeconds"`
+	Network                 string       `toml:"network"`
+	TLS                     *v1ClientTLS `toml:"tls"`
+}
+
+type v1ClientTLS struct {
+	CA                 string `toml:"ca"`
+	Cert               string `toml:"cert"`
+	Key                string `toml:"key"`
+	InsecureSkipVerify bool   `toml:"insecureSkipVerify"`
+}
+
+type v1ACME struct {
+	Email         string           `toml:"email"`
+	Storage       string           `toml:"storage"`
+	EntryPoint    string           `toml:"entryPoint"`
+	CAServer      string           `toml:"caServer"`
+	KeyType       string           `toml:"keyType"`
+	OnHostRule    bool             `toml:"onHostRule"`
+	OnDemand      bool             `toml:"onDemand"`
+	ACMELogging   bool             `toml:"acmeLogging"`
+	HTTPChallenge *v1HTTPChallenge `toml:"httpChallenge"`
+	TLSChallenge  *struct{}        `toml:"tlsChallenge"`
+	DNSChallenge  *v1DNSChallenge  `toml:"dnsChallenge"`
+	Domains       []v1Domain       `toml:"domains"`
+}
+
+type v1HTTPChallenge struct {
+	EntryPoint string `toml:"entryPoint"`
+}
+
+type v1DNSChallenge struct {
+	Provider                string      `toml:"provider"`
+	DelayBeforeCheck        interface{} `toml:"delayBeforeCheck"`
+	Resolvers               []string    `toml:"resolvers"`
+	DisablePropagationCheck bool        `toml:"disablePropagationCheck"`
+}
+
+type v1Domain struct {
+	Main string   `toml:"main"`
+	SANs []string `toml:"sans"`
+}
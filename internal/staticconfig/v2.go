@@ -0,0 +1,325 @@
+package staticconfig
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/databotic/traefik-migration-tool/internal/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// v2Keys are the top level keys of v2 static configurations.
+var v2Keys = []string{
+	"global", "serversTransport", "tcpServersTransport", "entryPoints", "providers", "api", "metrics", "ping", "log",
+	"accessLog", "tracing", "hostResolver", "certificatesResolvers", "experimental", "pilot", "spiffe",
+}
+
+// DetectV2 reports whether content is a v2 static configuration, in which
+// every top level key is a static configuration one.
+func DetectV2(content []byte, ext string) bool {
+	raw, _, err := decodeV2(content, ext)
+	if err != nil || len(raw) == 0 || DetectV1(content) {
+		return false
+	}
+
+	for key := range raw {
+		if !containsFold(v2Keys, key) {
+			return false
+		}
+	}
+	return true
+}
+
+// ConvertV2 converts the tracing and metrics sections of a v2 static
+// configuration to v3 and removes the providers v3 dropped. Only the changed
+// sections are written again, the rest of the configuration keeps its
+// comments and formatting.
+func (c *Converter) ConvertV2(content []byte, ext string) ([]byte, error) {
+	raw, isTOML, err := decodeV2(content, ext)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing static configuration: %v", err)
+	}
+
+	sections := map[string]interface{}{}
+	for _, key := range sortedKeys(raw) {
+		section, ok := raw[key].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		var converted map[string]interface{}
+		switch {
+		case strings.EqualFold(key, "tracing"):
+			converted = c.tracing(section).prune()
+		case strings.EqualFold(key, "metrics"):
+			converted = c.metricsV2(section).prune()
+		case strings.EqualFold(key, "providers"):
+			converted = c.providersV2(section)
+		default:
+			continue
+		}
+		if !reflect.DeepEqual(converted, section) {
+			sections[key] = converted
+		}
+	}
+	if len(sections) == 0 {
+		return content, nil
+	}
+
+	if isTOML {
+		return replaceTOMLSections(content, sections)
+	}
+	return replaceYAMLSections(content, sections)
+}
+
+// replaceYAMLSections replaces the given top level sections of a YAML
+// document, which must be a block mapping.
+func replaceYAMLSections(content []byte, sections map[string]interface{}) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode || doc.Content[0].Style&yaml.FlowStyle != 0 {
+		return nil, fmt.Errorf("static configuration is not a block mapping")
+	}
+
+	root := doc.Content[0]
+	var edits []utils.TextEdit
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key := root.Content[i]
+		section, ok := sections[key.Value]
+		if !ok {
+			continue
+		}
+
+		start, end, err := utils.YAMLPairSpan(content, key, root.Content[i+1])
+		if err != nil {
+			return nil, err
+		}
+		text, err := utils.EncodeYaml(map[string]interface{}{key.Value: section})
+		if err != nil {
+			return nil, err
+		}
+		edits = append(edits, utils.TextEdit{Start: start, End: end, Text: string(text)})
+	}
+	return utils.ApplyEdits(content, edits)
+}
+
+// replaceTOMLSections replaces the given top level sections of a TOML
+// document. A section is written where its first table was, or at the end
+// of the document if it was only made of dotted keys of the root table.
+func replaceTOMLSections(content []byte, sections map[string]interface{}) ([]byte, error) {
+	statements := tomlStatements(content)
+
+	var edits []utils.TextEdit
+	for _, key := range sortedKeys(sections) {
+		buf := &bytes.Buffer{}
+		if err := toml.NewEncoder(buf).Encode(map[string]interface{}{key: sections[key]}); err != nil {
+			return nil, err
+		}
+
+		written := false
+		for _, statement := range statements {
+			if statement.key != key {
+				continue
+			}
+			edit := utils.TextEdit{Start: statement.start, End: statement.end}
+			if statement.table && !written {
+				edit.Text, written = buf.String(), true
+			} else {
+				// removed statements take the blank lines following them
+				// along.
+				for edit.End < len(content) && content[edit.End] == '\n' {
+					edit.End++
+				}
+			}
+			edits = append(edits, edit)
+		}
+		if !written {
+			text := "\n" + buf.String()
+			if len(content) > 0 && content[len(content)-1] != '\n' {
+				text = "\n" + text
+			}
+			edits = append(edits, utils.TextEdit{Start: len(content), End: len(content), Text: text})
+		}
+	}
+	return utils.ApplyEdits(content, edits)
+}
+
+// tomlStatement is a table or a key of the root table of a TOML document,
+// spanning [start, end) from its first line to the end of its last value.
+// key is the first part of the table name or of the dotted key.
+type tomlStatement struct {
+	key   string
+	table bool
+	start int
+	end   int
+}
+
+// tomlStatements returns the tables and the root keys of a TOML document.
+// Comments and blank lines following a statement are not part of it.
+func tomlStatements(content []byte) []tomlStatement {
+	var statements []tomlStatement
+	var current *tomlStatement
+	scanner := &tomlScanner{}
+
+	for offset := 0; offset < len(content); {
+		end := bytes.IndexByte(content[offset:], '\n') + offset + 1
+		if end == offset {
+			end = len(content)
+		}
+		line := content[offset:end]
+		trimmed := bytes.TrimSpace(line)
+
+		switch {
+		case scanner.inValue():
+			scanner.scan(line)
+			current.end = end
+		case len(trimmed) == 0 || trimmed[0] == '#':
+		case trimmed[0] == '[':
+			name := strings.Trim(string(trimmed[:bytes.IndexByte(trimmed, ']')+1]), "[] \t")
+			statements = append(statements, tomlStatement{key: tomlKey(name), table: true, start: offset, end: end})
+			current = &statements[len(statements)-1]
+		case current != nil && current.table:
+			scanner.scan(line)
+			current.end = end
+		default:
+			name, _, _ := strings.Cut(string(trimmed), "=")
+			statements = append(statements, tomlStatement{key: tomlKey(name), start: offset, end: end})
+			current = &statements[len(statements)-1]
+			scanner.scan(line)
+		}
+		offset = end
+	}
+	return statements
+}
+
+// tomlKey returns the first part of a dotted key.
+func tomlKey(name string) string {
+	name = strings.TrimSpace(name)
+	if name != "" && (name[0] == '"' || name[0] == '\'') {
+		if end := strings.IndexByte(name[1:], name[0]); end >= 0 {
+			return name[1 : end+1]
+		}
+	}
+	key, _, _ := strings.Cut(name, ".")
+	return strings.TrimSpace(key)
+}
+
+// tomlScanner tracks the multi-line strings, arrays and inline tables of a
+// TOML document, so values spanning several lines are kept together.
+type tomlScanner struct {
+	depth int
+	// delimiter is the delimiter of the multi-line string being scanned.
+	delimiter string
+}
+
+func (s *tomlScanner) inValue() bool {
+	return s.depth > 0 || s.delimiter != ""
+}
+
+func (s *tomlScanner) scan(line []byte) {
+	for i := 0; i < len(line); i++ {
+		if s.delimiter != "" {
+			end := bytes.Index(line[i:], []byte(s.delimiter))
+			if end < 0 {
+				return
+			}
+			i += end + len(s.delimiter) - 1
+			s.delimiter = ""
+			continue
+		}
+
+		switch c := line[i]; c {
+		case '#':
+			return
+		case '[', '{':
+			s.depth++
+		case ']', '}':
+			s.depth--
+		case '"', '\'':
+			if bytes.HasPrefix(line[i:], bytes.Repeat([]byte{c}, 3)) {
+				s.delimiter = strings.Repeat(string(c), 3)
+				i += 2
+				continue
+			}
+			for i++; i < len(line) && line[i] != c; i++ {
+				if c == '"' && line[i] == '\\' {
+					i++
+				}
+			}
+		}
+	}
+}
+
+// metricsV2 converts a v2 metrics section: the openTelemetry exporter
+// becomes the otlp one, InfluxDB v1 is dropped.
+func (c *Converter) metricsV2(metrics map[string]interface{}) options {
+	out := options{}
+	for _, key := range sortedKeys(metrics) {
+		value := metrics[key]
+		switch {
+		case strings.EqualFold(key, "influxDB"):
+			c.report.Add("metrics", "InfluxDB v1 is not supported by v3, metrics must be pushed to InfluxDB v2 with the influxDB2 exporter")
+		case strings.EqualFold(key, "openTelemetry"):
+			openTelemetry, _ := value.(map[string]interface{})
+			otlpMetrics := options{}
+			otlp(otlpMetrics, openTelemetry, "/v1/metrics")
+			for _, option := range []string{"addEntryPointsLabels", "addRoutersLabels", "addServicesLabels", "explicitBoundaries", "pushInterval"} {
+				if v, ok := lookup(openTelemetry, option); ok {
+					otlpMetrics[option] = v
+				}
+			}
+			out.enable("otlp", otlpMetrics)
+		default:
+			out[key] = value
+		}
+	}
+	return out
+}
+
+// providersV2 removes the providers v3 doesn't have anymore from a v2
+// providers section. Empty provider sections enable a provider, so the
+// section is not pruned.
+func (c *Converter) providersV2(providers map[string]interface{}) map[string]interface{} {
+	out := map[string]interface{}{}
+	for _, key := range sortedKeys(providers) {
+		if guidance, ok := removedProviders[strings.ToLower(key)]; ok {
+			c.report.Add(key+" provider", guidance)
+			continue
+		}
+		out[key] = providers[key]
+	}
+	return out
+}
+
+// decodeV2 decodes a static configuration, in TOML for .toml files and in
+// YAML otherwise. Content read from stdin is tried in both formats.
+func decodeV2(content []byte, ext string) (map[string]interface{}, bool, error) {
+	raw := map[string]interface{}{}
+
+	switch strings.ToLower(strings.TrimPrefix(ext, ".")) {
+	case "toml":
+		return raw, true, toml.Unmarshal(content, &raw)
+	case "":
+		if err := yaml.Unmarshal(content, &raw); err != nil {
+			raw = map[string]interface{}{}
+			return raw, true, toml.Unmarshal(content, &raw)
+		}
+		return raw, false, nil
+	default:
+		return raw, false, yaml.Unmarshal(content, &raw)
+	}
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
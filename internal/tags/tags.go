@@ -0,0 +1,194 @@
+package tags
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/databotic/traefik-migration-tool/internal/hclscan"
+	"github.com/databotic/traefik-migration-tool/internal/labels"
+	"github.com/databotic/traefik-migration-tool/internal/report"
+	"github.com/databotic/traefik-migration-tool/internal/utils"
+)
+
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatHCL  Format = "hcl"
+)
+
+// literal is a double quoted string of the source document.
+type literal struct {
+	start int
+	end   int
+	value string
+	// templated literals contain interpolations and can't be rewritten.
+	templated bool
+}
+
+// Converter rewrites the Traefik tags of Consul service definitions, Nomad
+// jobs and ECS task definitions. Only the string literals holding Traefik
+// tags or labels are changed, the rest of the document is kept as is.
+type Converter struct {
+	labels *labels.Rewriter
+	report *report.Report
+}
+
+func New(rewriter *labels.Rewriter, r *report.Report) *Converter {
+	return &Converter{labels: rewriter, report: r}
+}
+
+// DetectFormat returns the format of a document from its file extension,
+// falling back to its content.
+func DetectFormat(content []byte, ext string) Format {
+	switch strings.ToLower(strings.TrimPrefix(ext, ".")) {
+	case "json":
+		return FormatJSON
+	case "hcl", "nomad", "tf":
+		return FormatHCL
+	}
+
+	trimmed := bytes.TrimSpace(content)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(trimmed) {
+		return FormatJSON
+	}
+	return FormatHCL
+}
+
+func (c *Converter) Convert(src []byte, format Format) ([]byte, error) {
+	literals, err := scan(src, format)
+	if err != nil {
+		return nil, err
+	}
+
+	var edits []utils.TextEdit
+	for i := 0; i < len(literals); i++ {
+		l := literals[i]
+
+		// "key": "value" in JSON objects or "key" = "value" in HCL maps,
+		// as used by ECS docker labels.
+		if i+1 < len(literals) && isPair(src, l, literals[i+1]) && c.labels.IsTraefikKey(l.value) {
+			value := literals[i+1]
+			i++
+
+			if l.templated || value.templated {
+				c.report.Add(location(src, l), "label %s contains interpolations and was not converted", l.value)
+				continue
+			}
+
+			newKey, newValue, err := c.rewrite(src, l, l.value, value.value)
+			if err != nil {
+				return nil, err
+			}
+			if newKey != l.value {
+				edits = append(edits, c.edit(l, newKey, format))
+			}
+			if newValue != value.value {
+				edits = append(edits, c.edit(value, newValue, format))
+			}
+			continue
+		}
+
+		// "key=value" tags, as used by Consul Catalog and Nomad.
+		key, value, found := strings.Cut(l.value, "=")
+		if !found || !c.labels.IsTraefikKey(key) {
+			continue
+		}
+		if l.templated {
+			c.report.Add(location(src, l), "tag %s contains interpolations and was not converted", key)
+			continue
+		}
+
+		newKey, newValue, err := c.rewrite(src, l, key, value)
+		if err != nil {
+			return nil, err
+		}
+		if newKey != key || newValue != value {
+			edits = append(edits, c.edit(l, newKey+"="+newValue, format))
+		}
+	}
+
+	return utils.ApplyEdits(src, edits)
+}
+
+func (c *Converter) rewrite(src []byte, l literal, key, value string) (string, string, error) {
+	if msg := c.labels.Deprecated(key); msg != "" {
+		c.report.Add(location(src, l), "tag %s: %s and should be fixed manually", key, msg)
+	}
+
+	newKey, newValue, err := c.labels.Rewrite(key, value)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", location(src, l), err)
+	}
+	return newKey, newValue, nil
+}
+
+func (c *Converter) edit(l literal, value string, format Format) utils.TextEdit {
+	text := utils.QuoteString(value)
+	if format == FormatHCL {
+		text = strings.ReplaceAll(strings.ReplaceAll(text, "${", "$${"), "%{", "%%{")
+	}
+	return utils.TextEdit{Start: l.start, End: l.end, Text: text}
+}
+
+// isPair reports whether the literals are the key and value of an object
+// or map entry.
+func isPair(src []byte, key, value literal) bool {
+	between := bytes.TrimSpace(src[key.end:value.start])
+	return len(between) == 1 && (between[0] == ':' || between[0] == '=')
+}
+
+func location(src []byte, l literal) string {
+	return fmt.Sprintf("line %d", bytes.Count(src[:l.start], []byte("\n"))+1)
+}
+
+// scan returns the double quoted string literals of a JSON or HCL document,
+// skipping HCL comments and heredocs.
+func scan(src []byte, format Format) ([]literal, error) {
+	var literals []literal
+
+	for i := 0; i < len(src); i++ {
+		switch {
+		case src[i] == '"':
+			l, err := scanString(src, i, format)
+			if err != nil {
+				return nil, err
+			}
+			literals = append(literals, l)
+			i = l.end - 1
+		case format != FormatHCL:
+		case bytes.HasPrefix(src[i:], []byte("<<")):
+			h, err := hclscan.ParseHeredoc(src, i)
+			if err != nil {
+				return nil, err
+			}
+			i = h.End - 1
+		default:
+			end, ok, err := hclscan.Comment(src, i)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				i = end - 1
+			}
+		}
+	}
+
+	return literals, nil
+}
+
+func scanString(src []byte, start int, format Format) (literal, error) {
+	end, templated, err := hclscan.String(src, start, format == FormatHCL)
+	if err != nil {
+		return literal{}, err
+	}
+
+	l := literal{start: start, end: end, templated: templated}
+	if err := json.Unmarshal(src[start:end], &l.value); err != nil {
+		// escape sequences which are not valid JSON, leave the literal alone
+		l.templated = true
+	}
+	return l, nil
+}
@@ -0,0 +1,108 @@
+variable "domain" {
+  type = string
+}
+
+locals {
+  entrypoint = "websecure"
+}
+
+# IngressRoute managed by the kubernetes provider
+resource "kubernetes_manifest" "whoami" {
+  manifest = {
+    apiVersion = "traefik.containo.us/v1alpha1"
+    kind       = "IngressRoute"
+    metadata = {
+      name      = "whoami"
+      namespace = var.namespace
+    }
+    spec = {
+      entryPoints = [local.entrypoint]
+      routes = [
+        {
+          kind  = "Rule"
+          match = "Host(`whoami.${var.domain}`) && PathPrefix(`/api`, `/v2`)" // both prefixes
+          middlewares = [
+            { name = "office" },
+          ]
+          services = [
+            {
+              name = "whoami"
+              port = 80
+            },
+          ]
+        },
+      ]
+      tls = {
+        secretName = "whoami-${terraform.workspace}"
+      }
+    }
+  }
+}
+
+resource "kubernetes_manifest" "office" {
+  manifest = {
+    apiVersion = "traefik.containo.us/v1alpha1"
+    kind       = "Middleware"
+    metadata = {
+      name      = "office"
+      namespace = var.namespace
+    }
+    spec = {
+      ipWhiteList = {
+        sourceRange = var.office_ranges
+        ipStrategy  = { depth = 2 }
+      }
+    }
+  }
+}
+
+resource "kubernetes_manifest" "secure_headers" {
+  manifest = {
+    apiVersion = "traefik.containo.us/v1alpha1"
+    kind       = "Middleware"
+    metadata = {
+      name      = "secure-headers"
+      namespace = "default"
+    }
+    spec = {
+      headers = {
+        sslRedirect          = true
+        stsSeconds           = 31536000
+        customRequestHeaders = { "X-Env" = "${var.env}" }
+      }
+    }
+  }
+}
+
+resource "kubectl_manifest" "dashboard" {
+  yaml_body = <<-YAML
+    apiVersion: traefik.containo.us/v1alpha1
+    kind: IngressRoute
+    metadata:
+      name: dashboard
+      namespace: ${var.namespace}
+    spec:
+      entryPoints:
+        - traefik
+      routes:
+        - kind: Rule
+          match: Host(`traefik.${var.domain}`) && Headers(`X-Admin`, `$${literal}`)
+          services:
+            - kind: TraefikService
+              name: api@internal
+  YAML
+}
+
+resource "kubernetes_manifest" "from_file" {
+  manifest = yamldecode(file("${path.module}/ingressroute.yaml"))
+}
+
+resource "kubernetes_manifest" "deployment" {
+  manifest = {
+    apiVersion = "apps/v1"
+    kind       = "Deployment"
+    metadata = {
+      name = "whoami"
+    }
+  }
+}
@@ -0,0 +1,478 @@
+package terraform
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/databotic/traefik-migration-tool/internal/hclscan"
+)
+
+var numberPattern = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?([eE][+-]?[0-9]+)?`)
+
+type nodeKind int
+
+const (
+	// exprNode is any expression which is not evaluated: references,
+	// function calls, operators, conditionals and for expressions.
+	exprNode nodeKind = iota
+	objectNode
+	tupleNode
+	stringNode
+	// literalNode is a number, a bool or null.
+	literalNode
+	heredocNode
+	// callNode is a call to one of the functions decoding manifests.
+	callNode
+)
+
+// node is an expression of the source document, spanning [start, end).
+type node struct {
+	kind  nodeKind
+	start int
+	end   int
+
+	attrs []*attribute
+	items []*node
+
+	// templated strings and heredocs contain interpolations or directives.
+	templated bool
+
+	name string
+	args []*node
+
+	// bodyStart and bodyEnd delimit the content of heredocs.
+	bodyStart int
+	bodyEnd   int
+	indented  bool
+}
+
+// attribute is an attribute of a body or an item of an object expression.
+// The name of items whose key is an expression is empty.
+type attribute struct {
+	name      string
+	nameStart int
+	nameEnd   int
+	start     int
+	value     *node
+}
+
+type block struct {
+	typ    string
+	labels []string
+	start  int
+	body   *body
+}
+
+type body struct {
+	attrs  []*attribute
+	blocks []*block
+}
+
+func (b *body) attribute(name string) *attribute {
+	for _, attr := range b.attrs {
+		if attr.name == name {
+			return attr
+		}
+	}
+	return nil
+}
+
+// hclParser is a minimal parser of the HCL native syntax. It only understands
+// the structure needed to locate and rewrite manifests: bodies, blocks,
+// attributes, and object, tuple and literal expressions. Other expressions
+// are kept as opaque spans of the source.
+type hclParser struct {
+	src []byte
+	pos int
+	// err is the error of an unterminated comment, met while skipping
+	// blanks.
+	err error
+}
+
+func parse(src []byte) (*body, error) {
+	p := &hclParser{src: src}
+	b, err := p.parseBody(0)
+	if p.err != nil {
+		return nil, p.err
+	}
+	return b, err
+}
+
+func (p *hclParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", hclscan.Line(p.src, p.pos), fmt.Sprintf(format, args...))
+}
+
+func (p *hclParser) peek(s string) bool {
+	return bytes.HasPrefix(p.src[p.pos:], []byte(s))
+}
+
+// skipSpace skips blanks and comments, and newlines if asked to.
+func (p *hclParser) skipSpace(newlines bool) {
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; {
+		case c == ' ' || c == '\t' || c == '\r':
+			p.pos++
+		case c == '\n' && newlines:
+			p.pos++
+		default:
+			end, ok, err := hclscan.Comment(p.src, p.pos)
+			if err != nil {
+				p.err, p.pos = err, len(p.src)
+			}
+			if !ok {
+				return
+			}
+			p.pos = end
+		}
+	}
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '-' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func (p *hclParser) ident() string {
+	start := p.pos
+	if p.pos < len(p.src) && (p.src[p.pos] == '-' || p.src[p.pos] >= '0' && p.src[p.pos] <= '9') {
+		return ""
+	}
+	for p.pos < len(p.src) && isIdentByte(p.src[p.pos]) {
+		p.pos++
+	}
+	return string(p.src[start:p.pos])
+}
+
+// parseBody parses attributes and blocks up to the end byte, which is not
+// consumed.
+func (p *hclParser) parseBody(end byte) (*body, error) {
+	b := &body{}
+	for {
+		p.skipSpace(true)
+		if p.pos >= len(p.src) {
+			if end != 0 {
+				return nil, p.errorf("unexpected end of file")
+			}
+			return b, nil
+		}
+		if p.src[p.pos] == end {
+			return b, nil
+		}
+
+		start := p.pos
+		name := p.ident()
+		if name == "" {
+			return nil, p.errorf("unexpected character %q", p.src[p.pos])
+		}
+		nameEnd := p.pos
+
+		p.skipSpace(false)
+		if p.peek("=") && !p.peek("==") {
+			p.pos++
+			value, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			b.attrs = append(b.attrs, &attribute{name: name, nameStart: start, nameEnd: nameEnd, start: start, value: value})
+			continue
+		}
+
+		blk := &block{typ: name, start: start}
+		for {
+			p.skipSpace(false)
+			if p.pos >= len(p.src) {
+				return nil, p.errorf("unexpected end of file")
+			}
+			if p.src[p.pos] == '{' {
+				break
+			}
+			if p.src[p.pos] == '"' {
+				label, err := p.parseString()
+				if err != nil {
+					return nil, err
+				}
+				blk.labels = append(blk.labels, strings.Trim(string(p.src[label.start:label.end]), `"`))
+				continue
+			}
+			label := p.ident()
+			if label == "" {
+				return nil, p.errorf("unexpected character %q", p.src[p.pos])
+			}
+			blk.labels = append(blk.labels, label)
+		}
+		p.pos++
+
+		inner, err := p.parseBody('}')
+		if err != nil {
+			return nil, err
+		}
+		p.pos++
+		blk.body = inner
+		b.blocks = append(b.blocks, blk)
+	}
+}
+
+// atTerminator reports whether the current position ends an expression.
+func (p *hclParser) atTerminator() bool {
+	if p.pos >= len(p.src) {
+		return true
+	}
+	switch p.src[p.pos] {
+	case '\n', ',', ')', ']', '}', '#':
+		return true
+	}
+	return p.peek("//") || p.peek("/*")
+}
+
+func (p *hclParser) parseExpr() (*node, error) {
+	p.skipSpace(false)
+	start := p.pos
+
+	n, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if n != nil {
+		end := p.pos
+		p.skipSpace(false)
+		if p.atTerminator() {
+			p.pos = end
+			return n, nil
+		}
+	}
+
+	// not a plain literal, keep the whole expression as is.
+	p.pos = start
+	if err := p.skipExpr(); err != nil {
+		return nil, err
+	}
+	end := p.pos
+	for end > start && strings.ContainsRune(" \t\r", rune(p.src[end-1])) {
+		end--
+	}
+	if end == start {
+		return nil, p.errorf("missing expression")
+	}
+	return &node{kind: exprNode, start: start, end: end}, nil
+}
+
+// parsePrimary parses the literal expressions, it returns nil for other
+// expressions.
+func (p *hclParser) parsePrimary() (*node, error) {
+	if p.pos >= len(p.src) {
+		return nil, nil
+	}
+
+	switch c := p.src[p.pos]; {
+	case c == '{' || c == '[':
+		if p.isForExpr() {
+			return nil, nil
+		}
+		if c == '{' {
+			return p.parseObject()
+		}
+		return p.parseTuple()
+	case c == '"':
+		return p.parseString()
+	case p.peek("<<"):
+		return p.parseHeredoc()
+	case c == '-' || c >= '0' && c <= '9':
+		match := numberPattern.Find(p.src[p.pos:])
+		if match == nil {
+			return nil, nil
+		}
+		n := &node{kind: literalNode, start: p.pos, end: p.pos + len(match)}
+		p.pos = n.end
+		return n, nil
+	}
+
+	start := p.pos
+	name := p.ident()
+	switch name {
+	case "":
+		return nil, nil
+	case "true", "false", "null":
+		return &node{kind: literalNode, start: start, end: p.pos}, nil
+	case "yamldecode":
+		if !p.peek("(") {
+			return nil, nil
+		}
+		p.pos++
+		p.skipSpace(true)
+		arg, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace(true)
+		if !p.peek(")") {
+			return nil, nil
+		}
+		p.pos++
+		return &node{kind: callNode, start: start, end: p.pos, name: name, args: []*node{arg}}, nil
+	}
+	return nil, nil
+}
+
+func (p *hclParser) isForExpr() bool {
+	save := p.pos
+	defer func() { p.pos = save }()
+
+	p.pos++
+	p.skipSpace(true)
+	return p.ident() == "for" && p.pos < len(p.src) && strings.ContainsRune(" \t\n", rune(p.src[p.pos]))
+}
+
+func (p *hclParser) parseObject() (*node, error) {
+	n := &node{kind: objectNode, start: p.pos}
+	p.pos++
+
+	for {
+		p.skipSpace(true)
+		if p.pos >= len(p.src) {
+			return nil, p.errorf("unterminated object")
+		}
+		if p.src[p.pos] == '}' {
+			p.pos++
+			n.end = p.pos
+			return n, nil
+		}
+
+		attr := &attribute{start: p.pos, nameStart: p.pos}
+		switch p.src[p.pos] {
+		case '"':
+			key, err := p.parseString()
+			if err != nil {
+				return nil, err
+			}
+			if !key.templated {
+				if value, ok := unquote(p.src[key.start:key.end]); ok {
+					attr.name = value
+				}
+			}
+		case '(':
+			if err := p.skipExpr(); err != nil {
+				return nil, err
+			}
+		default:
+			attr.name = p.ident()
+			if attr.name == "" {
+				if err := p.skipExpr(); err != nil {
+					return nil, err
+				}
+			}
+		}
+		attr.nameEnd = p.pos
+
+		p.skipSpace(false)
+		if !p.peek("=") && !p.peek(":") {
+			return nil, p.errorf("missing '=' in object")
+		}
+		p.pos++
+
+		value, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		attr.value = value
+		n.attrs = append(n.attrs, attr)
+
+		p.skipSpace(false)
+		if p.peek(",") {
+			p.pos++
+		}
+	}
+}
+
+func (p *hclParser) parseTuple() (*node, error) {
+	n := &node{kind: tupleNode, start: p.pos}
+	p.pos++
+
+	for {
+		p.skipSpace(true)
+		if p.pos >= len(p.src) {
+			return nil, p.errorf("unterminated list")
+		}
+		if p.src[p.pos] == ']' {
+			p.pos++
+			n.end = p.pos
+			return n, nil
+		}
+
+		item, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		n.items = append(n.items, item)
+
+		p.skipSpace(true)
+		if p.peek(",") {
+			p.pos++
+		}
+	}
+}
+
+func (p *hclParser) parseString() (*node, error) {
+	end, templated, err := hclscan.String(p.src, p.pos, true)
+	if err != nil {
+		return nil, err
+	}
+	n := &node{kind: stringNode, start: p.pos, end: end, templated: templated}
+	p.pos = end
+	return n, nil
+}
+
+func (p *hclParser) parseHeredoc() (*node, error) {
+	h, err := hclscan.ParseHeredoc(p.src, p.pos)
+	if err != nil {
+		return nil, err
+	}
+	p.pos = h.End
+	return &node{
+		kind:      heredocNode,
+		start:     h.Start,
+		end:       h.End,
+		templated: h.Templated,
+		bodyStart: h.BodyStart,
+		bodyEnd:   h.BodyEnd,
+		indented:  h.Indented,
+	}, nil
+}
+
+// skipExpr moves to the end of the expression at the current position.
+func (p *hclParser) skipExpr() error {
+	depth := 0
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; {
+		case c == '"':
+			if _, err := p.parseString(); err != nil {
+				return err
+			}
+			continue
+		case p.peek("<<"):
+			if _, err := p.parseHeredoc(); err != nil {
+				return err
+			}
+			continue
+		case c == '#' || p.peek("//") || p.peek("/*"):
+			p.skipSpace(false)
+			continue
+		case c == '(' || c == '[' || c == '{':
+			depth++
+		case c == ')' || c == ']' || c == '}':
+			if depth == 0 {
+				return nil
+			}
+			depth--
+		case c == ',' || c == '\n':
+			if depth == 0 {
+				return nil
+			}
+		}
+		p.pos++
+	}
+	if depth > 0 {
+		return p.errorf("unterminated expression")
+	}
+	return nil
+}
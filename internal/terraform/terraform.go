@@ -0,0 +1,574 @@
+package terraform
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/databotic/traefik-migration-tool/internal/converter"
+	"github.com/databotic/traefik-migration-tool/internal/hclscan"
+	"github.com/databotic/traefik-migration-tool/internal/parser"
+	"github.com/databotic/traefik-migration-tool/internal/report"
+	"github.com/databotic/traefik-migration-tool/internal/utils"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const containousGroup = "traefik.containo.us/"
+
+// manifestAttributes are the Terraform resources holding Kubernetes
+// manifests, with the attribute the manifest is set in.
+var manifestAttributes = map[string]string{
+	"kubernetes_manifest": "manifest",
+	"kubectl_manifest":    "yaml_body",
+}
+
+var (
+	resourcePattern   = regexp.MustCompile(`(?m)^\s*resource\s+"(kubernetes|kubectl)_manifest"`)
+	identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+)
+
+// Converter rewrites the Traefik manifests of Terraform kubernetes_manifest
+// and kubectl_manifest resources in place. Only the values changed by the
+// conversion are edited, interpolations and the rest of the configuration
+// are kept as is.
+type Converter struct {
+	converter *converter.Converter
+	report    *report.Report
+}
+
+func New(r *report.Report) (*Converter, error) {
+	c, err := converter.New(r)
+	if err != nil {
+		return nil, err
+	}
+	return &Converter{converter: c, report: r}, nil
+}
+
+// Detect reports whether content is a Terraform configuration declaring
+// Kubernetes manifests.
+func Detect(content []byte, ext string) bool {
+	if strings.EqualFold(ext, ".tf") {
+		return true
+	}
+	return filepath.Ext(ext) == "" && resourcePattern.Match(content)
+}
+
+func (c *Converter) Convert(src []byte) ([]byte, error) {
+	b, err := parse(src)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing terraform configuration: %v", err)
+	}
+
+	var edits []utils.TextEdit
+	for _, blk := range b.blocks {
+		if blk.typ != "resource" || len(blk.labels) != 2 {
+			continue
+		}
+		name, ok := manifestAttributes[blk.labels[0]]
+		if !ok {
+			continue
+		}
+		attr := blk.body.attribute(name)
+		if attr == nil {
+			continue
+		}
+
+		source := blk.labels[0] + "." + blk.labels[1]
+		e, err := c.convertManifest(src, source, attr.value)
+		if err != nil {
+			c.report.Add(source, "%v", err)
+			continue
+		}
+		edits = append(edits, e...)
+	}
+
+	return utils.ApplyEdits(src, edits)
+}
+
+func (c *Converter) convertManifest(src []byte, source string, n *node) ([]utils.TextEdit, error) {
+	switch n.kind {
+	case objectNode:
+		return c.convertObject(src, source, n)
+	case heredocNode:
+		return c.convertHeredoc(src, n)
+	case callNode:
+		if arg := n.args[0]; arg.kind == heredocNode {
+			return c.convertHeredoc(src, arg)
+		}
+	}
+
+	return nil, fmt.Errorf("manifest is computed by an expression and was not checked")
+}
+
+func (c *Converter) convertObject(src []byte, source string, n *node) ([]utils.TextEdit, error) {
+	m := &masker{}
+	value := evaluate(src, n, m)
+
+	apiVersion, _ := value.(map[string]interface{})["apiVersion"].(string)
+	if !strings.HasPrefix(apiVersion, containousGroup) {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	_, before, after, err := c.convert(data)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &editor{src: src, masker: m, report: c.report, source: source}
+	e.diff(n, "", before[0], after[0])
+	return e.edits, nil
+}
+
+func (c *Converter) convertHeredoc(src []byte, n *node) ([]utils.TextEdit, error) {
+	content := string(src[n.bodyStart:n.bodyEnd])
+	indent := ""
+	if n.indented {
+		indent = commonIndent(content)
+		content = reindent(content, indent, "")
+	}
+
+	m := &masker{}
+	masked, err := m.maskTemplates(content)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.Contains(masked, containousGroup) {
+		return nil, nil
+	}
+
+	objects, _, _, err := c.convert([]byte(masked))
+	if err != nil {
+		return nil, err
+	}
+
+	var fragments []string
+	for _, object := range objects {
+		data, err := c.converter.EncodeYaml(object)
+		if err != nil {
+			return nil, err
+		}
+		fragments = append(fragments, string(data))
+	}
+	converted := m.restore(strings.Join(fragments, "---\n"))
+
+	return []utils.TextEdit{{Start: n.bodyStart, End: n.bodyEnd, Text: reindent(converted, "", indent)}}, nil
+}
+
+// convert converts the manifests in data, it returns the converted objects
+// and their generic representation before and after the conversion.
+func (c *Converter) convert(data []byte) ([]runtime.Object, []interface{}, []interface{}, error) {
+	objects, err := parser.ParseManifest(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	converted, err := c.converter.Do(objects)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var before, after []interface{}
+	for i, object := range objects {
+		b, err := utils.ToMap(object)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		a, err := utils.ToMap(converted[i])
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		before = append(before, b)
+		after = append(after, a)
+	}
+
+	return converted, before, after, nil
+}
+
+// evaluate returns the value of the object, tuple and literal expressions.
+// Object items set by other expressions are left out, tuple items are
+// replaced by placeholders.
+func evaluate(src []byte, n *node, m *masker) interface{} {
+	switch n.kind {
+	case objectNode:
+		object := map[string]interface{}{}
+		for _, attr := range n.attrs {
+			if attr.name == "" || !evaluable(attr.value) {
+				continue
+			}
+			object[attr.name] = evaluate(src, attr.value, m)
+		}
+		return object
+	case tupleNode:
+		items := []interface{}{}
+		for _, item := range n.items {
+			if !evaluable(item) {
+				items = append(items, m.maskExpr(string(src[item.start:item.end])))
+				continue
+			}
+			items = append(items, evaluate(src, item, m))
+		}
+		return items
+	case stringNode:
+		masked, err := m.maskTemplates(string(src[n.start+1 : n.end-1]))
+		if err != nil {
+			return m.maskExpr(string(src[n.start:n.end]))
+		}
+		value, ok := unquote([]byte(`"` + masked + `"`))
+		if !ok {
+			return m.maskExpr(string(src[n.start:n.end]))
+		}
+		return value
+	case literalNode:
+		var value interface{}
+		decoder := json.NewDecoder(bytes.NewReader(src[n.start:n.end]))
+		decoder.UseNumber()
+		_ = decoder.Decode(&value)
+		return value
+	}
+	return nil
+}
+
+func evaluable(n *node) bool {
+	switch n.kind {
+	case objectNode, tupleNode, stringNode, literalNode:
+		return true
+	}
+	return false
+}
+
+func unquote(raw []byte) (string, bool) {
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", false
+	}
+	return value, true
+}
+
+// masker replaces the template sequences and the expressions of a manifest
+// by placeholders, so it can be decoded and converted, and restores them in
+// the converted manifest.
+type masker struct {
+	templates []string
+	exprs     []string
+}
+
+func (m *masker) maskExpr(raw string) string {
+	m.exprs = append(m.exprs, raw)
+	return fmt.Sprintf("tfexpr%dtf", len(m.exprs)-1)
+}
+
+func (m *masker) maskTemplates(raw string) (string, error) {
+	var out strings.Builder
+	last := 0
+	for i := 0; i+1 < len(raw); i++ {
+		if (raw[i] != '$' && raw[i] != '%') || raw[i+1] != '{' {
+			continue
+		}
+
+		start, end := i, i+1
+		if i > 0 && raw[i-1] == raw[i] {
+			// escaped $${ or %%{ sequence
+			start--
+		} else {
+			e, err := hclscan.Template([]byte(raw), i+1)
+			if err != nil {
+				return "", err
+			}
+			end = e
+		}
+
+		out.WriteString(raw[last:start])
+		m.templates = append(m.templates, raw[start:end+1])
+		fmt.Fprintf(&out, "tftemplate%dtf", len(m.templates)-1)
+		last = end + 1
+		i = end
+	}
+	out.WriteString(raw[last:])
+
+	return out.String(), nil
+}
+
+func (m *masker) restore(s string) string {
+	for i := len(m.exprs) - 1; i >= 0; i-- {
+		placeholder := fmt.Sprintf("tfexpr%dtf", i)
+		s = strings.ReplaceAll(s, `"`+placeholder+`"`, m.exprs[i])
+		s = strings.ReplaceAll(s, placeholder, m.exprs[i])
+	}
+	for i := len(m.templates) - 1; i >= 0; i-- {
+		s = strings.ReplaceAll(s, fmt.Sprintf("tftemplate%dtf", i), m.templates[i])
+	}
+	return s
+}
+
+// quote renders value as an HCL string, escaping the sequences which would
+// start a template.
+func (m *masker) quote(value string) string {
+	quoted := utils.QuoteString(value)
+	quoted = strings.ReplaceAll(quoted, "${", "$${")
+	quoted = strings.ReplaceAll(quoted, "%{", "%%{")
+	return m.restore(quoted)
+}
+
+// editor computes the edits turning the manifest expression into the
+// converted manifest.
+type editor struct {
+	src    []byte
+	masker *masker
+	report *report.Report
+	source string
+	edits  []utils.TextEdit
+}
+
+func (e *editor) diff(n *node, path string, before, after interface{}) {
+	if reflect.DeepEqual(before, after) {
+		return
+	}
+
+	switch n.kind {
+	case objectNode:
+		b, okBefore := before.(map[string]interface{})
+		a, okAfter := after.(map[string]interface{})
+		if okBefore && okAfter {
+			e.diffObject(n, path, b, a)
+			return
+		}
+	case tupleNode:
+		b, okBefore := before.([]interface{})
+		a, okAfter := after.([]interface{})
+		if okBefore && okAfter && len(a) == len(b) && len(n.items) == len(b) {
+			for i, item := range n.items {
+				e.diff(item, fmt.Sprintf("%s[%d]", path, i), b[i], a[i])
+			}
+			return
+		}
+	case exprNode, heredocNode, callNode:
+		e.report.Add(e.source, "%s is set by an expression and was not converted", path)
+		return
+	}
+
+	e.replace(n, after)
+}
+
+func (e *editor) diffObject(n *node, path string, before, after map[string]interface{}) {
+	attrs := map[string]*attribute{}
+	for _, attr := range n.attrs {
+		if attr.name != "" {
+			attrs[attr.name] = attr
+		}
+	}
+
+	var removed, added []string
+	for key := range before {
+		if _, ok := after[key]; !ok {
+			removed = append(removed, key)
+		}
+	}
+	for key := range after {
+		if _, ok := before[key]; !ok {
+			added = append(added, key)
+		}
+	}
+	sort.Strings(removed)
+	sort.Strings(added)
+
+	// a single field replaced by another one is a renaming, keep its value
+	// as written.
+	if len(removed) == 1 && len(added) == 1 && attrs[removed[0]] != nil &&
+		reflect.TypeOf(before[removed[0]]) == reflect.TypeOf(after[added[0]]) {
+		attr := attrs[removed[0]]
+		e.edits = append(e.edits, utils.TextEdit{Start: attr.nameStart, End: attr.nameEnd, Text: e.key(added[0])})
+		e.diff(attr.value, join(path, added[0]), before[removed[0]], after[added[0]])
+		removed, added = nil, nil
+	}
+
+	keys := make([]string, 0, len(after))
+	for key := range after {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if _, ok := before[key]; !ok {
+			continue
+		}
+		if attr := attrs[key]; attr != nil {
+			e.diff(attr.value, join(path, key), before[key], after[key])
+		} else if !reflect.DeepEqual(before[key], after[key]) {
+			e.insert(n, key, after[key])
+		}
+	}
+
+	for _, key := range removed {
+		if attr := attrs[key]; attr != nil {
+			e.remove(n, attr)
+			e.report.Add(e.source, "%s was removed", join(path, key))
+		}
+	}
+	for _, key := range added {
+		e.insert(n, key, after[key])
+	}
+}
+
+func (e *editor) replace(n *node, value interface{}) {
+	e.edits = append(e.edits, utils.TextEdit{Start: n.start, End: n.end, Text: e.render(value, e.indentAt(n.start))})
+}
+
+// remove deletes an item of an object, with its line when it is alone on it.
+func (e *editor) remove(object *node, attr *attribute) {
+	lineStart := bytes.LastIndexByte(e.src[:attr.start], '\n') + 1
+	lineEnd := bytes.IndexByte(e.src[attr.value.end:], '\n')
+	if lineEnd < 0 {
+		lineEnd = len(e.src)
+	} else {
+		lineEnd += attr.value.end
+	}
+
+	before := strings.TrimSpace(string(e.src[lineStart:attr.start]))
+	after := strings.TrimSpace(string(e.src[attr.value.end:lineEnd]))
+	if before == "" && (after == "" || after == ",") && lineEnd < len(e.src) && lineEnd < object.end {
+		e.edits = append(e.edits, utils.TextEdit{Start: lineStart, End: lineEnd + 1})
+		return
+	}
+
+	end := attr.value.end
+	for end < object.end && strings.ContainsRune(", \t", rune(e.src[end])) {
+		end++
+	}
+	e.edits = append(e.edits, utils.TextEdit{Start: attr.start, End: end})
+}
+
+// insert adds an item at the end of an object.
+func (e *editor) insert(object *node, key string, value interface{}) {
+	closing := object.end - 1
+	lineStart := bytes.LastIndexByte(e.src[:closing], '\n') + 1
+
+	if len(object.attrs) > 0 && strings.TrimSpace(string(e.src[lineStart:closing])) == "" {
+		first := object.attrs[0]
+		indent := e.indentAt(first.start)
+		text := indent + e.key(key) + " = " + e.render(value, indent) + "\n"
+		e.edits = append(e.edits, utils.TextEdit{Start: lineStart, End: lineStart, Text: text})
+		return
+	}
+
+	if len(object.attrs) == 0 {
+		object := map[string]interface{}{key: value}
+		e.edits = append(e.edits, utils.TextEdit{Start: closing - 1, End: closing + 1, Text: e.render(object, e.indentAt(closing))})
+		return
+	}
+
+	last := object.attrs[len(object.attrs)-1]
+	text := ", " + e.key(key) + " = " + e.render(value, "")
+	e.edits = append(e.edits, utils.TextEdit{Start: last.value.end, End: last.value.end, Text: text})
+}
+
+// indentAt returns the indentation of the line holding offset.
+func (e *editor) indentAt(offset int) string {
+	lineStart := bytes.LastIndexByte(e.src[:offset], '\n') + 1
+	end := lineStart
+	for end < len(e.src) && (e.src[end] == ' ' || e.src[end] == '\t') {
+		end++
+	}
+	return string(e.src[lineStart:end])
+}
+
+func (e *editor) key(name string) string {
+	if identifierPattern.MatchString(name) {
+		return name
+	}
+	return e.masker.quote(name)
+}
+
+// render returns the HCL representation of a value.
+func (e *editor) render(value interface{}, indent string) string {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			return "{}"
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		var out strings.Builder
+		out.WriteString("{\n")
+		for _, key := range keys {
+			fmt.Fprintf(&out, "%s  %s = %s\n", indent, e.key(key), e.render(v[key], indent+"  "))
+		}
+		out.WriteString(indent + "}")
+		return out.String()
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		multiline := false
+		for _, item := range v {
+			if _, ok := item.(map[string]interface{}); ok {
+				multiline = true
+			}
+		}
+		for _, item := range v {
+			if multiline {
+				items = append(items, indent+"  "+e.render(item, indent+"  "))
+			} else {
+				items = append(items, e.render(item, indent))
+			}
+		}
+		if multiline {
+			return "[\n" + strings.Join(items, ",\n") + ",\n" + indent + "]"
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case string:
+		return e.masker.quote(v)
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "null"
+	}
+	return string(data)
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// commonIndent returns the indentation shared by the non blank lines of s.
+func commonIndent(s string) string {
+	indent := ""
+	first := true
+	for _, l := range strings.Split(s, "\n") {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		current := l[:len(l)-len(strings.TrimLeft(l, " \t"))]
+		if first || strings.HasPrefix(indent, current) {
+			indent = current
+			first = false
+		}
+	}
+	return indent
+}
+
+// reindent replaces the from indentation of the non blank lines of s.
+func reindent(s, from, to string) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		lines[i] = to + strings.TrimPrefix(l, from)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,60 @@
+package terraform
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/databotic/traefik-migration-tool/internal/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var updateExpected = flag.Bool("update_expected", false, "Update expected files in testdata")
+
+func TestConvert(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		filename string
+		report   []string
+	}{
+		{
+			desc:     "kubernetes and kubectl manifests",
+			filename: "manifests.tf",
+			report: []string{
+				"Middleware default/secure-headers: Headers.SSLRedirect was removed in v3 and must be fixed manually",
+				"kubernetes_manifest.from_file: manifest is computed by an expression and was not checked",
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			src, err := os.ReadFile(filepath.Join("fixtures", "input", test.filename))
+			require.NoError(t, err)
+
+			r := report.New()
+			c, err := New(r)
+			require.NoError(t, err)
+
+			converted, err := c.Convert(src)
+			require.NoError(t, err)
+
+			fixtureFile := filepath.Join("fixtures", "output", test.filename)
+			if *updateExpected {
+				require.NoError(t, os.WriteFile(fixtureFile, converted, 0o666))
+			}
+
+			expected, err := os.ReadFile(fixtureFile)
+			require.NoError(t, err)
+			assert.Equal(t, string(expected), string(converted))
+
+			var entries []string
+			for _, entry := range r.Entries() {
+				entries = append(entries, entry.Source+": "+entry.Message)
+			}
+			assert.Equal(t, test.report, entries)
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -170,6 +171,49 @@ func AsType[T any](src interface{}) (*T, error) {
 	return dest, nil
 }
 
+// ToMap marshals o to a generic map through its JSON representation. Null
+// values are dropped and integral numbers are kept as integers, so the map can
+// be encoded to YAML or TOML without artifacts.
+func ToMap(o interface{}) (map[string]interface{}, error) {
+	bs, err := json.Marshal(o)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %T: %w", o, err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(bs))
+	decoder.UseNumber()
+
+	out := map[string]interface{}{}
+	if err = decoder.Decode(&out); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %T: %w", o, err)
+	}
+	return plainValue(out).(map[string]interface{}), nil
+}
+
+func plainValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			if item == nil {
+				delete(value, key)
+				continue
+			}
+			value[key] = plainValue(item)
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = plainValue(item)
+		}
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return i
+		}
+		f, _ := value.Float64()
+		return f
+	}
+	return v
+}
+
 func FilterAnnotations(v2Annotations map[string]string) map[string]string {
 	annotations := make(map[string]string)
 	for key, value := range v2Annotations {
@@ -191,34 +235,89 @@ func IsAnnotationToBeSkiped(annotation string) bool {
 }
 
 func HasDepricatedMiddleWareOptions(m *v1alpha1.Middleware) (exists bool) {
-	metaValue := reflect.ValueOf(m).Elem()
-	specValue := metaValue.FieldByName("Spec")
+	return len(DepricatedOptions(m.Spec)) > 0
+}
+
+// DepricatedOptions returns the options set in a middleware configuration,
+// either a CRD spec or a dynamic configuration middleware, which were removed
+// in v3.
+func DepricatedOptions(spec interface{}) []string {
+	var options []string
+
+	specValue := reflect.Indirect(reflect.ValueOf(spec))
 	for name, opts := range DepricatedMiddlewareOpts {
-		field := reflect.Indirect(specValue).FieldByName(name)
+		field := specValue.FieldByName(name)
 		if field == (reflect.Value{}) || field.IsNil() {
 			continue
 		}
-		exists = false
 		for _, opt := range opts {
-			v := reflect.Indirect(field).FieldByName(opt)
-			if v != (reflect.Value{}) {
-				switch v.Kind() {
-				case reflect.Bool:
-					if value := v.Bool(); value {
-						exists = true
-					}
-				case reflect.String:
-					if value := v.String(); value != "" {
-						exists = true
-					}
-				case reflect.Int, reflect.Int8, reflect.Int16,
-					reflect.Int32, reflect.Int64:
-					if v.Int() > 0 {
-						exists = true
-					}
-				}
+			v := reflect.Indirect(reflect.Indirect(field).FieldByName(opt))
+			if v == (reflect.Value{}) {
+				continue
+			}
+
+			exists := false
+			switch v.Kind() {
+			case reflect.Bool:
+				exists = v.Bool()
+			case reflect.String:
+				exists = v.String() != ""
+			case reflect.Int, reflect.Int8, reflect.Int16,
+				reflect.Int32, reflect.Int64:
+				exists = v.Int() > 0
+			}
+			if exists {
+				options = append(options, name+"."+opt)
+			}
+		}
+	}
+
+	sort.Strings(options)
+	return options
+}
+
+// DroppedFields returns the paths of the fields set in before which have no
+// counterpart in after, once both are marshalled to JSON.
+func DroppedFields(before, after interface{}) ([]string, error) {
+	beforeMap, err := AsType[map[string]interface{}](before)
+	if err != nil {
+		return nil, err
+	}
+	afterMap, err := AsType[map[string]interface{}](after)
+	if err != nil {
+		return nil, err
+	}
+
+	var dropped []string
+	droppedFields("", *beforeMap, *afterMap, &dropped)
+	sort.Strings(dropped)
+	return dropped, nil
+}
+
+func droppedFields(prefix string, before, after interface{}, dropped *[]string) {
+	switch b := before.(type) {
+	case map[string]interface{}:
+		a, _ := after.(map[string]interface{})
+		for key, value := range b {
+			path := key
+			if prefix != "" {
+				path = prefix + "." + key
+			}
+			afterValue, ok := a[key]
+			if !ok {
+				*dropped = append(*dropped, path)
+				continue
+			}
+			droppedFields(path, value, afterValue, dropped)
+		}
+	case []interface{}:
+		a, _ := after.([]interface{})
+		for i, value := range b {
+			if i >= len(a) {
+				*dropped = append(*dropped, fmt.Sprintf("%s[%d]", prefix, i))
+				continue
 			}
+			droppedFields(fmt.Sprintf("%s[%d]", prefix, i), value, a[i], dropped)
 		}
 	}
-	return exists
 }
@@ -0,0 +1,258 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TextEdit replaces the bytes in [Start, End) of a source document with Text.
+type TextEdit struct {
+	Start int
+	End   int
+	Text  string
+}
+
+// ApplyEdits applies the edits to src. Identical edits are applied once,
+// overlapping edits are rejected.
+func ApplyEdits(src []byte, edits []TextEdit) ([]byte, error) {
+	sorted := make([]TextEdit, len(edits))
+	copy(sorted, edits)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+
+	var out bytes.Buffer
+	last := 0
+	var prev *TextEdit
+	for i := range sorted {
+		e := &sorted[i]
+		if prev != nil && *prev == *e {
+			continue
+		}
+		if e.Start < last || e.End < e.Start || e.End > len(src) {
+			return nil, fmt.Errorf("conflicting edit at offset %d", e.Start)
+		}
+		out.Write(src[last:e.Start])
+		out.WriteString(e.Text)
+		last = e.End
+		prev = e
+	}
+	out.Write(src[last:])
+
+	return out.Bytes(), nil
+}
+
+// NodeOffset returns the byte offset of a yaml node in the document it was
+// decoded from.
+func NodeOffset(src []byte, node *yaml.Node) (int, error) {
+	offset := 0
+	for line := 1; line < node.Line; line++ {
+		i := bytes.IndexByte(src[offset:], '\n')
+		if i < 0 {
+			return 0, fmt.Errorf("line %d is out of range", node.Line)
+		}
+		offset += i + 1
+	}
+	for col := 1; col < node.Column; col++ {
+		if offset >= len(src) {
+			return 0, fmt.Errorf("column %d is out of range", node.Column)
+		}
+		_, size := utf8.DecodeRune(src[offset:])
+		offset += size
+	}
+	return offset, nil
+}
+
+// YAMLScalarSpan returns the byte range of a scalar node, including its
+// quotes, in the document it was decoded from.
+func YAMLScalarSpan(src []byte, node *yaml.Node) (int, int, error) {
+	start, err := NodeOffset(src, node)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	switch node.Style {
+	case yaml.DoubleQuotedStyle:
+		if src[start] != '"' {
+			break
+		}
+		for i := start + 1; i < len(src); i++ {
+			switch src[i] {
+			case '\\':
+				i++
+			case '"':
+				return start, i + 1, nil
+			}
+		}
+	case yaml.SingleQuotedStyle:
+		if src[start] != '\'' {
+			break
+		}
+		for i := start + 1; i < len(src); i++ {
+			if src[i] != '\'' {
+				continue
+			}
+			if i+1 < len(src) && src[i+1] == '\'' {
+				i++
+				continue
+			}
+			return start, i + 1, nil
+		}
+	case 0:
+		if bytes.HasPrefix(src[start:], []byte(node.Value)) {
+			return start, start + len(node.Value), nil
+		}
+	}
+
+	return 0, 0, fmt.Errorf("unsupported scalar at line %d column %d", node.Line, node.Column)
+}
+
+// YAMLPairSpan returns the byte range of the lines holding a key of a block
+// mapping and its value, so the pair can be removed from the document it was
+// decoded from.
+func YAMLPairSpan(src []byte, key, value *yaml.Node) (int, int, error) {
+	offset, err := NodeOffset(src, key)
+	if err != nil {
+		return 0, 0, err
+	}
+	start := bytes.LastIndexByte(src[:offset], '\n') + 1
+	if len(bytes.TrimSpace(src[start:offset])) > 0 {
+		return 0, 0, fmt.Errorf("key at line %d doesn't start its line", key.Line)
+	}
+
+	// the value spans the following lines indented deeper than the key, or
+	// the items of a sequence at the same indentation.
+	indent := offset - start
+	end := lineEnd(src, offset)
+	for next := end; next < len(src); {
+		nextEnd := lineEnd(src, next)
+		line := src[next:nextEnd]
+		content := bytes.TrimSpace(line)
+		depth := len(line) - len(bytes.TrimLeft(line, " "))
+		switch {
+		case len(content) == 0:
+		case depth > indent,
+			depth == indent && value.Kind == yaml.SequenceNode && (bytes.Equal(content, []byte("-")) || bytes.HasPrefix(content, []byte("- "))):
+			end = nextEnd
+		default:
+			return start, end, nil
+		}
+		next = nextEnd
+	}
+	return start, end, nil
+}
+
+func lineEnd(src []byte, offset int) int {
+	if i := bytes.IndexByte(src[offset:], '\n'); i >= 0 {
+		return offset + i + 1
+	}
+	return len(src)
+}
+
+// YAMLScalarEdit replaces the value of a scalar node, keeping its style.
+// Block scalars are rewritten line by line with their original indentation.
+func YAMLScalarEdit(src []byte, node *yaml.Node, value string) (TextEdit, error) {
+	if node.Style != yaml.LiteralStyle && node.Style != yaml.FoldedStyle {
+		start, end, err := YAMLScalarSpan(src, node)
+		if err != nil {
+			return TextEdit{}, err
+		}
+		return TextEdit{Start: start, End: end, Text: FormatYAMLScalar(value, node.Style)}, nil
+	}
+
+	indicator, err := NodeOffset(src, node)
+	if err != nil {
+		return TextEdit{}, err
+	}
+	eol := bytes.IndexByte(src[indicator:], '\n')
+	if eol < 0 {
+		return TextEdit{}, fmt.Errorf("empty block scalar at line %d", node.Line)
+	}
+	start := indicator + eol + 1
+
+	indent := -1
+	end := start
+	for offset := start; offset < len(src); {
+		next := lineEnd(src, offset)
+		line := src[offset:next]
+		if trimmed := bytes.TrimLeft(line, " "); len(bytes.TrimSpace(trimmed)) > 0 {
+			current := len(line) - len(trimmed)
+			if indent < 0 {
+				indent = current
+			}
+			if current < indent {
+				break
+			}
+			end = next
+		}
+		offset = next
+	}
+
+	prefix := strings.Repeat(" ", max(indent, 0))
+	lines := strings.Split(strings.TrimRight(value, "\n"), "\n")
+	for i, l := range lines {
+		if l != "" {
+			lines[i] = prefix + l
+		}
+	}
+	text := strings.Join(lines, "\n")
+	if end > 0 && src[end-1] == '\n' {
+		text += "\n"
+	}
+
+	return TextEdit{Start: start, End: end, Text: text}, nil
+}
+
+// YAMLMappingValue returns the value of key in a mapping node, or nil if
+// node is not a mapping or doesn't hold key.
+func YAMLMappingValue(node *yaml.Node, key string) *yaml.Node {
+	node = ResolveYAMLAlias(node)
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// ResolveYAMLAlias returns the node an alias node refers to.
+func ResolveYAMLAlias(node *yaml.Node) *yaml.Node {
+	for node != nil && node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	return node
+}
+
+// FormatYAMLScalar renders value in the given scalar style. Plain scalars
+// which would change meaning are rendered double quoted instead.
+func FormatYAMLScalar(value string, style yaml.Style) string {
+	switch style {
+	case yaml.SingleQuotedStyle:
+		if !strings.ContainsAny(value, "\n\r") {
+			return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+		}
+	case 0:
+		if out, err := yaml.Marshal(value); err == nil &&
+			string(out) == value+"\n" && !strings.ContainsAny(value, ",[]{}") {
+			return value
+		}
+	}
+	return QuoteString(value)
+}
+
+// QuoteString renders value as a double quoted string, which is valid in
+// YAML, JSON and HCL documents.
+func QuoteString(value string) string {
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(value)
+	return strings.TrimSuffix(out.String(), "\n")
+}
@@ -16,6 +16,11 @@ func main() {
 		Long:  `A tool to migrate from Traefik v2 to Traefik v3.`,
 	}
 	rootCmd.AddCommand(cmd.Convert())
+	rootCmd.AddCommand(cmd.ConvertCompose())
+	rootCmd.AddCommand(cmd.ConvertTags())
+	rootCmd.AddCommand(cmd.ConvertKV())
+	rootCmd.AddCommand(cmd.ConvertMarathon())
+	rootCmd.AddCommand(cmd.ConvertKustomize())
 	rootCmd.AddCommand(cmd.Migrate())
 
 	versionCmd := &cobra.Command{